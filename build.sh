@@ -39,11 +39,15 @@ else
 fi
 
 # ── Step 2: Build Go binaries ───────────────────────────────────────────────
+# CATALOG_SIGNING_KEYS: public key(s) that sign upgrade-safety.json and mirrors.json.
+[ -n "$CATALOG_SIGNING_KEYS" ] || warn "未设置 CATALOG_SIGNING_KEYS，构建产物将忽略 upgrade-safety.json 与 mirrors.json"
+LDFLAGS="-X fnos-store/internal/source.signingKeys=$CATALOG_SIGNING_KEYS"
+
 info "构建 Go 二进制文件 (x86)..."
-GOOS=linux GOARCH=amd64 go build -o "$BUILD_DIR/store-server-x86" ./cmd/server/
+GOOS=linux GOARCH=amd64 go build -ldflags "$LDFLAGS" -o "$BUILD_DIR/store-server-x86" ./cmd/server/
 
 info "构建 Go 二进制文件 (arm)..."
-GOOS=linux GOARCH=arm64 go build -o "$BUILD_DIR/store-server-arm" ./cmd/server/
+GOOS=linux GOARCH=arm64 go build -ldflags "$LDFLAGS" -o "$BUILD_DIR/store-server-arm" ./cmd/server/
 
 info "Go 构建完成"
 
@@ -0,0 +1,70 @@
+// Command catalog-sign signs the lists published next to apps.json: the
+// upgrade safety list and the mirror registry.
+//
+//	catalog-sign -genkey                                  # print a new key pair
+//	catalog-sign -key signing.key list.json               # print the signed upgrade-safety.json
+//	catalog-sign -key signing.key -kind mirrors list.json # print the signed mirrors.json
+//
+// The public key is built into the store through CATALOG_SIGNING_KEYS (see
+// source.signingKeys); the private key file holds the base64 private key and
+// never leaves the publisher.
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"fnos-store/internal/source"
+)
+
+func main() {
+	genkey := flag.Bool("genkey", false, "generate a key pair")
+	keyPath := flag.String("key", "", "file holding the base64 Ed25519 private key")
+	kind := flag.String("kind", "safety", "list to sign: safety or mirrors")
+	flag.Parse()
+
+	if *genkey {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("public:  %s\nprivate: %s\n", base64.StdEncoding.EncodeToString(pub), base64.StdEncoding.EncodeToString(priv))
+		return
+	}
+
+	if *keyPath == "" || flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	rawKey, err := os.ReadFile(*keyPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	priv, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(rawKey)))
+	if err != nil || len(priv) != ed25519.PrivateKeySize {
+		log.Fatalf("%s: not a base64 Ed25519 private key", *keyPath)
+	}
+	payload, err := os.ReadFile(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	sign := source.SignSafetyList
+	switch *kind {
+	case "safety":
+	case "mirrors":
+		sign = source.SignMirrorList
+	default:
+		log.Fatalf("unknown -kind %q", *kind)
+	}
+	signed, err := sign(ed25519.PrivateKey(priv), payload)
+	if err != nil {
+		log.Fatalf("%s: %v", flag.Arg(0), err)
+	}
+	fmt.Println(string(signed))
+}
@@ -7,6 +7,7 @@ import (
 	"fnos-store/internal/cache"
 	"fnos-store/internal/config"
 	"fnos-store/internal/core"
+	"fnos-store/internal/docker"
 	"fnos-store/internal/platform"
 	"fnos-store/internal/scheduler"
 	"fnos-store/internal/source"
@@ -40,45 +41,63 @@ func main() {
 		log.Printf("cache init failed: %v", err)
 	}
 	cacheStore.CleanupStaleFiles()
+	config.SetMirrorScores(cacheStore.MirrorScores())
 
 	ac := platform.NewAppCenter(projectRoot)
 	src := source.NewFNOSAppsSource(
 		cachePath,
 		filepath.Join(projectRoot, "..", "fnos-apps", "apps.json"),
 		cfgMgr,
+		cacheStore,
 	)
 	recommendedSrc := source.NewRecommendedSource(
 		filepath.Join(dataDir, "cache", "recommended.json"),
 		filepath.Join(projectRoot, "..", "fnos-apps", "recommended.json"),
 		cfgMgr,
+		cacheStore,
+	)
+	safetySrc := source.NewSafetySource(
+		filepath.Join(dataDir, "cache", "upgrade-safety.json"),
+		cfgMgr,
+		cacheStore,
+	)
+	mirrorsSrc := source.NewMirrorsSource(
+		filepath.Join(dataDir, "cache", "mirrors.json"),
+		cfgMgr,
+		cacheStore,
 	)
 	reg := core.NewRegistry()
+	reg.SetHost(core.Host{Arch: platform.DetectPlatform(), FnOSVersion: platform.FnOSVersion()})
 	downloader := core.NewDownloader(downloadDir)
-	if err := downloader.CleanupStaleTmpFiles(); err != nil {
+	downloader.SetProxy(cfgMgr.Proxy)
+	if err := downloader.CleanupStaleTmpFiles(0); err != nil {
 		log.Printf("cleanup stale tmp files failed: %v", err)
 	}
 
-	checkInterval := time.Duration(cfg.CheckIntervalHours) * time.Hour
-
 	srv := api.NewServer(api.Config{
 		AppCenter:         ac,
 		Source:            src,
 		RecommendedSource: recommendedSrc,
+		SafetySource:      safetySrc,
+		MirrorsSource:     mirrorsSrc,
 		Registry:          reg,
 		Downloader:        downloader,
 		ConfigMgr:         cfgMgr,
 		CacheStore:        cacheStore,
+		Docker:            docker.NewClient(envOr("DOCKER_SOCKET", docker.DefaultSocket)),
 		AppsDir:           appsDir,
+		Journal:           api.NewJournal(filepath.Join(dataDir, "journal.json")),
 		Platform:          platform.DetectPlatform(),
 		StoreApp:          storeAppName,
 		StaticFS:          storeassets.WebFS,
 	})
 
-	sched := scheduler.New(checkInterval, srv.RefreshRegistry, cacheStore.LastCheckAt)
+	sched := scheduler.New(cfg.ScheduleLocation())
 	srv.SetScheduler(sched)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+	srv.RecoverInterrupted(ctx)
 	go sched.Start(ctx)
 
 	httpServer := &http.Server{
@@ -1,3 +1,7 @@
 module fnos-store
 
 go 1.25.0
+
+require gopkg.in/yaml.v3 v3.0.1
+
+require github.com/mozillazg/go-pinyin v0.21.0
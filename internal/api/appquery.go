@@ -0,0 +1,184 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"fnos-store/internal/config"
+	"fnos-store/internal/core"
+)
+
+const (
+	defaultAppPageSize = 50
+	maxAppPageSize     = 200
+)
+
+// App list filters for ?status=.
+const (
+	appFilterInstalled       = "installed"
+	appFilterNotInstalled    = "not_installed"
+	appFilterUpdateAvailable = "update_available"
+	appFilterUpToDate        = "up_to_date"
+	appFilterIncompatible    = "incompatible"
+)
+
+// App list orders for ?sort=. Without one, a search is ordered by relevance
+// and a plain listing by last update.
+const (
+	appSortDownloads = "downloads"
+	appSortName      = "name"
+	appSortUpdated   = "updated"
+)
+
+// appQuery is the search, filters, order and page of GET /api/apps.
+type appQuery struct {
+	text     string
+	category string
+	appType  string
+	status   string
+	source   string
+	platform string
+	sort     string
+	// includeIncompatible lists apps this box cannot run, which are hidden
+	// by default. Installed apps are always listed.
+	includeIncompatible bool
+	page                int // 0: no paging, every match
+	pageSize            int
+}
+
+func parseAppQuery(r *http.Request) (appQuery, error) {
+	v := r.URL.Query()
+	q := appQuery{
+		text:     strings.TrimSpace(v.Get("q")),
+		category: v.Get("category"),
+		appType:  v.Get("type"),
+		status:   v.Get("status"),
+		source:   v.Get("source"),
+		platform: v.Get("platform"),
+		sort:     v.Get("sort"),
+	}
+
+	switch q.status {
+	case "", appFilterInstalled, appFilterNotInstalled, appFilterUpdateAvailable, appFilterUpToDate:
+	case appFilterIncompatible:
+		q.includeIncompatible = true
+	default:
+		return q, fmt.Errorf("invalid status %q", q.status)
+	}
+	if raw := v.Get("include_incompatible"); raw != "" {
+		include, err := strconv.ParseBool(raw)
+		if err != nil {
+			return q, fmt.Errorf("invalid include_incompatible %q", raw)
+		}
+		q.includeIncompatible = q.includeIncompatible || include
+	}
+	switch q.sort {
+	case "", appSortDownloads, appSortName, appSortUpdated:
+	default:
+		return q, fmt.Errorf("invalid sort %q", q.sort)
+	}
+
+	if raw := v.Get("page"); raw != "" {
+		page, err := strconv.Atoi(raw)
+		if err != nil || page < 1 {
+			return q, fmt.Errorf("invalid page %q", raw)
+		}
+		q.page = page
+		q.pageSize = defaultAppPageSize
+	}
+	if raw := v.Get("page_size"); raw != "" {
+		size, err := strconv.Atoi(raw)
+		if err != nil || size < 1 {
+			return q, fmt.Errorf("invalid page_size %q", raw)
+		}
+		if q.page == 0 {
+			q.page = 1
+		}
+		q.pageSize = min(size, maxAppPageSize)
+	}
+	return q, nil
+}
+
+// apply filters and orders apps, which come from Registry.Search and so are
+// already in relevance order for a search. The store itself is never listed.
+func (q appQuery) apply(apps []core.AppInfo, cfg config.Config, storeApp string) []core.AppInfo {
+	out := apps[:0:0]
+	for _, app := range apps {
+		if storeApp != "" && app.AppName == storeApp {
+			continue
+		}
+		if q.matches(app, cfg) {
+			out = append(out, app)
+		}
+	}
+
+	switch q.sort {
+	case appSortDownloads:
+		slices.SortStableFunc(out, func(a, b core.AppInfo) int {
+			if a.DownloadCount != b.DownloadCount {
+				return b.DownloadCount - a.DownloadCount
+			}
+			return strings.Compare(core.NameSortKey(a.DisplayName), core.NameSortKey(b.DisplayName))
+		})
+	case appSortName:
+		slices.SortStableFunc(out, func(a, b core.AppInfo) int {
+			return strings.Compare(core.NameSortKey(a.DisplayName), core.NameSortKey(b.DisplayName))
+		})
+	case appSortUpdated:
+		slices.SortStableFunc(out, func(a, b core.AppInfo) int {
+			if c := strings.Compare(b.UpdatedAt, a.UpdatedAt); c != 0 {
+				return c
+			}
+			return strings.Compare(a.DisplayName, b.DisplayName)
+		})
+	}
+	return out
+}
+
+func (q appQuery) matches(app core.AppInfo, cfg config.Config) bool {
+	if app.Status == core.AppStatusIncompatible && !q.includeIncompatible {
+		return false
+	}
+	if q.category != "" && !strings.EqualFold(app.Category, q.category) {
+		return false
+	}
+	if q.appType != "" && !strings.EqualFold(app.AppType, q.appType) {
+		return false
+	}
+	if q.source != "" && !strings.EqualFold(app.Source, q.source) {
+		return false
+	}
+	if q.platform != "" && app.Platform != "" && !slices.Contains(strings.Split(app.Platform, ","), q.platform) {
+		return false
+	}
+
+	hasUpdate := app.Status == core.AppStatusUpdateAvailable && !cfg.IsAppIgnored(app.AppName)
+	switch q.status {
+	case appFilterInstalled:
+		return app.Installed
+	case appFilterNotInstalled:
+		return !app.Installed
+	case appFilterUpdateAvailable:
+		return hasUpdate
+	case appFilterUpToDate:
+		return app.Installed && !hasUpdate
+	case appFilterIncompatible:
+		return app.IncompatibleReason != ""
+	}
+	return true
+}
+
+// paginate returns the requested page of apps, or all of them without paging.
+func (q appQuery) paginate(apps []core.AppInfo) []core.AppInfo {
+	if q.page == 0 {
+		return apps
+	}
+	start := (q.page - 1) * q.pageSize
+	if start >= len(apps) {
+		return nil
+	}
+	return apps[start:min(start+q.pageSize, len(apps))]
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fnos-store/internal/config"
+	"fnos-store/internal/core"
+	"fnos-store/internal/source"
+)
+
+func newAppQueryTestServer(t *testing.T, host core.Host) *Server {
+	t.Helper()
+
+	registry := core.NewRegistry()
+	registry.SetHost(host)
+	registry.Merge([]core.Manifest{
+		{AppName: "jellyfin", Version: "10.9.0"},
+		{AppName: "emby", Version: "4.8.0"},
+		{AppName: "fnos-apps-store", Version: "1.0.0"},
+	}, []source.RemoteApp{
+		{AppName: "jellyfin", DisplayName: "Jellyfin", Version: "10.10.7", Category: "media", AppType: "native", Platforms: []string{"x86", "arm"}, DownloadCount: 50, UpdatedAt: "2026-03-01", Source: "fnos-apps"},
+		{AppName: "emby", DisplayName: "Emby", Version: "4.8.0", Category: "media", AppType: "docker", Platforms: []string{"x86"}, DownloadCount: 80, UpdatedAt: "2026-01-01", Source: "fnos-apps"},
+		{AppName: "thunder", DisplayName: "迅雷", Version: "1.0", Category: "download", AppType: "native", Platforms: []string{"x86"}, DownloadCount: 10, UpdatedAt: "2026-02-01", Source: "fnos-apps"},
+		{AppName: "iqiyi", DisplayName: "爱奇艺", Version: "1.0", Category: "media", AppType: "docker", Platforms: []string{"x86"}, DownloadCount: 5, UpdatedAt: "2025-06-01", Source: "fnos-apps"},
+		{AppName: "fnos-apps-store", DisplayName: "fnOS Apps", Version: "1.0.0"},
+	}, nil)
+
+	return &Server{
+		ac:        &stubAppCenter{},
+		registry:  registry,
+		configMgr: config.NewManager(t.TempDir()),
+		storeApp:  "fnos-apps-store",
+	}
+}
+
+func listApps(t *testing.T, s *Server, rawQuery string) (appsListResponse, int) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.handleListApps(rec, httptest.NewRequest(http.MethodGet, "/api/apps?"+rawQuery, nil))
+	var resp appsListResponse
+	if rec.Code == http.StatusOK {
+		decodeResponse(t, rec, &resp)
+	}
+	return resp, rec.Code
+}
+
+func TestHandleListAppsQuery(t *testing.T) {
+	s := newAppQueryTestServer(t, core.Host{})
+
+	tests := []struct {
+		query string
+		want  []string
+		total int
+	}{
+		{"", []string{"jellyfin", "thunder", "emby", "iqiyi"}, 4},
+		{"q=xunlei", []string{"thunder"}, 1},
+		{"q=jelly&category=download", nil, 0},
+		{"category=MEDIA", []string{"jellyfin", "emby", "iqiyi"}, 3},
+		{"type=docker&sort=updated", []string{"emby", "iqiyi"}, 2},
+		{"platform=arm", []string{"jellyfin"}, 1},
+		{"source=other", nil, 0},
+		{"status=installed", []string{"jellyfin", "emby"}, 2},
+		{"status=not_installed", []string{"thunder", "iqiyi"}, 2},
+		{"status=update_available", []string{"jellyfin"}, 1},
+		{"status=up_to_date", []string{"emby"}, 1},
+		{"sort=downloads", []string{"emby", "jellyfin", "thunder", "iqiyi"}, 4},
+		// Chinese names sort by pinyin: 爱奇艺 (aiqiyi) first, 迅雷 (xunlei) last.
+		{"sort=name", []string{"iqiyi", "emby", "jellyfin", "thunder"}, 4},
+		{"sort=downloads&page=2&page_size=3", []string{"iqiyi"}, 4},
+		{"page=3&page_size=2", nil, 4},
+	}
+	for _, tt := range tests {
+		resp, code := listApps(t, s, tt.query)
+		if code != http.StatusOK {
+			t.Errorf("%q: status %d", tt.query, code)
+			continue
+		}
+		var got []string
+		for _, app := range resp.Apps {
+			got = append(got, app.AppName)
+		}
+		if len(got) != len(tt.want) || resp.Total != tt.total {
+			t.Errorf("%q: apps = %v (total %d), want %v (total %d)", tt.query, got, resp.Total, tt.want, tt.total)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%q: apps = %v, want %v", tt.query, got, tt.want)
+				break
+			}
+		}
+	}
+
+	for _, bad := range []string{"sort=stars", "status=broken", "page=0", "page_size=x"} {
+		if _, code := listApps(t, s, bad); code != http.StatusBadRequest {
+			t.Errorf("%q: status %d, want 400", bad, code)
+		}
+	}
+}
+
+func TestHandleListAppsHidesIncompatible(t *testing.T) {
+	s := newAppQueryTestServer(t, core.Host{Arch: "arm"})
+
+	names := func(resp appsListResponse) []string {
+		var got []string
+		for _, app := range resp.Apps {
+			got = append(got, app.AppName)
+		}
+		return got
+	}
+
+	// emby is x86-only but installed, so it stays listed.
+	resp, _ := listApps(t, s, "sort=name")
+	if got := names(resp); len(got) != 2 || got[0] != "emby" || got[1] != "jellyfin" {
+		t.Errorf("default listing = %v, want [emby jellyfin]", got)
+	}
+	for _, app := range resp.Apps {
+		if want := app.AppName == "jellyfin"; app.Compatible != want {
+			t.Errorf("%s: compatible = %v, want %v", app.AppName, app.Compatible, want)
+		}
+	}
+
+	resp, _ = listApps(t, s, "include_incompatible=true")
+	if resp.Total != 4 {
+		t.Errorf("include_incompatible total = %d, want 4", resp.Total)
+	}
+	resp, _ = listApps(t, s, "status=incompatible&sort=name")
+	if got := names(resp); len(got) != 3 || got[0] != "iqiyi" {
+		t.Errorf("status=incompatible = %v, want [iqiyi emby thunder]", got)
+	}
+	if _, code := listApps(t, s, "include_incompatible=maybe"); code != http.StatusBadRequest {
+		t.Errorf("include_incompatible=maybe: status %d, want 400", code)
+	}
+}
@@ -11,13 +11,19 @@ import (
 )
 
 func (s *Server) handleListApps(w http.ResponseWriter, r *http.Request) {
-	apps := s.listRegistryApps()
+	query, err := parseAppQuery(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	cfg := s.configMgr.Get()
+	apps := query.apply(s.searchRegistryApps(query.text), cfg, s.storeApp)
+	total := len(apps)
+	apps = query.paginate(apps)
+
 	respApps := make([]appResponse, 0, len(apps))
 	for _, app := range apps {
-		if s.storeApp != "" && app.AppName == s.storeApp {
-			continue
-		}
 		status := ""
 		if app.Installed {
 			status = s.getRuntimeStatus(app.AppName)
@@ -65,15 +71,24 @@ func (s *Server) handleListApps(w http.ResponseWriter, r *http.Request) {
 			AppType:          app.AppType,
 			Category:         app.Category,
 			PostInstallNote:  app.PostInstallNote,
+
+			Compatible:         app.IncompatibleReason == "",
+			IncompatibleReason: app.IncompatibleReason,
+
+			UpgradeBlockedReason: app.UpgradeBlockedReason,
 		})
 	}
 
-	upgradeCap := s.ac.UpgradeCapability()
+	upgradeCap := s.ac.UpgradeCapability(r.Context())
 	writeJSON(w, http.StatusOK, appsListResponse{
 		UpgradeAllowed:       upgradeCap.Allowed,
 		UpgradeBlockedReason: upgradeCap.Reason,
 		Apps:                 respApps,
+		Total:                total,
+		Page:                 query.page,
+		PageSize:             query.pageSize,
 		LastCheck:            formatTimestamp(s.getLastCheck()),
+		Catalog:              s.catalogStatus(),
 	})
 }
 
@@ -149,7 +164,7 @@ func (s *Server) handleDownloadFpk(w http.ResponseWriter, r *http.Request) {
 
 	cfg := s.configMgr.Get()
 	prefix := config.GitHubMirrorPrefix(cfg.Mirror, cfg)
-	http.Redirect(w, r, prefix+found.DownloadURL, http.StatusFound)
+	http.Redirect(w, r, config.MirrorURL(prefix, found.DownloadURL), http.StatusFound)
 }
 
 func (s *Server) handleReloadApps(w http.ResponseWriter, r *http.Request) {
@@ -174,11 +189,14 @@ func (s *Server) handleReloadApps(w http.ResponseWriter, r *http.Request) {
 			msg = fmt.Sprintf("%s 连接失败", p.Mirror)
 		case "success":
 			msg = fmt.Sprintf("通过 %s 加载成功", p.Mirror)
+			if p.NotModified {
+				msg = fmt.Sprintf("通过 %s 确认应用列表已是最新", p.Mirror)
+			}
 		}
 		_ = stream.sendProgress(progressPayload{Step: p.Status, Message: msg})
 	}
 
-	remoteApps, fetchErr := src.FetchAppsWithProgress(r.Context(), onProgress)
+	remoteApps, prov, fetchErr := src.FetchAppsWithProgress(r.Context(), onProgress)
 	if fetchErr != nil {
 		_ = stream.sendProgress(progressPayload{
 			Step:    "error",
@@ -198,11 +216,12 @@ func (s *Server) handleReloadApps(w http.ResponseWriter, r *http.Request) {
 	s.registry.Merge(localApps, remoteApps, installedTags)
 	s.lastCheck = now
 	s.mu.Unlock()
+	s.setCatalogProvenance(prov)
 
 	if s.cacheStore != nil {
 		s.cacheStore.SetLastCheckAt(now)
 	}
-	s.refreshRuntimeStatus()
+	s.refreshRuntimeStatus(r.Context())
 
 	apps := s.listRegistryApps()
 	_ = stream.sendProgress(progressPayload{
@@ -0,0 +1,309 @@
+package api
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fnos-store/internal/config"
+	"fnos-store/internal/diagnostics"
+	"fnos-store/internal/docker"
+	"fnos-store/internal/platform"
+)
+
+const (
+	// maxBundleLogBytes caps each log in a support bundle; the tail is kept.
+	maxBundleLogBytes = 16 << 20
+	// maxBundleDockerLogLines is how far back each container log goes.
+	maxBundleDockerLogLines = 100000
+)
+
+// bundleIndex is bundle.json: what the bundle holds and what could not be
+// collected, so a missing file reads as "failed" rather than "forgotten".
+type bundleIndex struct {
+	App          string    `json:"app"`
+	StoreVersion string    `json:"store_version"`
+	GeneratedAt  time.Time `json:"generated_at"`
+	Files        []string  `json:"files"`
+	Errors       []string  `json:"errors,omitempty"`
+}
+
+// bundleMirrorConfig is the part of the store config a bundle carries: only
+// the mirror choices, which decide where downloads and pulls went.
+type bundleMirrorConfig struct {
+	Mirror             string `json:"mirror"`
+	DockerMirror       string `json:"docker_mirror"`
+	CustomGitHubMirror string `json:"custom_github_mirror,omitempty"`
+	CustomDockerMirror string `json:"custom_docker_mirror,omitempty"`
+
+	CustomGitHubMirrors []config.CustomMirror `json:"custom_github_mirrors,omitempty"`
+	CustomDockerMirrors []config.CustomMirror `json:"custom_docker_mirrors,omitempty"`
+}
+
+type bundleAppCenterList struct {
+	Apps  []platform.InstalledApp `json:"apps"`
+	Error string                  `json:"error,omitempty"`
+}
+
+type bundleVolumes struct {
+	Volumes        []platform.VolumeInfo `json:"volumes"`
+	AppVolume      int                   `json:"app_volume,omitempty"`
+	AppVolumeFound bool                  `json:"app_volume_found"`
+	Errors         []string              `json:"errors,omitempty"`
+}
+
+// handleGetSupportBundle returns a zip with everything needed to debug a
+// failed app, meant to be attached to an issue: the diagnostic report, full
+// app and container logs, manifest, compose file, recent operations, mirror
+// settings, appcenter-cli's app list and volumes. Logs, the compose file and
+// other free text pass through the app's redactor first.
+// ?step= and ?error= fill in the report as for /diagnostic.
+func (s *Server) handleGetSupportBundle(w http.ResponseWriter, r *http.Request) {
+	appName := r.PathValue("appname")
+	if appName == "" {
+		writeAPIError(w, http.StatusBadRequest, "missing app name")
+		return
+	}
+	ctx := r.Context()
+	now := time.Now().UTC()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("%s-support-%s.zip", appName, now.Format("20060102-150405"))))
+
+	red := s.redactorFor(appName)
+	b := &bundleWriter{zw: zip.NewWriter(w), modified: now}
+	index := bundleIndex{App: appName, StoreVersion: s.storeVersion(), GeneratedAt: now}
+
+	report := s.buildDiagnosticReport(ctx, appName, r.URL.Query().Get("step"), r.URL.Query().Get("error"))
+	b.addJSON("report.json", report)
+
+	s.addBundleLogs(ctx, b, red, appName)
+
+	manifestPath := filepath.Join(s.appsDir, appName, "manifest")
+	if data, err := os.ReadFile(manifestPath); err == nil {
+		b.add("manifest", data)
+	} else {
+		b.fail("manifest: %v", err)
+	}
+
+	for _, path := range appComposePaths(s.appsDir, appName) {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			b.fail("docker-compose.yaml: %v", err)
+			break
+		}
+		b.add("docker-compose.yaml", []byte(red.Redact(diagnostics.RedactSecretValues(string(data)))))
+		if env, err := os.ReadFile(filepath.Join(filepath.Dir(path), ".env")); err == nil {
+			b.add("compose.env", []byte(red.Redact(diagnostics.RedactSecretValues(string(env)))))
+		}
+		break
+	}
+
+	if s.queue != nil {
+		history := s.queue.History(appName)
+		for i := range history {
+			history[i].Message = red.Redact(history[i].Message)
+		}
+		b.addJSON("operations.json", history)
+	}
+
+	if s.configMgr != nil {
+		cfg := s.configMgr.Get()
+		b.addJSON("config.json", bundleMirrorConfig{
+			Mirror:             cfg.Mirror,
+			DockerMirror:       cfg.DockerMirror,
+			CustomGitHubMirror: red.Redact(cfg.CustomGitHubMirror),
+			CustomDockerMirror: red.Redact(cfg.CustomDockerMirror),
+
+			CustomGitHubMirrors: redactMirrors(red, cfg.CustomGitHubMirrors),
+			CustomDockerMirrors: redactMirrors(red, cfg.CustomDockerMirrors),
+		})
+	}
+
+	if s.ac != nil {
+		b.addJSON("appcenter-list.json", s.bundleAppCenterList(ctx))
+		b.addJSON("volumes.json", s.bundleVolumes(ctx, appName))
+	}
+
+	index.Files = b.files
+	index.Errors = b.errors
+	b.addJSON("bundle.json", index)
+	_ = b.zw.Close()
+}
+
+// redactMirrors masks the URLs of custom mirrors, which may carry tokens.
+func redactMirrors(red *diagnostics.Redactor, mirrors []config.CustomMirror) []config.CustomMirror {
+	out := make([]config.CustomMirror, len(mirrors))
+	for i, m := range mirrors {
+		m.URL = red.Redact(m.URL)
+		out[i] = m
+	}
+	return out
+}
+
+func (s *Server) addBundleLogs(ctx context.Context, b *bundleWriter, red *diagnostics.Redactor, appName string) {
+	for _, path := range appLogPaths(s.appsDir, appName) {
+		data, err := readFileTail(path, maxBundleLogBytes)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			b.fail("%s: %v", path, err)
+			continue
+		}
+		dir := "logs/system/"
+		if strings.HasPrefix(path, s.appsDir+string(filepath.Separator)) {
+			dir = "logs/app/"
+		}
+		b.add(dir+filepath.Base(path), []byte(red.Redact(string(data))))
+	}
+
+	containers := findDockerContainers(ctx, s.docker, s.appsDir, appName)
+	if len(containers) > 0 && s.docker == nil {
+		b.fail("docker logs: docker 不可用")
+		return
+	}
+	for _, name := range containers {
+		rc, err := s.docker.Logs(ctx, name, docker.LogsOptions{Tail: maxBundleDockerLogLines})
+		if err != nil {
+			b.fail("docker logs %s: %v", name, err)
+			continue
+		}
+		tb := &tailBuffer{max: maxBundleLogBytes}
+		_, err = io.Copy(tb, rc)
+		rc.Close()
+		if err != nil {
+			b.fail("docker logs %s: %v", name, err)
+		}
+		tail, _ := capTailBytes(string(tb.bytes()), maxBundleLogBytes)
+		b.add("logs/docker/"+name+".log", []byte(red.Redact(tail)))
+	}
+}
+
+func (s *Server) bundleAppCenterList(ctx context.Context) bundleAppCenterList {
+	var out bundleAppCenterList
+	err := s.queue.WithCLI(func() error {
+		var listErr error
+		out.Apps, listErr = s.ac.List(ctx)
+		return listErr
+	})
+	if err != nil {
+		out.Error = err.Error()
+	}
+	return out
+}
+
+func (s *Server) bundleVolumes(ctx context.Context, appName string) bundleVolumes {
+	var out bundleVolumes
+	err := s.queue.WithCLI(func() error {
+		var err error
+		out.Volumes, err = s.ac.ListVolumes(ctx)
+		if err != nil {
+			out.Errors = append(out.Errors, "list volumes: "+err.Error())
+		}
+		out.AppVolume, out.AppVolumeFound, err = s.ac.AppInstallVolume(ctx, appName)
+		return err
+	})
+	if err != nil {
+		out.Errors = append(out.Errors, "app volume: "+err.Error())
+	}
+	return out
+}
+
+// readFileTail reads at most maxBytes from the end of path.
+func readFileTail(path string, maxBytes int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if offset := info.Size() - maxBytes; offset > 0 {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+	// The log may still be growing; what was appended since Stat is left out.
+	return io.ReadAll(io.LimitReader(f, maxBytes))
+}
+
+// tailBuffer is a writer that keeps only the last max bytes written to it,
+// so a log of any length streams through in bounded memory.
+type tailBuffer struct {
+	max int
+	buf []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(p) > t.max {
+		p = p[len(p)-t.max:]
+	}
+	t.buf = append(t.buf, p...)
+	// Compact only once twice the limit is held, so the copy is amortized
+	// over at least max bytes of writes.
+	if len(t.buf) > 2*t.max {
+		t.buf = append(t.buf[:0], t.buf[len(t.buf)-t.max:]...)
+	}
+	return n, nil
+}
+
+func (t *tailBuffer) bytes() []byte {
+	if len(t.buf) > t.max {
+		return t.buf[len(t.buf)-t.max:]
+	}
+	return t.buf
+}
+
+// bundleWriter adds files to a zip, remembering what went in and what failed.
+// The first write error stops further writes: the client has gone.
+type bundleWriter struct {
+	zw       *zip.Writer
+	modified time.Time
+	files    []string
+	errors   []string
+	err      error
+}
+
+func (b *bundleWriter) add(name string, data []byte) {
+	if b.err != nil {
+		return
+	}
+	f, err := b.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: b.modified})
+	if err == nil {
+		_, err = f.Write(data)
+	}
+	if err != nil {
+		b.err = err
+		return
+	}
+	b.files = append(b.files, name)
+}
+
+func (b *bundleWriter) addJSON(name string, v any) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		b.fail("%s: %v", name, err)
+		return
+	}
+	b.add(name, data)
+}
+
+func (b *bundleWriter) fail(format string, args ...any) {
+	b.errors = append(b.errors, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestHandleGetSupportBundle(t *testing.T) {
+	const appName = "jellyfin"
+
+	s := newDiagnosticTestServer(t)
+	s.queue = NewOperationQueue()
+
+	appDir := filepath.Join(s.appsDir, appName)
+	writeFile := func(rel, content string) {
+		t.Helper()
+		path := filepath.Join(appDir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("manifest", "appname = jellyfin\nversion = 10.10.7\n")
+	writeFile("app/docker/docker-compose.yaml", "services:\n  app:\n    image: jellyfin:10\n    environment:\n      - ADMIN_PASSWORD=hunter2\n")
+	writeFile("var/jellyfin.log", "started\nfailed to bind\n")
+
+	s.queue.TryStart("install", appName)
+	s.queue.SetResult(appName, opResultError, "安装失败")
+	s.queue.FinishApp(appName)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/apps/"+appName+"/support-bundle?step=installing", nil)
+	req.SetPathValue("appname", appName)
+	rec := httptest.NewRecorder()
+	s.handleGetSupportBundle(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body=%s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/zip" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatalf("open zip: %v", err)
+	}
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(data)
+	}
+
+	for _, name := range []string{"bundle.json", "report.json", "manifest", "docker-compose.yaml", "operations.json"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("bundle is missing %s; has %v", name, keys(files))
+		}
+	}
+	var logFound bool
+	for name, content := range files {
+		if strings.HasPrefix(name, "logs/") && strings.Contains(content, "failed to bind") {
+			logFound = true
+		}
+	}
+	if !logFound {
+		t.Errorf("bundle has no app log; has %v", keys(files))
+	}
+	if strings.Contains(files["docker-compose.yaml"], "hunter2") {
+		t.Error("compose secret leaked into bundle")
+	}
+
+	var ops []OperationRecord
+	if err := json.Unmarshal([]byte(files["operations.json"]), &ops); err != nil {
+		t.Fatalf("operations.json: %v", err)
+	}
+	if len(ops) != 1 || ops[0].Operation != "install" || ops[0].Result != opResultError {
+		t.Errorf("operations = %+v, want the failed install", ops)
+	}
+}
+
+func keys(m map[string]string) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
+
+func TestTailBuffer(t *testing.T) {
+	tb := &tailBuffer{max: 10}
+	var want strings.Builder
+	for i := range 100 {
+		chunk := strconv.Itoa(i) + ","
+		want.WriteString(chunk)
+		if _, err := tb.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+		if len(tb.buf) > 2*tb.max {
+			t.Fatalf("buffer grew to %d bytes", len(tb.buf))
+		}
+	}
+	if got, all := string(tb.bytes()), want.String(); got != all[len(all)-10:] {
+		t.Errorf("tail = %q, want %q", got, all[len(all)-10:])
+	}
+
+	if _, err := tb.Write([]byte("a write longer than the limit")); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(tb.bytes()); got != " the limit" {
+		t.Errorf("tail after a long write = %q", got)
+	}
+}
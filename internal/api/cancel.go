@@ -0,0 +1,63 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// cancelledMessage ends the stream of a cancelled operation.
+const cancelledMessage = "操作已取消"
+
+// cancelWait bounds how long a cancel request waits for the operation to
+// stop. Downloads and pulls stop at once; the wait only runs long when a
+// stage is slow to notice.
+const cancelWait = 10 * time.Second
+
+type cancelResponse struct {
+	AppName string `json:"appname"`
+	// Result is how the operation ended, or "cancelling" when it had not
+	// stopped yet when the request returned.
+	Result  string `json:"result"`
+	Message string `json:"message,omitempty"`
+}
+
+// handleCancelOperation cancels the app's running install or update. It is
+// honoured while the operation downloads or pulls images, and refused once
+// the operation has started changing the installed app. The response carries
+// the operation's final result, which can still be a success or an error
+// when it finished before the cancel took effect.
+func (s *Server) handleCancelOperation(w http.ResponseWriter, r *http.Request) {
+	appname := r.PathValue("appname")
+	if appname == "" {
+		writeAPIError(w, http.StatusBadRequest, "appname is required")
+		return
+	}
+
+	done, err := s.queue.Cancel(appname)
+	switch {
+	case errors.Is(err, errNoOperation):
+		writeAPIError(w, http.StatusNotFound, err.Error())
+		return
+	case err != nil:
+		writeAPIError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	timer := time.NewTimer(cancelWait)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		writeJSON(w, http.StatusAccepted, cancelResponse{AppName: appname, Result: "cancelling"})
+		return
+	case <-r.Context().Done():
+		return
+	}
+
+	resp := cancelResponse{AppName: appname, Result: opResultInterrupted}
+	if history := s.queue.History(appname); len(history) > 0 {
+		resp.Result, resp.Message = history[0].Result, history[0].Message
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func cancelOperation(t *testing.T, s *Server, appname string) (cancelResponse, int) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodDelete, "/api/apps/"+appname+"/operation", nil)
+	req.SetPathValue("appname", appname)
+	rec := httptest.NewRecorder()
+	s.handleCancelOperation(rec, req)
+	var resp cancelResponse
+	if rec.Code == http.StatusOK {
+		decodeResponse(t, rec, &resp)
+	}
+	return resp, rec.Code
+}
+
+// startDownload runs a stand-in for an install that is downloading: it waits
+// on its context and fails the stage the way runStandard does.
+func startDownload(s *Server, appname string) {
+	s.queue.TryStart("install", appname)
+	ctx, release := s.queue.Cancellable(context.Background(), appname)
+	go func() {
+		defer s.queue.FinishApp(appname)
+		defer release()
+		stream := newBackgroundStream(context.Background(), appname)
+		<-ctx.Done()
+		stream.failStage(ctx, ctx.Err())
+		stream.recordResult(s.queue)
+	}()
+}
+
+func TestHandleCancelOperation(t *testing.T) {
+	t.Run("cancels a download and reports it as cancelled", func(t *testing.T) {
+		s := &Server{queue: NewOperationQueue()}
+		startDownload(s, "gopeed")
+
+		resp, code := cancelOperation(t, s, "gopeed")
+		if code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", code)
+		}
+		if resp.Result != opResultCancelled || resp.Message != cancelledMessage {
+			t.Errorf("response = %+v, want a cancelled result", resp)
+		}
+		if h := s.queue.History("gopeed"); len(h) != 1 || h[0].Result != opResultCancelled {
+			t.Errorf("history = %+v, want one cancelled record", h)
+		}
+		if s.queue.IsBusy() {
+			t.Error("queue still busy after the cancelled operation finished")
+		}
+	})
+
+	t.Run("refuses once the install step has begun", func(t *testing.T) {
+		s := &Server{queue: NewOperationQueue()}
+		s.queue.TryStart("update", "jellyfin")
+		ctx, release := s.queue.Cancellable(context.Background(), "jellyfin")
+		defer release()
+		if !s.queue.BeginDestructive("jellyfin") {
+			t.Fatal("BeginDestructive = false for an operation nobody cancelled")
+		}
+
+		if _, code := cancelOperation(t, s, "jellyfin"); code != http.StatusConflict {
+			t.Errorf("status = %d, want 409", code)
+		}
+		if ctx.Err() != nil {
+			t.Error("refused cancel still cancelled the operation")
+		}
+	})
+
+	t.Run("a cancel before the install step stops it", func(t *testing.T) {
+		q := NewOperationQueue()
+		q.TryStart("update", "emby")
+		ctx, release := q.Cancellable(context.Background(), "emby")
+		defer release()
+		if _, err := q.Cancel("emby"); err != nil {
+			t.Fatalf("Cancel: %v", err)
+		}
+		if q.BeginDestructive("emby") {
+			t.Error("BeginDestructive = true after the operation was cancelled")
+		}
+		if context.Cause(ctx) != errOperationCancelled {
+			t.Errorf("cause = %v, want errOperationCancelled", context.Cause(ctx))
+		}
+	})
+
+	t.Run("refuses operations without a cancel point", func(t *testing.T) {
+		s := &Server{queue: NewOperationQueue()}
+		s.queue.TryStart("uninstall", "emby")
+		if _, code := cancelOperation(t, s, "emby"); code != http.StatusConflict {
+			t.Errorf("status = %d, want 409", code)
+		}
+	})
+
+	t.Run("404 without an operation", func(t *testing.T) {
+		s := &Server{queue: NewOperationQueue()}
+		if _, code := cancelOperation(t, s, "emby"); code != http.StatusNotFound {
+			t.Errorf("status = %d, want 404", code)
+		}
+	})
+}
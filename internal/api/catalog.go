@@ -0,0 +1,86 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"fnos-store/internal/config"
+	"fnos-store/internal/source"
+)
+
+// catalogResponse tells the UI where the app list it shows came from, so a
+// week-old cached copy is not mistaken for a fresh one.
+type catalogResponse struct {
+	Origin     string `json:"origin"`
+	Mirror     string `json:"mirror,omitempty"`
+	AsOf       string `json:"as_of,omitempty"`
+	AgeSeconds int64  `json:"age_seconds"`
+	Stale      bool   `json:"stale"`
+	Warning    string `json:"warning,omitempty"`
+	FetchError string `json:"fetch_error,omitempty"`
+	// InvalidEntries lists catalog entries left out for failing validation.
+	InvalidEntries []string `json:"invalid_entries,omitempty"`
+}
+
+// setCatalogProvenance records where the merged catalog came from and warns
+// when it is older than the configured threshold.
+func (s *Server) setCatalogProvenance(prov source.Provenance) {
+	s.mu.Lock()
+	s.catalog = prov
+	s.mu.Unlock()
+
+	if resp := s.catalogStatus(); resp != nil && resp.Stale {
+		log.Printf("catalog: %s (%s)", resp.Warning, resp.FetchError)
+	}
+}
+
+// catalogStatus describes the catalog in use, or nil before one was loaded.
+func (s *Server) catalogStatus() *catalogResponse {
+	s.mu.RLock()
+	prov := s.catalog
+	s.mu.RUnlock()
+	if prov.Origin == "" {
+		return nil
+	}
+
+	age := prov.Age(time.Now())
+	resp := &catalogResponse{
+		Origin:     prov.Origin,
+		Mirror:     prov.Mirror,
+		AsOf:       formatTimestamp(prov.AsOf),
+		AgeSeconds: int64(age / time.Second),
+		FetchError: prov.FetchError,
+	}
+	for _, ee := range prov.InvalidEntries {
+		resp.InvalidEntries = append(resp.InvalidEntries, ee.String())
+	}
+	if prov.Origin == source.OriginRemote {
+		return resp
+	}
+
+	threshold := time.Duration(s.staleCatalogHours()) * time.Hour
+	resp.Stale = prov.AsOf.IsZero() || age > threshold
+	if resp.Stale {
+		what := "本地缓存"
+		if prov.Origin == source.OriginLocal {
+			what = "内置应用列表"
+		}
+		if prov.AsOf.IsZero() {
+			resp.Warning = fmt.Sprintf("所有加速节点均无法连接，正在使用%s，更新时间未知", what)
+		} else {
+			resp.Warning = fmt.Sprintf("所有加速节点均无法连接，正在使用 %d 小时前的%s", int(age.Hours()), what)
+		}
+	}
+	return resp
+}
+
+func (s *Server) staleCatalogHours() int {
+	if s.configMgr == nil {
+		return config.DefaultStaleCatalogHours
+	}
+	if hours := s.configMgr.Get().StaleCatalogHours; hours > 0 {
+		return hours
+	}
+	return config.DefaultStaleCatalogHours
+}
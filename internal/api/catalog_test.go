@@ -0,0 +1,49 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"fnos-store/internal/config"
+	"fnos-store/internal/source"
+)
+
+func TestCatalogStatusStaleness(t *testing.T) {
+	cfgMgr := config.NewManager(t.TempDir())
+	if err := cfgMgr.SaveConfig(config.Config{StaleCatalogHours: 24}); err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{configMgr: cfgMgr}
+
+	if got := s.catalogStatus(); got != nil {
+		t.Fatalf("status before any fetch = %+v, want nil", got)
+	}
+
+	now := time.Now()
+	tests := []struct {
+		name      string
+		prov      source.Provenance
+		wantStale bool
+	}{
+		{"remote", source.Provenance{Origin: source.OriginRemote, Mirror: "GH-Proxy", AsOf: now}, false},
+		{"fresh cache", source.Provenance{Origin: source.OriginCache, AsOf: now.Add(-2 * time.Hour)}, false},
+		{"old cache", source.Provenance{Origin: source.OriginCache, AsOf: now.Add(-30 * time.Hour)}, true},
+		{"bundled, unknown age", source.Provenance{Origin: source.OriginLocal}, true},
+	}
+	for _, tt := range tests {
+		s.setCatalogProvenance(tt.prov)
+		got := s.catalogStatus()
+		if got.Origin != tt.prov.Origin || got.Stale != tt.wantStale {
+			t.Errorf("%s: status = %+v", tt.name, got)
+		}
+		if tt.wantStale && got.Warning == "" {
+			t.Errorf("%s: stale catalog without a warning", tt.name)
+		}
+	}
+
+	s.setCatalogProvenance(source.Provenance{Origin: source.OriginCache, AsOf: now.Add(-30 * time.Hour)})
+	if got := s.catalogStatus(); !strings.Contains(got.Warning, "30 小时") {
+		t.Errorf("warning = %q, want the age in hours", got.Warning)
+	}
+}
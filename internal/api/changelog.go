@@ -0,0 +1,96 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"sort"
+
+	"fnos-store/internal/core"
+	"fnos-store/internal/source"
+)
+
+// Where a changelog came from.
+const (
+	changelogFromCatalog = "catalog"
+	changelogFromGitHub  = "github"
+)
+
+type changelogResponse struct {
+	AppName string `json:"appname"`
+	// From is the version the changes are counted from (exclusive), To the
+	// catalog's latest version (inclusive).
+	From    string                  `json:"from,omitempty"`
+	To      string                  `json:"to"`
+	Source  string                  `json:"source,omitempty"`
+	Entries []source.ChangelogEntry `json:"entries"`
+}
+
+// handleGetChangelog returns what changed between two versions of an app,
+// newest first: from the installed version with ?from=installed (the
+// default), from any version with ?from=<version>, and only the latest
+// version's notes when the app is not installed. The catalog's changelog is
+// used when it has one; otherwise the GitHub release notes, through the
+// configured mirror.
+func (s *Server) handleGetChangelog(w http.ResponseWriter, r *http.Request) {
+	appName := r.PathValue("appname")
+	if appName == "" {
+		writeAPIError(w, http.StatusBadRequest, "missing app name")
+		return
+	}
+	app, ok := s.getRegistryApp(appName)
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "app not found")
+		return
+	}
+
+	from := r.URL.Query().Get("from")
+	if from == "" || from == "installed" {
+		from = app.InstalledVersion
+	}
+
+	resp := changelogResponse{AppName: appName, From: from, To: app.LatestVersion, Entries: []source.ChangelogEntry{}}
+	entries, origin := app.Changelog, changelogFromCatalog
+	if len(entries) == 0 {
+		entries, origin = nil, ""
+		if rn, ok := s.source.(source.ReleaseNotesSource); ok && app.ReleaseTag != "" {
+			notes, err := rn.ReleaseNotes(r.Context(), appName, app.ReleaseTag, from)
+			if err != nil {
+				log.Printf("changelog %s: %v", appName, err)
+				writeAPIError(w, http.StatusBadGateway, "获取更新日志失败: "+err.Error())
+				return
+			}
+			entries, origin = notes, changelogFromGitHub
+		}
+	}
+	resp.Entries = append(resp.Entries, changelogBetween(entries, from, app.LatestVersion)...)
+	if len(resp.Entries) > 0 {
+		resp.Source = origin
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// changelogBetween returns the entries newer than from and not newer than
+// to, newest first. With from empty only to's own entries are returned. A
+// revision of the same version (from == to) keeps to's entries, since that
+// is the update on offer.
+func changelogBetween(entries []source.ChangelogEntry, from, to string) []source.ChangelogEntry {
+	var out []source.ChangelogEntry
+	for _, e := range entries {
+		upper := core.CompareVersions(e.Version, to)
+		switch {
+		case upper > 0:
+			continue
+		case from == "" || core.CompareVersions(from, to) >= 0:
+			if upper != 0 {
+				continue
+			}
+		case core.CompareVersions(e.Version, from) <= 0:
+			continue
+		}
+		out = append(out, e)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return core.CompareVersions(out[i].Version, out[j].Version) > 0
+	})
+	return out
+}
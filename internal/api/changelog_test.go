@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fnos-store/internal/core"
+	"fnos-store/internal/source"
+)
+
+// releaseNotesSource is a catalog source that also serves release notes.
+type releaseNotesSource struct {
+	notes []source.ChangelogEntry
+	err   error
+	calls int
+}
+
+func (s *releaseNotesSource) Name() string { return "test" }
+
+func (s *releaseNotesSource) FetchApps(context.Context) ([]source.RemoteApp, source.Provenance, error) {
+	return nil, source.Provenance{}, errors.New("not used")
+}
+
+func (s *releaseNotesSource) ReleaseNotes(context.Context, string, string, string) ([]source.ChangelogEntry, error) {
+	s.calls++
+	return s.notes, s.err
+}
+
+func getChangelog(t *testing.T, s *Server, appname, rawQuery string) (changelogResponse, int) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/apps/"+appname+"/changelog?"+rawQuery, nil)
+	req.SetPathValue("appname", appname)
+	rec := httptest.NewRecorder()
+	s.handleGetChangelog(rec, req)
+	var resp changelogResponse
+	if rec.Code == http.StatusOK {
+		decodeResponse(t, rec, &resp)
+	}
+	return resp, rec.Code
+}
+
+func versionsOf(entries []source.ChangelogEntry) []string {
+	var out []string
+	for _, e := range entries {
+		out = append(out, e.Version)
+	}
+	return out
+}
+
+func TestHandleGetChangelog(t *testing.T) {
+	src := &releaseNotesSource{notes: []source.ChangelogEntry{
+		{Version: "1.7.0", Notes: "new UI"},
+		{Version: "1.6.1", Notes: "fixes"},
+		{Version: "1.6.0", Notes: "old"},
+	}}
+	registry := core.NewRegistry()
+	registry.Merge([]core.Manifest{
+		{AppName: "gopeed", Version: "1.6.0"},
+		{AppName: "jellyfin", Version: "10.9.0"},
+	}, []source.RemoteApp{
+		{AppName: "gopeed", Version: "1.7.0", ReleaseTag: "gopeed/v1.7.0"},
+		{AppName: "jellyfin", Version: "10.10.7", ReleaseTag: "jellyfin/v10.10.7", Changelog: []source.ChangelogEntry{
+			{Version: "10.10.7", Notes: "security"},
+			{Version: "10.10.0", Notes: "big release"},
+			{Version: "10.9.0", Notes: "installed"},
+		}},
+		{AppName: "emby", Version: "4.9.0", ReleaseTag: "emby/v4.9.0", Changelog: []source.ChangelogEntry{
+			{Version: "4.9.0", Notes: "latest"},
+			{Version: "4.8.0", Notes: "older"},
+		}},
+	}, nil)
+	s := &Server{source: src, registry: registry}
+
+	tests := []struct {
+		app, query string
+		want       []string
+		source     string
+	}{
+		{"jellyfin", "from=installed", []string{"10.10.7", "10.10.0"}, changelogFromCatalog},
+		{"jellyfin", "", []string{"10.10.7", "10.10.0"}, changelogFromCatalog},
+		{"jellyfin", "from=10.10.0", []string{"10.10.7"}, changelogFromCatalog},
+		{"emby", "", []string{"4.9.0"}, changelogFromCatalog},
+		{"gopeed", "from=installed", []string{"1.7.0", "1.6.1"}, changelogFromGitHub},
+	}
+	for _, tt := range tests {
+		resp, code := getChangelog(t, s, tt.app, tt.query)
+		if code != http.StatusOK {
+			t.Errorf("%s?%s: status %d", tt.app, tt.query, code)
+			continue
+		}
+		got := versionsOf(resp.Entries)
+		if len(got) != len(tt.want) || resp.Source != tt.source {
+			t.Errorf("%s?%s: entries = %v from %q, want %v from %q", tt.app, tt.query, got, resp.Source, tt.want, tt.source)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s?%s: entries = %v, want %v", tt.app, tt.query, got, tt.want)
+				break
+			}
+		}
+	}
+	if src.calls != 1 {
+		t.Errorf("release notes fetched %d times, want only for the app without a catalog changelog", src.calls)
+	}
+
+	if _, code := getChangelog(t, s, "missing", ""); code != http.StatusNotFound {
+		t.Errorf("unknown app: status %d, want 404", code)
+	}
+	src.err = errors.New("offline")
+	if _, code := getChangelog(t, s, "gopeed", ""); code != http.StatusBadGateway {
+		t.Errorf("failed fetch: status %d, want 502", code)
+	}
+}
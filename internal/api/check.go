@@ -4,6 +4,8 @@ import (
 	"net/http"
 
 	"fnos-store/internal/core"
+	"fnos-store/internal/platform"
+	"fnos-store/internal/source"
 )
 
 func (s *Server) handleCheck(w http.ResponseWriter, r *http.Request) {
@@ -31,6 +33,12 @@ func (s *Server) handleCheck(w http.ResponseWriter, r *http.Request) {
 	if fetchErr != nil {
 		resp.Status = "partial"
 		resp.Warning = fetchErr.Error()
+	} else if catalog := s.catalogStatus(); catalog != nil && catalog.Origin != source.OriginRemote {
+		resp.Status = "partial"
+		resp.Warning = catalog.Warning
+		if resp.Warning == "" {
+			resp.Warning = catalog.FetchError
+		}
 	}
 
 	writeJSON(w, http.StatusOK, resp)
@@ -45,6 +53,11 @@ func (s *Server) handleStatus(w http.ResponseWriter, _ *http.Request) {
 		LastCheck: formatTimestamp(s.getLastCheck()),
 		Platform:  s.platform,
 		ActiveOps: activeOps,
+		Recovered: s.queue.Recovered(),
+		Catalog:   s.catalogStatus(),
+
+		HungCommands:      platform.HungCommands(),
+		SigningKeyWarning: source.SigningKeyStatus(),
 	}
 
 	// Backward compat: fill single-operation fields from first active op
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"errors"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"fnos-store/internal/config"
+	"fnos-store/internal/core"
+	"fnos-store/internal/docker"
+)
+
+type appServicesResponse struct {
+	AppName     string                  `json:"appname"`
+	ComposeFile string                  `json:"compose_file,omitempty"`
+	Services    []docker.ComposeService `json:"services"`
+}
+
+// handleGetAppServices returns the services an installed app's compose file
+// declares, with variables substituted the way docker compose would.
+func (s *Server) handleGetAppServices(w http.ResponseWriter, r *http.Request) {
+	appName := r.PathValue("appname")
+	if appName == "" {
+		writeAPIError(w, http.StatusBadRequest, "missing app name")
+		return
+	}
+
+	vars := map[string]string{}
+	if s.configMgr != nil {
+		cfg := s.configMgr.Get()
+		if prefix := config.DockerMirrorPrefix(cfg.DockerMirror, cfg); prefix != "" {
+			vars["DOCKER_MIRROR"] = prefix
+		}
+	}
+
+	path, services, err := readAppCompose(s.appsDir, appName, vars)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if services == nil {
+		services = []docker.ComposeService{}
+	}
+	writeJSON(w, http.StatusOK, appServicesResponse{AppName: appName, ComposeFile: path, Services: services})
+}
+
+func appComposePaths(appsDir, appName string) []string {
+	return []string{
+		filepath.Join(appsDir, appName, "app", "docker", "docker-compose.yaml"),
+		filepath.Join(appsDir, appName, "app", "docker-compose.yaml"),
+	}
+}
+
+// readAppCompose parses the first compose file an installed app has. VERSION
+// defaults to the installed fpk version. A missing file is not an error: the
+// path comes back empty.
+func readAppCompose(appsDir, appName string, vars map[string]string) (string, []docker.ComposeService, error) {
+	for _, path := range appComposePaths(appsDir, appName) {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return path, nil, err
+		}
+
+		if _, ok := vars["VERSION"]; !ok {
+			if m, err := core.ParseManifest(filepath.Join(appsDir, appName, "manifest")); err == nil {
+				vars = maps.Clone(vars)
+				if vars == nil {
+					vars = map[string]string{}
+				}
+				vars["VERSION"] = m.FpkVersion
+			}
+		}
+		services, err := docker.ParseCompose(data, composeEnv(filepath.Dir(path), vars))
+		return path, services, err
+	}
+	return "", nil, nil
+}
+
+// composeEnv is the environment compose substitutes from: the project's .env
+// file, overridden by the variables the store itself sets.
+func composeEnv(composeDir string, vars map[string]string) map[string]string {
+	env, err := docker.ReadEnvFile(filepath.Join(composeDir, ".env"))
+	if err != nil {
+		env = make(map[string]string, len(vars))
+	}
+	maps.Copy(env, vars)
+	return env
+}
+
+// composeImages lists the distinct images the services reference, in file
+// order.
+func composeImages(services []docker.ComposeService) []string {
+	var images []string
+	seen := make(map[string]bool)
+	for _, svc := range services {
+		if svc.Image == "" || seen[svc.Image] {
+			continue
+		}
+		seen[svc.Image] = true
+		images = append(images, svc.Image)
+	}
+	return images
+}
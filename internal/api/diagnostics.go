@@ -1,6 +1,8 @@
 package api
 
 import (
+	"context"
+	"log"
 	"net/http"
 	"runtime"
 	"time"
@@ -16,6 +18,17 @@ func (s *Server) handleGetAppDiagnostic(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	report := s.buildDiagnosticReport(r.Context(), app, step, r.URL.Query().Get("error"))
+
+	issueURL, err := diagnostics.BuildIssueURL(report)
+	if err != nil {
+		issueURL = ""
+	}
+
+	writeJSON(w, http.StatusOK, diagnosticResponse{Report: report, IssueURL: issueURL})
+}
+
+func (s *Server) buildDiagnosticReport(ctx context.Context, app, step, errMsg string) diagnostics.DiagnosticReport {
 	displayName := app
 	version := ""
 	appType := ""
@@ -28,28 +41,42 @@ func (s *Server) handleGetAppDiagnostic(w http.ResponseWriter, r *http.Request)
 		appType = info.AppType
 	}
 
-	rawTail, _, _ := fetchAppLogTail(r.Context(), s.appsDir, app, 200, 0)
-	tail, truncated := diagnostics.TruncateLogTail(rawTail, diagnostics.MaxLogLines, diagnostics.MaxLogBytes)
+	// Redact before truncating, so a cut never leaves half a secret without
+	// the key that identifies it.
+	red := s.redactorFor(app)
+	rawTail, _, _ := fetchAppLogTail(ctx, s.docker, s.appsDir, app, 200, 0)
+	tail, truncated := diagnostics.TruncateLogTail(red.Redact(rawTail), diagnostics.MaxLogLines, diagnostics.MaxLogBytes)
 
-	report := diagnostics.DiagnosticReport{
+	return diagnostics.DiagnosticReport{
 		App:          app,
 		DisplayName:  displayName,
 		Version:      version,
 		Arch:         diagnostics.NormalizeArch(runtime.GOARCH),
 		AppType:      appType,
 		FailedStep:   step,
-		ErrorMessage: diagnostics.TruncateError(r.URL.Query().Get("error")),
+		ErrorMessage: diagnostics.TruncateError(red.Redact(errMsg)),
 		LogTail:      tail,
 		LogTruncated: truncated,
 		StoreVersion: s.storeVersion(),
 		Platform:     runtime.GOOS + "/" + runtime.GOARCH,
 		Timestamp:    time.Now().UTC().Format(time.RFC3339),
 	}
+}
 
-	issueURL, err := diagnostics.BuildIssueURL(report)
+// redactorFor returns the redactor for app's output: the built-in rules, the
+// catalog's rules for the app, and IP masking when configured. A catalog rule
+// that does not compile is logged and skipped.
+func (s *Server) redactorFor(app string) *diagnostics.Redactor {
+	var opts diagnostics.RedactOptions
+	if s.configMgr != nil {
+		opts.RedactIPs = s.configMgr.Get().RedactIPs
+	}
+	if info, ok := s.getRegistryApp(app); ok {
+		opts.AppPatterns = info.RedactPatterns
+	}
+	red, err := diagnostics.NewRedactor(opts)
 	if err != nil {
-		issueURL = ""
+		log.Printf("redact: %s: %v", app, err)
 	}
-
-	writeJSON(w, http.StatusOK, diagnosticResponse{Report: report, IssueURL: issueURL})
+	return red
 }
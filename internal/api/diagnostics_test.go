@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -88,6 +90,30 @@ func TestHandleGetAppDiagnostic(t *testing.T) {
 		}
 	})
 
+	t.Run("secrets are redacted from report and issue URL", func(t *testing.T) {
+		s := newDiagnosticTestServer(t)
+		logPath := filepath.Join(s.appsDir, appName, "var", appName+".log")
+		if err := os.MkdirAll(filepath.Dir(logPath), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(logPath, []byte("login with password=hunter2\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		resp := requestDiagnostic(t, s, appName, "step=installing&error="+url.QueryEscape("wizard_natfrp_token=abcd1234 rejected"))
+
+		var got diagnosticResponse
+		decodeResponse(t, resp, &got)
+
+		for _, secret := range []string{"hunter2", "abcd1234"} {
+			if strings.Contains(got.Report.LogTail, secret) || strings.Contains(got.Report.ErrorMessage, secret) {
+				t.Errorf("report leaks %q: %+v", secret, got.Report)
+			}
+			if strings.Contains(got.IssueURL, secret) {
+				t.Errorf("issue URL leaks %q", secret)
+			}
+		}
+	})
+
 	t.Run("missing log file still returns diagnostic", func(t *testing.T) {
 		s := newDiagnosticTestServer(t)
 		resp := requestDiagnostic(t, s, appName, "step=verifying")
@@ -0,0 +1,293 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"fnos-store/internal/cache"
+	"fnos-store/internal/config"
+	"fnos-store/internal/docker"
+)
+
+type appImageStore interface {
+	AppImages(appname string) []cache.AppImage
+	SetAppImages(appname string, images []cache.AppImage)
+	RemoveAppImages(appname string)
+	AllAppImages() map[string][]cache.AppImage
+}
+
+type appImageResponse struct {
+	Ref string `json:"ref"`
+	// ID is the image the reference resolved to when the app was installed;
+	// CurrentID is what it resolves to now. They differ once something else
+	// re-pulled the tag, which leaves the recorded image dangling.
+	ID        string `json:"id,omitempty"`
+	CurrentID string `json:"current_id,omitempty"`
+	Present   bool   `json:"present"`
+	SizeBytes int64  `json:"size_bytes,omitempty"`
+}
+
+type appImagesResponse struct {
+	AppName string             `json:"appname"`
+	Images  []appImageResponse `json:"images"`
+}
+
+type imagePruneFailure struct {
+	Image string `json:"image"`
+	Error string `json:"error"`
+}
+
+// imagePruneResult reports a prune. In a dry run Removed lists what WOULD be
+// removed and nothing is touched.
+type imagePruneResult struct {
+	AppName string              `json:"appname"`
+	DryRun  bool                `json:"dry_run"`
+	Removed []string            `json:"removed"`
+	Failed  []imagePruneFailure `json:"failed,omitempty"`
+}
+
+func (s *Server) handleGetAppImages(w http.ResponseWriter, r *http.Request) {
+	appName := r.PathValue("appname")
+	if appName == "" {
+		writeAPIError(w, http.StatusBadRequest, "missing app name")
+		return
+	}
+
+	resp := appImagesResponse{AppName: appName, Images: []appImageResponse{}}
+	if s.cacheStore == nil {
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	for _, img := range s.cacheStore.AppImages(appName) {
+		item := appImageResponse{Ref: img.Ref, ID: img.ID}
+		if id, size, ok := s.pipeline.inspectImage(r.Context(), img.Ref); ok {
+			item.CurrentID = id
+			item.Present = true
+			item.SizeBytes = size
+		}
+		resp.Images = append(resp.Images, item)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handlePruneAppImages removes an app's images that nothing references any
+// more: all of them once the app is uninstalled, otherwise only the ones a
+// re-pulled tag left dangling. ?dry_run=true reports without removing.
+func (s *Server) handlePruneAppImages(w http.ResponseWriter, r *http.Request) {
+	appName := r.PathValue("appname")
+	if appName == "" {
+		writeAPIError(w, http.StatusBadRequest, "missing app name")
+		return
+	}
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+	if !s.queue.TryStart("prune-images", appName) {
+		writeAPIError(w, http.StatusConflict, "another operation is already running")
+		return
+	}
+	defer s.queue.FinishApp(appName)
+
+	var current []cache.AppImage
+	if s.pipeline.manifestExists(appName) && s.cacheStore != nil {
+		current = s.pipeline.resolveAppImages(r.Context(), imageRefs(s.cacheStore.AppImages(appName)))
+	}
+
+	result, record := s.pipeline.pruneAppImages(r.Context(), appName, current, dryRun)
+	if !dryRun {
+		s.pipeline.storeAppImages(appName, record)
+	}
+	outcome := opResultDone
+	if len(result.Failed) > 0 {
+		outcome = opResultError
+	}
+	s.queue.SetResult(appName, outcome, fmt.Sprintf("removed %d, kept %d", len(result.Removed), len(result.Failed)))
+	writeJSON(w, http.StatusOK, result)
+}
+
+// afterImageChange updates an app's tracked images once an update or
+// uninstall has succeeded and prunes the superseded ones according to the
+// configured mode. current is nil for an uninstall.
+func (p *installPipeline) afterImageChange(ctx context.Context, stream *sseStream, appname string, current []cache.AppImage) {
+	if p.cacheStore == nil {
+		return
+	}
+	mode := config.DefaultImagePrune
+	if p.configMgr != nil {
+		mode = p.configMgr.Get().ImagePrune
+	}
+	if mode == config.ImagePruneOff {
+		// Keep an uninstalled app's records: they are the only way a manual
+		// prune can later attribute those images to it.
+		if current != nil {
+			p.storeAppImages(appname, current)
+		}
+		return
+	}
+
+	result, record := p.pruneAppImages(ctx, appname, current, mode == config.ImagePruneDryRun)
+	p.storeAppImages(appname, record)
+	if len(result.Removed) == 0 {
+		return
+	}
+	msg := fmt.Sprintf("已清理 %d 个旧 Docker 镜像", len(result.Removed))
+	if result.DryRun {
+		msg = fmt.Sprintf("可清理 %d 个旧 Docker 镜像（预演模式，未删除）", len(result.Removed))
+	}
+	_ = stream.sendProgress(progressPayload{Step: "pruning", Message: msg})
+}
+
+// pruneAppImages removes the images appname previously recorded that are not
+// part of current and not referenced by any other app. It also returns what
+// appname's record should hold afterwards: current, plus the recorded images
+// the prune left on the system — all candidates in a dry run, the failures
+// otherwise — so a later prune can still attribute them to the app.
+//
+// Removal is never forced, so the daemon still refuses an image some container
+// uses; that is reported as a failure rather than overridden.
+func (p *installPipeline) pruneAppImages(ctx context.Context, appname string, current []cache.AppImage, dryRun bool) (imagePruneResult, []cache.AppImage) {
+	result := imagePruneResult{AppName: appname, DryRun: dryRun, Removed: []string{}}
+	if p.cacheStore == nil {
+		return result, current
+	}
+
+	all := p.cacheStore.AllAppImages()
+	previous := all[appname]
+	delete(all, appname)
+	candidates := supersededImages(previous, current, all)
+
+	if dryRun {
+		result.Removed = append(result.Removed, candidates...)
+		return result, retainedImages(previous, current, candidates)
+	}
+	if len(candidates) > 0 && p.docker == nil {
+		for _, img := range candidates {
+			result.Failed = append(result.Failed, imagePruneFailure{Image: img, Error: "docker 不可用"})
+		}
+		return result, retainedImages(previous, current, candidates)
+	}
+
+	for _, img := range candidates {
+		if err := p.docker.RemoveImage(ctx, img); err != nil {
+			if errors.Is(err, docker.ErrNotFound) {
+				// Already gone, typically because removing its last tag took
+				// the image with it.
+				continue
+			}
+			result.Failed = append(result.Failed, imagePruneFailure{Image: img, Error: err.Error()})
+			continue
+		}
+		result.Removed = append(result.Removed, img)
+	}
+	kept := make([]string, 0, len(result.Failed))
+	for _, f := range result.Failed {
+		kept = append(kept, f.Image)
+	}
+	if len(kept) > 0 {
+		log.Printf("pruneAppImages: %s: %d image(s) kept: %+v", appname, len(result.Failed), result.Failed)
+	}
+	return result, retainedImages(previous, current, kept)
+}
+
+// retainedImages returns current plus the previous records whose reference
+// or image ID is among kept.
+func retainedImages(previous, current []cache.AppImage, kept []string) []cache.AppImage {
+	images := slices.Clone(current)
+	for _, img := range previous {
+		if !slices.Contains(kept, img.Ref) && (img.ID == "" || !slices.Contains(kept, img.ID)) {
+			continue
+		}
+		if slices.Contains(images, img) {
+			continue
+		}
+		images = append(images, img)
+	}
+	return images
+}
+
+func (p *installPipeline) storeAppImages(appname string, current []cache.AppImage) {
+	if p.cacheStore == nil {
+		return
+	}
+	if len(current) == 0 {
+		p.cacheStore.RemoveAppImages(appname)
+		return
+	}
+	p.cacheStore.SetAppImages(appname, current)
+}
+
+// supersededImages picks which of an app's previously recorded images may be
+// removed: references it no longer uses, and image IDs that no reference in
+// use points at any more. Anything the current install or another app
+// records is kept. References come before IDs so a tag is untagged before its
+// image is deleted.
+func supersededImages(previous, current []cache.AppImage, others map[string][]cache.AppImage) []string {
+	keepRefs := make(map[string]bool)
+	keepIDs := make(map[string]bool)
+	mark := func(images []cache.AppImage) {
+		for _, img := range images {
+			keepRefs[img.Ref] = true
+			if img.ID != "" {
+				keepIDs[img.ID] = true
+			}
+		}
+	}
+	mark(current)
+	for _, images := range others {
+		mark(images)
+	}
+
+	var refs, ids []string
+	seen := make(map[string]bool)
+	for _, img := range previous {
+		if img.Ref != "" && !keepRefs[img.Ref] && !seen[img.Ref] {
+			seen[img.Ref] = true
+			refs = append(refs, img.Ref)
+		}
+		if img.ID != "" && !keepIDs[img.ID] && !seen[img.ID] {
+			seen[img.ID] = true
+			ids = append(ids, img.ID)
+		}
+	}
+	return append(refs, ids...)
+}
+
+// resolveAppImages records which image each reference currently points at.
+// A reference docker does not know keeps an empty ID.
+func (p *installPipeline) resolveAppImages(ctx context.Context, refs []string) []cache.AppImage {
+	images := make([]cache.AppImage, 0, len(refs))
+	seen := make(map[string]bool, len(refs))
+	for _, ref := range refs {
+		if ref == "" || seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		id, _, _ := p.inspectImage(ctx, ref)
+		images = append(images, cache.AppImage{Ref: ref, ID: id})
+	}
+	return images
+}
+
+func imageRefs(images []cache.AppImage) []string {
+	refs := make([]string, 0, len(images))
+	for _, img := range images {
+		refs = append(refs, img.Ref)
+	}
+	return refs
+}
+
+func (p *installPipeline) inspectImage(ctx context.Context, ref string) (id string, size int64, ok bool) {
+	if p.docker == nil {
+		return "", 0, false
+	}
+	img, err := p.docker.InspectImage(ctx, ref)
+	if err != nil {
+		return "", 0, false
+	}
+	return img.ID, img.Size, true
+}
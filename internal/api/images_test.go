@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"fnos-store/internal/cache"
+	"fnos-store/internal/config"
+)
+
+// TestSupersededImages locks which images a prune may touch. The store shares
+// the docker daemon with every other app on the box, so anything the new
+// install or another app still records must survive, and a tag that was
+// re-pulled must give up only the image ID it used to point at.
+func TestSupersededImages(t *testing.T) {
+	cases := []struct {
+		name     string
+		previous []cache.AppImage
+		current  []cache.AppImage
+		others   map[string][]cache.AppImage
+		want     []string
+	}{
+		{
+			name:     "update to a new tag drops the old tag and its image",
+			previous: []cache.AppImage{{Ref: "nginx:1.25", ID: "sha256:old"}},
+			current:  []cache.AppImage{{Ref: "nginx:1.27", ID: "sha256:new"}},
+			want:     []string{"nginx:1.25", "sha256:old"},
+		},
+		{
+			name:     "re-pulled tag keeps the tag but drops the image it left dangling",
+			previous: []cache.AppImage{{Ref: "app:latest", ID: "sha256:old"}},
+			current:  []cache.AppImage{{Ref: "app:latest", ID: "sha256:new"}},
+			want:     []string{"sha256:old"},
+		},
+		{
+			name:     "unchanged image is kept",
+			previous: []cache.AppImage{{Ref: "app:1", ID: "sha256:same"}},
+			current:  []cache.AppImage{{Ref: "app:1", ID: "sha256:same"}},
+			want:     nil,
+		},
+		{
+			name:     "uninstall drops everything the app recorded",
+			previous: []cache.AppImage{{Ref: "app:1", ID: "sha256:a"}, {Ref: "mirror/app:1", ID: "sha256:a"}},
+			want:     []string{"app:1", "mirror/app:1", "sha256:a"},
+		},
+		{
+			name:     "images another app records are never pruned",
+			previous: []cache.AppImage{{Ref: "redis:7", ID: "sha256:r"}, {Ref: "app:1", ID: "sha256:a"}},
+			others:   map[string][]cache.AppImage{"other": {{Ref: "redis:7", ID: "sha256:r"}}},
+			want:     []string{"app:1", "sha256:a"},
+		},
+		{
+			name:     "unresolved IDs are skipped rather than guessed",
+			previous: []cache.AppImage{{Ref: "app:1"}},
+			want:     []string{"app:1"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := supersededImages(tc.previous, tc.current, tc.others)
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("supersededImages() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestAfterImageChangeKeepsRecords checks that a prune which leaves images on
+// the system keeps them recorded: a dry run removes nothing, and a failed
+// removal must still be attributable to the app for a later prune.
+func TestAfterImageChangeKeepsRecords(t *testing.T) {
+	previous := []cache.AppImage{{Ref: "nginx:1.25", ID: "sha256:old"}}
+	current := []cache.AppImage{{Ref: "nginx:1.27", ID: "sha256:new"}}
+
+	for _, mode := range []string{config.ImagePruneDryRun, config.ImagePruneAuto} {
+		t.Run(mode, func(t *testing.T) {
+			store := cache.NewStore(t.TempDir())
+			store.SetAppImages("emby", previous)
+			cfgMgr := config.NewManager(t.TempDir())
+			cfg := cfgMgr.Get()
+			cfg.ImagePrune = mode
+			if err := cfgMgr.SaveConfig(cfg); err != nil {
+				t.Fatal(err)
+			}
+			// Without a docker daemon every removal fails.
+			p := &installPipeline{cacheStore: store, configMgr: cfgMgr}
+
+			stream, err := newSSEStream(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/apps/emby/update", nil), "emby")
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.afterImageChange(context.Background(), stream, "emby", current)
+			want := append(slices.Clone(current), previous...)
+			if got := store.AppImages("emby"); !slices.Equal(got, want) {
+				t.Errorf("recorded images = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// TestPutSettingsRejectsUnknownImagePrune guards against a typo of "off"
+// being saved as something that deletes images.
+func TestPutSettingsRejectsUnknownImagePrune(t *testing.T) {
+	cfgMgr := config.NewManager(t.TempDir())
+	s := &Server{configMgr: cfgMgr}
+	req := httptest.NewRequest(http.MethodPut, "/api/settings", strings.NewReader(`{"image_prune":"of"}`))
+	rec := httptest.NewRecorder()
+	s.handlePutSettings(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+	if got := cfgMgr.Get().ImagePrune; got != config.DefaultImagePrune {
+		t.Errorf("image_prune = %q after a rejected request", got)
+	}
+}
@@ -1,8 +1,10 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"strconv"
 
 	"fnos-store/internal/core"
 	"fnos-store/internal/platform"
@@ -30,6 +32,10 @@ func (s *Server) handleInstall(w http.ResponseWriter, r *http.Request) {
 		writeAPIError(w, http.StatusBadRequest, "应用已安装，请使用更新功能")
 		return
 	}
+	if app.IncompatibleReason != "" {
+		writeAPIError(w, http.StatusBadRequest, app.IncompatibleReason)
+		return
+	}
 
 	// Wizard answers ride along as a query param so the SSE POST body stays
 	// free; the browser sends them from the form rendered off /wizard.
@@ -64,7 +70,12 @@ func (s *Server) runInstallLikeOperation(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	s.pipeline.runStandard(r.Context(), stream, opName, app, params, s.refreshRegistry)
+	defer stream.recordResult(s.queue)
+
+	ctx, release := s.queue.Cancellable(r.Context(), appname)
+	defer release()
+	ctx = withDownloadLimit(ctx, parseDownloadLimit(r))
+	s.pipeline.runStandard(ctx, stream, opName, app, s.pipeline.newOpEnv(params), s.refreshRegistry)
 }
 
 func (s *Server) runSelfUpdate(w http.ResponseWriter, r *http.Request, app core.AppInfo) {
@@ -80,5 +91,34 @@ func (s *Server) runSelfUpdate(w http.ResponseWriter, r *http.Request, app core.
 		return
 	}
 
-	s.pipeline.runSelfUpdate(r.Context(), stream, app)
+	defer stream.recordResult(s.queue)
+
+	s.pipeline.runSelfUpdate(withDownloadLimit(r.Context(), parseDownloadLimit(r)), stream, app)
+}
+
+// parseDownloadLimit reads the operation's own download limit in KB/s from
+// ?limit_kbps=, in bytes per second. Absent or malformed input leaves only
+// the global limit.
+func parseDownloadLimit(r *http.Request) int64 {
+	kbps, err := strconv.ParseInt(r.URL.Query().Get("limit_kbps"), 10, 64)
+	if err != nil || kbps <= 0 {
+		return 0
+	}
+	return kbps * 1024
+}
+
+type downloadLimitKey struct{}
+
+// withDownloadLimit carries an operation's download limit, in bytes per
+// second, to the pipeline's downloads.
+func withDownloadLimit(ctx context.Context, bytesPerSec int64) context.Context {
+	if bytesPerSec <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, downloadLimitKey{}, bytesPerSec)
+}
+
+func downloadLimit(ctx context.Context) int64 {
+	limit, _ := ctx.Value(downloadLimitKey{}).(int64)
+	return limit
 }
@@ -0,0 +1,241 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Journal stages, recorded before each stage starts. The stages before
+// stageInstalling only fetch things; from it on the installed app is being
+// changed.
+const (
+	stageDownloading = "downloading"
+	stagePulling     = "pulling"
+	stageInstalling  = "installing"
+	stageVerifying   = "verifying"
+	stageStarting    = "starting"
+)
+
+// JournalEntry is the last recorded stage of an operation in progress.
+type JournalEntry struct {
+	AppName   string `json:"appname"`
+	Operation string `json:"operation"`
+	Stage     string `json:"stage"`
+	// TargetVersion is the fpk version the operation installs, ReleaseTag the
+	// release it comes from and Volume the volume it was pinned to, once
+	// known.
+	TargetVersion string    `json:"target_version,omitempty"`
+	ReleaseTag    string    `json:"release_tag,omitempty"`
+	Volume        int       `json:"volume,omitempty"`
+	StartedAt     time.Time `json:"started_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Journal is a write-ahead record of the operations in progress, kept on
+// disk so a store that dies mid-install (OOM, power loss, the self-update
+// kill) can tell on startup which operations never finished. An operation's
+// entry is removed once it ends, whatever the result.
+type Journal struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]JournalEntry // by app name
+}
+
+// NewJournal opens the journal at path, loading the entries a previous
+// process left behind. An unreadable journal is logged and starts empty.
+func NewJournal(path string) *Journal {
+	j := &Journal{path: path, entries: make(map[string]JournalEntry)}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("journal: read %s: %v", path, err)
+		}
+		return j
+	}
+	var entries []JournalEntry
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		log.Printf("journal: ignoring corrupt %s: %v", path, err)
+		return j
+	}
+	for _, e := range entries {
+		j.entries[e.AppName] = e
+	}
+	return j
+}
+
+// Record stores e as its app's current stage. It is on disk when Record
+// returns, so it must be called before the stage starts.
+func (j *Journal) Record(e JournalEntry) {
+	if j == nil {
+		return
+	}
+	e.UpdatedAt = time.Now()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries[e.AppName] = e
+	if err := j.persistLocked(); err != nil {
+		log.Printf("journal: record %s %s: %v", e.AppName, e.Stage, err)
+	}
+}
+
+// Remove drops appname's entry once its operation has ended.
+func (j *Journal) Remove(appname string) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, ok := j.entries[appname]; !ok {
+		return
+	}
+	delete(j.entries, appname)
+	if err := j.persistLocked(); err != nil {
+		log.Printf("journal: remove %s: %v", appname, err)
+	}
+}
+
+// Pending returns the recorded entries, oldest first.
+func (j *Journal) Pending() []JournalEntry {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.sortedLocked()
+}
+
+func (j *Journal) sortedLocked() []JournalEntry {
+	entries := slices.Collect(maps.Values(j.entries))
+	slices.SortFunc(entries, func(a, b JournalEntry) int {
+		return a.StartedAt.Compare(b.StartedAt)
+	})
+	return entries
+}
+
+// persistLocked replaces the journal file atomically and syncs it, so a
+// crash leaves either the old or the new journal, never a torn one.
+func (j *Journal) persistLocked() error {
+	if len(j.entries) == 0 {
+		if err := os.Remove(j.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	raw, err := json.MarshalIndent(j.sortedLocked(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0o755); err != nil {
+		return err
+	}
+	tmp := j.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(raw); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		return err
+	}
+	if dir, err := os.Open(filepath.Dir(j.path)); err == nil {
+		_ = dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
+// RecoverInterrupted reconciles the operations a previous process left
+// unfinished. The apps are claimed in the queue before it returns, so
+// nothing else touches them; the checks run in the background, as
+// verification can take close to a minute per app. Each outcome is recorded
+// in the history, and an operation that did not complete is listed in the
+// status for the user to resume.
+func (s *Server) RecoverInterrupted(ctx context.Context) {
+	var claimed []JournalEntry
+	for _, e := range s.pipeline.journal.Pending() {
+		if !s.queue.TryStart(e.Operation, e.AppName) {
+			continue
+		}
+		claimed = append(claimed, e)
+	}
+	if len(claimed) == 0 {
+		return
+	}
+	go func() {
+		for _, e := range claimed {
+			result, message := s.pipeline.reconcile(ctx, e)
+			log.Printf("journal: %s %s interrupted at %s: %s: %s", e.Operation, e.AppName, e.Stage, result, message)
+			s.queue.SetResult(e.AppName, result, message)
+			s.queue.FinishRecovered(e.AppName)
+			s.pipeline.journal.Remove(e.AppName)
+		}
+		_ = s.refreshRegistry(ctx)
+	}()
+}
+
+// reconcile works out how an interrupted operation ended. One stopped before
+// the install step left the app untouched; past it, the app is verified the
+// way a finishing operation would be.
+func (p *installPipeline) reconcile(ctx context.Context, e JournalEntry) (result, message string) {
+	switch e.Stage {
+	case stageDownloading, stagePulling:
+		return opResultInterrupted, "商店在下载阶段退出，应用未被改动，可重新执行该操作"
+	}
+
+	err := p.verifyInstalled(ctx, e.AppName)
+	if err == nil {
+		err = p.verifyPayloadLanded(ctx, e.AppName, e.Volume, e.TargetVersion)
+	}
+	if err != nil {
+		return opResultError, fmt.Sprintf("商店在安装过程中退出，重启后校验未通过，可重新执行该操作：%v", err)
+	}
+
+	if p.cacheStore != nil && e.ReleaseTag != "" {
+		p.cacheStore.SetInstalledTag(e.AppName, e.ReleaseTag)
+	}
+	message = "商店在安装过程中退出，重启后校验通过"
+	if e.Operation == "install" && e.Stage != stageStarting {
+		message += "，应用尚未启动"
+	}
+	return opResultDone, message
+}
+
+// handleResumeOperation runs the app's interrupted operation again, from the
+// start. Only an operation RecoverInterrupted found unfinished can be
+// resumed.
+func (s *Server) handleResumeOperation(w http.ResponseWriter, r *http.Request) {
+	appname := r.PathValue("appname")
+	rec, ok := s.queue.RecoveredOperation(appname)
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "no interrupted operation to resume")
+		return
+	}
+	switch rec.Operation {
+	case "install":
+		s.handleInstall(w, r)
+	case "update":
+		s.handleUpdate(w, r)
+	default:
+		writeAPIError(w, http.StatusBadRequest, "cannot resume "+rec.Operation)
+	}
+}
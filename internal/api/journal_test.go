@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournalSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.json")
+	j := NewJournal(path)
+	j.Record(JournalEntry{AppName: "gopeed", Operation: "update", Stage: stageDownloading, StartedAt: time.Now()})
+	j.Record(JournalEntry{AppName: "emby", Operation: "install", Stage: stageDownloading, StartedAt: time.Now()})
+	j.Record(JournalEntry{AppName: "gopeed", Operation: "update", Stage: stageInstalling, Volume: 2})
+	j.Remove("emby")
+
+	pending := NewJournal(path).Pending()
+	if len(pending) != 1 || pending[0].AppName != "gopeed" || pending[0].Stage != stageInstalling || pending[0].Volume != 2 {
+		t.Fatalf("reloaded journal = %+v, want gopeed at the install stage on vol2", pending)
+	}
+
+	j.Remove("gopeed")
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("empty journal left on disk: %v", err)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	const appName = "filebrowser"
+
+	// installedAt lays out an app whose manifest says version, with a payload
+	// on disk.
+	installedAt := func(t *testing.T, version string) string {
+		t.Helper()
+		root := t.TempDir()
+		appsDir := filepath.Join(root, "apps")
+		volDir := filepath.Join(root, "vol1", "@appcenter", appName)
+		for _, dir := range []string{filepath.Join(appsDir, appName), volDir} {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.WriteFile(filepath.Join(volDir, appName), []byte("binary"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(volDir, filepath.Join(appsDir, appName, "target")); err != nil {
+			t.Fatal(err)
+		}
+		manifest := "appname = " + appName + "\nversion = " + version + "\n"
+		if err := os.WriteFile(filepath.Join(appsDir, appName, "manifest"), []byte(manifest), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return appsDir
+	}
+
+	tests := []struct {
+		name      string
+		stage     string
+		installed string
+		want      string
+	}{
+		{"download never touched the app", stageDownloading, "2.63.18", opResultInterrupted},
+		{"update landed before the crash", stageVerifying, "2.63.19", opResultDone},
+		{"update did not land", stageInstalling, "2.63.18", opResultError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &stubAppCenter{checkScript: []stubCheckResult{{installed: true}}}
+			p := &installPipeline{queue: NewOperationQueue(), ac: stub, appsDir: installedAt(t, tt.installed)}
+			result, message := p.reconcile(context.Background(), JournalEntry{
+				AppName: appName, Operation: "update", Stage: tt.stage, TargetVersion: "2.63.19",
+			})
+			if result != tt.want {
+				t.Errorf("result = %s (%s), want %s", result, message, tt.want)
+			}
+			if tt.stage == stageDownloading && stub.nCheck != 0 {
+				t.Error("an operation stopped before installing was verified")
+			}
+		})
+	}
+}
+
+func TestRecoverInterruptedListsUnfinished(t *testing.T) {
+	journal := NewJournal(filepath.Join(t.TempDir(), "journal.json"))
+	journal.Record(JournalEntry{AppName: "gopeed", Operation: "install", Stage: stageDownloading})
+	queue := NewOperationQueue()
+	s := &Server{queue: queue, pipeline: &installPipeline{queue: queue, journal: journal}}
+
+	s.RecoverInterrupted(context.Background())
+	if queue.TryStart("install", "gopeed") {
+		t.Fatal("app not claimed while its interrupted operation is reconciled")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for queue.IsBusy() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	rec, ok := queue.RecoveredOperation("gopeed")
+	if !ok || rec.Operation != "install" || rec.Result != opResultInterrupted {
+		t.Fatalf("recovered = %+v, %v; want the interrupted install", rec, ok)
+	}
+	if len(journal.Pending()) != 0 {
+		t.Error("reconciled entry left in the journal")
+	}
+	if !queue.TryStart("install", "gopeed") {
+		t.Fatal("app still claimed after reconciliation")
+	}
+	if _, ok := queue.RecoveredOperation("gopeed"); ok {
+		t.Error("a new operation did not clear the recovered one")
+	}
+}
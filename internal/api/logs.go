@@ -6,11 +6,13 @@ import (
 	"fmt"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"unicode/utf8"
+
+	"fnos-store/internal/docker"
 )
 
 func (s *Server) handleGetAppLogs(w http.ResponseWriter, r *http.Request) {
@@ -25,12 +27,13 @@ func (s *Server) handleGetAppLogs(w http.ResponseWriter, r *http.Request) {
 		lines = 200
 	}
 
-	containers := s.findDockerContainers(appName)
-	tail, _, err := fetchAppLogTail(r.Context(), s.appsDir, appName, lines, 0)
+	containers := findDockerContainers(r.Context(), s.docker, s.appsDir, appName)
+	tail, _, err := fetchAppLogTail(r.Context(), s.docker, s.appsDir, appName, lines, 0)
 	if err != nil {
 		writeAPIError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	tail = s.redactorFor(appName).Redact(tail)
 
 	if len(containers) > 0 {
 		writeJSON(w, http.StatusOK, appLogsResponse{
@@ -58,43 +61,76 @@ func (s *Server) handleGetAppLogs(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func (s *Server) findDockerContainers(appName string) []string {
-	return findDockerContainers(s.appsDir, appName)
+// findDockerContainers lists the containers belonging to appName. Compose
+// labels are authoritative when the daemon is reachable, since they also
+// cover services without a container_name; the compose file is the fallback.
+func findDockerContainers(ctx context.Context, dc *docker.Client, appsDir, appName string) []string {
+	if dc != nil {
+		if list, err := dc.ListContainers(ctx, docker.LabelComposeProject); err == nil {
+			if names := composeContainersForApp(list, appsDir, appName); len(names) > 0 {
+				return names
+			}
+		}
+	}
+	return composeContainerNames(appsDir, appName)
 }
 
-func findDockerContainers(appsDir, appName string) []string {
-	composePaths := []string{
-		filepath.Join(appsDir, appName, "app", "docker", "docker-compose.yaml"),
-		filepath.Join(appsDir, appName, "app", "docker-compose.yaml"),
+// composeContainersForApp picks the containers whose compose project is
+// appName or whose project directory lives under the app's install directory.
+func composeContainersForApp(containers []docker.Container, appsDir, appName string) []string {
+	appDir := filepath.Join(appsDir, appName)
+	dirs := []string{appDir}
+	if resolved, err := filepath.EvalSymlinks(appDir); err == nil && resolved != appDir {
+		dirs = append(dirs, resolved)
 	}
 
-	for _, p := range composePaths {
-		data, err := os.ReadFile(p)
-		if err != nil {
+	var names []string
+	for _, c := range containers {
+		if !containerInApp(c, appName, dirs) {
 			continue
 		}
-		var containers []string
-		for line := range strings.SplitSeq(string(data), "\n") {
-			trimmed := strings.TrimSpace(line)
-			if name, ok := strings.CutPrefix(trimmed, "container_name:"); ok {
-				name := strings.TrimSpace(name)
-				name = strings.Trim(name, "\"' ")
-				if name != "" {
-					containers = append(containers, name)
-				}
-			}
+		if name := c.Name(); name != "" {
+			names = append(names, name)
 		}
-		if len(containers) > 0 {
-			return containers
+	}
+	slices.Sort(names)
+	return names
+}
+
+func containerInApp(c docker.Container, appName string, dirs []string) bool {
+	if c.Labels[docker.LabelComposeProject] == appName {
+		return true
+	}
+	workDir := c.Labels[docker.LabelComposeWorkingDir]
+	if workDir == "" {
+		return false
+	}
+	for _, dir := range dirs {
+		if workDir == dir || strings.HasPrefix(workDir, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func composeContainerNames(appsDir, appName string) []string {
+	_, services, err := readAppCompose(appsDir, appName, nil)
+	if err != nil {
+		return nil
+	}
+	var containers []string
+	for _, svc := range services {
+		if svc.ContainerName != "" {
+			containers = append(containers, svc.ContainerName)
 		}
 	}
-	return nil
+	return containers
 }
 
-func fetchAppLogTail(ctx context.Context, appsDir, app string, maxLines, maxBytes int) (tail string, truncated bool, err error) {
-	containers := findDockerContainers(appsDir, app)
+func fetchAppLogTail(ctx context.Context, dc *docker.Client, appsDir, app string, maxLines, maxBytes int) (tail string, truncated bool, err error) {
+	containers := findDockerContainers(ctx, dc, appsDir, app)
 	if len(containers) > 0 {
-		tail, truncated := fetchDockerLogTail(ctx, containers, maxLines, maxBytes)
+		tail, truncated := fetchDockerLogTail(ctx, dc, containers, maxLines, maxBytes)
 		return tail, truncated, nil
 	}
 
@@ -109,13 +145,11 @@ func fetchAppLogTail(ctx context.Context, appsDir, app string, maxLines, maxByte
 	return "", false, nil
 }
 
-func fetchDockerLogTail(ctx context.Context, containers []string, maxLines, maxBytes int) (string, bool) {
+func fetchDockerLogTail(ctx context.Context, dc *docker.Client, containers []string, maxLines, maxBytes int) (string, bool) {
 	var allLines []string
-	tail := strconv.Itoa(maxLines)
 
 	for _, cname := range containers {
-		cmd := exec.CommandContext(ctx, "docker", "logs", "--tail", tail, cname)
-		out, err := cmd.CombinedOutput()
+		lines, err := readContainerLogs(ctx, dc, cname, maxLines)
 		if err != nil {
 			allLines = append(allLines, fmt.Sprintf("=== [%s] 获取日志失败: %v ===", cname, err))
 			continue
@@ -123,16 +157,32 @@ func fetchDockerLogTail(ctx context.Context, containers []string, maxLines, maxB
 		if len(containers) > 1 {
 			allLines = append(allLines, fmt.Sprintf("=== [%s] ===", cname))
 		}
-		scanner := bufio.NewScanner(strings.NewReader(string(out)))
-		for scanner.Scan() {
-			allLines = append(allLines, scanner.Text())
-		}
+		allLines = append(allLines, lines...)
 	}
 
 	tailText := strings.Join(allLines, "\n")
 	return capTailBytes(tailText, maxBytes)
 }
 
+func readContainerLogs(ctx context.Context, dc *docker.Client, container string, maxLines int) ([]string, error) {
+	if dc == nil {
+		return nil, fmt.Errorf("docker 不可用")
+	}
+	rc, err := dc.Logs(ctx, container, docker.LogsOptions{Tail: maxLines})
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(rc)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
 func tailFileLog(data []byte, maxLines, maxBytes int) (string, bool, error) {
 	if len(data) == 0 {
 		return "", false, nil
@@ -199,63 +249,3 @@ func splitFileLogTail(tail string) []string {
 	}
 	return strings.Split(tail, "\n")
 }
-
-func (s *Server) serveDockerLogs(w http.ResponseWriter, appName string, containers []string, lines int) {
-	var allLines []string
-	tail := strconv.Itoa(lines)
-
-	for _, cname := range containers {
-		cmd := exec.Command("docker", "logs", "--tail", tail, cname)
-		out, err := cmd.CombinedOutput()
-		if err != nil {
-			allLines = append(allLines, fmt.Sprintf("=== [%s] 获取日志失败: %v ===", cname, err))
-			continue
-		}
-		if len(containers) > 1 {
-			allLines = append(allLines, fmt.Sprintf("=== [%s] ===", cname))
-		}
-		scanner := bufio.NewScanner(strings.NewReader(string(out)))
-		for scanner.Scan() {
-			allLines = append(allLines, scanner.Text())
-		}
-	}
-
-	writeJSON(w, http.StatusOK, appLogsResponse{
-		AppName:    appName,
-		LogLines:   allLines,
-		Source:     "docker",
-		Containers: containers,
-	})
-}
-
-func (s *Server) serveFileLogs(w http.ResponseWriter, appName string, lines int) {
-	logPaths := []string{
-		filepath.Join(s.appsDir, appName, "var", appName+".log"),
-		filepath.Join("/var/log/apps", appName+".log"),
-	}
-
-	for _, logPath := range logPaths {
-		data, err := os.ReadFile(logPath)
-		if err != nil {
-			continue
-		}
-
-		allLines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
-		if len(allLines) > lines {
-			allLines = allLines[len(allLines)-lines:]
-		}
-
-		writeJSON(w, http.StatusOK, appLogsResponse{
-			AppName:  appName,
-			LogLines: allLines,
-			Source:   "file",
-		})
-		return
-	}
-
-	writeJSON(w, http.StatusOK, appLogsResponse{
-		AppName:  appName,
-		LogLines: []string{},
-		Source:   "none",
-	})
-}
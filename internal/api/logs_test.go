@@ -4,8 +4,11 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 	"unicode/utf8"
+
+	"fnos-store/internal/docker"
 )
 
 func TestFetchAppLogTail(t *testing.T) {
@@ -77,7 +80,7 @@ func TestFetchAppLogTail(t *testing.T) {
 				}
 			}
 
-			gotTail, gotTruncated, err := fetchAppLogTail(ctx, appsDir, tc.app, tc.maxLines, tc.maxBytes)
+			gotTail, gotTruncated, err := fetchAppLogTail(ctx, nil, appsDir, tc.app, tc.maxLines, tc.maxBytes)
 			if err != nil {
 				t.Fatalf("fetchAppLogTail returned error: %v", err)
 			}
@@ -97,6 +100,30 @@ func TestFetchAppLogTail(t *testing.T) {
 	}
 }
 
+func TestComposeContainersForApp(t *testing.T) {
+	t.Parallel()
+
+	appsDir := t.TempDir()
+	containers := []docker.Container{
+		{Names: []string{"/demo-web-1"}, Labels: map[string]string{docker.LabelComposeProject: "demo"}},
+		{Names: []string{"/demo-db"}, Labels: map[string]string{
+			docker.LabelComposeProject:    "docker",
+			docker.LabelComposeWorkingDir: filepath.Join(appsDir, "demo", "app", "docker"),
+		}},
+		{Names: []string{"/demo2-web"}, Labels: map[string]string{
+			docker.LabelComposeProject:    "docker",
+			docker.LabelComposeWorkingDir: filepath.Join(appsDir, "demo2", "app", "docker"),
+		}},
+		{Names: []string{"/other"}, Labels: map[string]string{docker.LabelComposeProject: "other"}},
+	}
+
+	got := composeContainersForApp(containers, appsDir, "demo")
+	want := []string{"demo-db", "demo-web-1"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("composeContainersForApp() = %v, want %v", got, want)
+	}
+}
+
 func strPtr(s string) *string {
 	return &s
 }
@@ -0,0 +1,426 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"fnos-store/internal/docker"
+)
+
+const (
+	// logStreamBuffer bounds how many lines may wait for a slow client.
+	// Producers block once it is full, which in turn stops reading from the
+	// daemon or the file, so a slow client costs no memory beyond this.
+	logStreamBuffer = 256
+	// logStreamWriteTimeout is how long one event may take to reach the
+	// client before the stream is dropped.
+	logStreamWriteTimeout = 15 * time.Second
+	logStreamHeartbeat    = 15 * time.Second
+	logFilePollInterval   = 500 * time.Millisecond
+	// logFileChunk is how much of a log file is read at a time, and
+	// maxLogFileTailBytes how far back from its end the initial tail looks,
+	// so a multi-GB log costs no more memory than a small one.
+	logFileChunk        = 64 << 10
+	maxLogFileTailBytes = 4 << 20
+	// maxLogLineBytes bounds a line without a newline; a longer one is
+	// emitted as it is.
+	maxLogLineBytes = 1 << 20
+)
+
+type logStreamLine struct {
+	Container string `json:"container,omitempty"`
+	Line      string `json:"line"`
+}
+
+type logStreamStatus struct {
+	Source     string   `json:"source"`
+	Containers []string `json:"containers,omitempty"`
+	Container  string   `json:"container,omitempty"`
+	Message    string   `json:"message,omitempty"`
+}
+
+// handleStreamAppLogs follows an app's logs over SSE: every container compose
+// started for it, or else its log file. ?tail=N sets how much history comes
+// first (default 100), ?grep= keeps only matching lines (case-insensitive;
+// a regular expression when ?regex=true).
+//
+// Events: "status" once with the source, "log" per line, and "status" again
+// with a message when a container's stream ends or reading fails. The stream
+// runs until the client disconnects or every container stream has ended.
+func (s *Server) handleStreamAppLogs(w http.ResponseWriter, r *http.Request) {
+	appName := r.PathValue("appname")
+	if appName == "" {
+		writeAPIError(w, http.StatusBadRequest, "missing app name")
+		return
+	}
+
+	tail := 100
+	if v := r.URL.Query().Get("tail"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeAPIError(w, http.StatusBadRequest, "invalid tail")
+			return
+		}
+		tail = n
+	}
+	regex, _ := strconv.ParseBool(r.URL.Query().Get("regex"))
+	match, err := logLineMatcher(r.URL.Query().Get("grep"), regex)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid grep: "+err.Error())
+		return
+	}
+	// Redact before matching, so grep cannot be used to probe for a secret.
+	red := s.redactorFor(appName)
+	filter := func(line string) (string, bool) {
+		line = red.Redact(line)
+		return line, match(line)
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	containers := findDockerContainers(ctx, s.docker, s.appsDir, appName)
+	logPath := firstExistingLogPath(s.appsDir, appName)
+
+	stream, err := newSSEStream(w, r, appName)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	rc := http.NewResponseController(w)
+	send := func(event string, payload any) error {
+		_ = rc.SetWriteDeadline(time.Now().Add(logStreamWriteTimeout))
+		return stream.sendEvent(event, payload)
+	}
+
+	lines := make(chan logStreamLine, logStreamBuffer)
+	statuses := make(chan logStreamStatus, 1)
+	var producers sync.WaitGroup
+
+	switch {
+	case len(containers) > 0 && s.docker != nil:
+		if err := send("status", logStreamStatus{Source: "docker", Containers: containers}); err != nil {
+			return
+		}
+		for _, name := range containers {
+			producers.Add(1)
+			go func() {
+				defer producers.Done()
+				err := followContainerLogs(ctx, s.docker, name, tail, filter, lines)
+				msg := "容器日志流已结束"
+				if err != nil && ctx.Err() == nil {
+					msg = fmt.Sprintf("获取日志失败: %v", err)
+				}
+				select {
+				case statuses <- logStreamStatus{Source: "docker", Container: name, Message: msg}:
+				case <-ctx.Done():
+				}
+			}()
+		}
+	case logPath != "":
+		if err := send("status", logStreamStatus{Source: "file"}); err != nil {
+			return
+		}
+		producers.Add(1)
+		go func() {
+			defer producers.Done()
+			err := followLogFile(ctx, logPath, tail, logFilePollInterval, func(line string) error {
+				line, ok := filter(line)
+				if !ok {
+					return nil
+				}
+				select {
+				case lines <- logStreamLine{Line: line}:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+			if err != nil && ctx.Err() == nil {
+				select {
+				case statuses <- logStreamStatus{Source: "file", Message: fmt.Sprintf("读取日志失败: %v", err)}:
+				case <-ctx.Done():
+				}
+			}
+		}()
+	default:
+		_ = send("status", logStreamStatus{Source: "none", Message: "未找到该应用的日志"})
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		producers.Wait()
+		close(done)
+	}()
+
+	heartbeat := time.NewTicker(logStreamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case line := <-lines:
+			if err := send("log", line); err != nil {
+				return
+			}
+		case st := <-statuses:
+			if err := send("status", st); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			_ = rc.SetWriteDeadline(time.Now().Add(logStreamWriteTimeout))
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+			stream.flusher.Flush()
+		case <-done:
+			// Drain what the producers queued before they finished.
+			for {
+				select {
+				case line := <-lines:
+					if err := send("log", line); err != nil {
+						return
+					}
+				case st := <-statuses:
+					if err := send("status", st); err != nil {
+						return
+					}
+				default:
+					return
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// logLineMatcher builds the ?grep= filter. An empty pattern matches every
+// line.
+func logLineMatcher(pattern string, regex bool) (func(string) bool, error) {
+	if pattern == "" {
+		return func(string) bool { return true }, nil
+	}
+	if !regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, err
+	}
+	return re.MatchString, nil
+}
+
+func firstExistingLogPath(appsDir, appName string) string {
+	for _, p := range appLogPaths(appsDir, appName) {
+		if info, err := os.Stat(p); err == nil && info.Mode().IsRegular() {
+			return p
+		}
+	}
+	return ""
+}
+
+func followContainerLogs(ctx context.Context, dc *docker.Client, name string, tail int, filter func(string) (string, bool), out chan<- logStreamLine) error {
+	opts := docker.LogsOptions{Tail: tail, Follow: true}
+	if tail == 0 {
+		// Tail 0 means the whole log to the daemon; no history means "from
+		// now on".
+		opts.Since = time.Now()
+	}
+	rc, err := dc.Logs(ctx, name, opts)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	scanner := bufio.NewScanner(rc)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line, ok := filter(scanner.Text())
+		if !ok {
+			continue
+		}
+		select {
+		case out <- logStreamLine{Container: name, Line: line}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return scanner.Err()
+}
+
+// followLogFile emits the last tail lines of path, then every line appended
+// to it, until ctx ends. It polls rather than relying on inotify, which is
+// not available everywhere the store runs. A file that is replaced (rotated)
+// or truncated is reopened and read from the start. A trailing line without a
+// newline is held back until it is completed.
+func followLogFile(ctx context.Context, path string, tail int, interval time.Duration, emit func(string) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	offset := info.Size()
+	start, err := tailLinesOffset(f, offset, tail)
+	if err != nil {
+		return err
+	}
+	data := make([]byte, offset-start)
+	if _, err := f.ReadAt(data, start); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	complete, partial := splitCompleteLines(data)
+	if tail > 0 && len(complete) > tail {
+		complete = complete[len(complete)-tail:]
+	} else if tail == 0 {
+		complete = nil
+	}
+	for _, line := range complete {
+		if err := emit(line); err != nil {
+			return err
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		current, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		latest, err := os.Stat(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			// Between the rename and the new file appearing; keep reading
+			// what is left of the old one.
+		case err != nil:
+			return err
+		case !os.SameFile(current, latest) || latest.Size() < offset:
+			// Finish the old file before switching: a writer may have
+			// appended after our last poll and before rotating.
+			if _, err := emitAppendedFrom(f, offset, &partial, emit); err != nil {
+				return err
+			}
+			if partial != nil {
+				if err := emit(string(partial)); err != nil {
+					return err
+				}
+				partial = nil
+			}
+			next, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			f.Close()
+			f = next
+			offset = 0
+		}
+
+		n, err := emitAppendedFrom(f, offset, &partial, emit)
+		if err != nil {
+			return err
+		}
+		offset += n
+	}
+}
+
+// tailLinesOffset returns where the last n complete lines of f, which is size
+// bytes long, begin. It reads backwards a chunk at a time and looks back at
+// most maxLogFileTailBytes; when that cuts the lines short, they start at the
+// first whole line within it.
+func tailLinesOffset(f *os.File, size int64, n int) (int64, error) {
+	limit := max(size-maxLogFileTailBytes, 0)
+	buf := make([]byte, logFileChunk)
+	// The newline ending the last complete line is counted too, so n+1
+	// newlines back is where those lines start.
+	newlines := 0
+	firstLine := limit
+	for end := size; end > limit; {
+		start := max(end-logFileChunk, limit)
+		chunk := buf[:end-start]
+		if _, err := f.ReadAt(chunk, start); err != nil && !errors.Is(err, io.EOF) {
+			return 0, err
+		}
+		for i := len(chunk) - 1; i >= 0; i-- {
+			if chunk[i] != '\n' {
+				continue
+			}
+			if newlines++; newlines > n {
+				return start + int64(i) + 1, nil
+			}
+			firstLine = start + int64(i) + 1
+		}
+		end = start
+	}
+	if limit == 0 {
+		return 0, nil
+	}
+	return firstLine, nil
+}
+
+// emitAppendedFrom emits the complete lines written to f after offset and
+// returns how many bytes it consumed. It reads a chunk at a time, however
+// much was appended.
+func emitAppendedFrom(f *os.File, offset int64, partial *[]byte, emit func(string) error) (int64, error) {
+	buf := make([]byte, logFileChunk)
+	var consumed int64
+	for {
+		n, err := f.ReadAt(buf, offset+consumed)
+		if n > 0 {
+			consumed += int64(n)
+			complete, rest := splitCompleteLines(append(*partial, buf[:n]...))
+			if len(rest) > maxLogLineBytes {
+				complete, rest = append(complete, string(rest)), nil
+			}
+			*partial = rest
+			for _, line := range complete {
+				if err := emit(line); err != nil {
+					return consumed, err
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return consumed, nil
+		}
+		if err != nil {
+			return consumed, err
+		}
+	}
+}
+
+// splitCompleteLines splits data into newline-terminated lines (without the
+// newline) and whatever follows the last newline.
+func splitCompleteLines(data []byte) (lines []string, rest []byte) {
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		lines = append(lines, string(bytes.TrimSuffix(data[:i], []byte("\r"))))
+		data = data[i+1:]
+	}
+	if len(data) > 0 {
+		rest = append([]byte(nil), data...)
+	}
+	return lines, rest
+}
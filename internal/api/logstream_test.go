@@ -0,0 +1,152 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestFollowLogFileHandlesRotation appends, rotates by rename and truncates
+// the file under a running follower, which must see every complete line
+// exactly once.
+func TestFollowLogFileHandlesRotation(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte("old1\nold2\nold3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lines := make(chan string, 64)
+	done := make(chan error, 1)
+	go func() {
+		done <- followLogFile(ctx, path, 2, 5*time.Millisecond, func(line string) error {
+			lines <- line
+			return nil
+		})
+	}()
+
+	var got []string
+	waitFor := func(want ...string) {
+		t.Helper()
+		deadline := time.After(2 * time.Second)
+		for len(got) < len(want) {
+			select {
+			case line := <-lines:
+				got = append(got, line)
+			case <-deadline:
+				t.Fatalf("timed out: got %q, want %q", got, want)
+			}
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("lines = %q, want %q", got, want)
+		}
+	}
+	appendTo := func(s string) {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	waitFor("old2", "old3")
+
+	appendTo("new1\npart")
+	waitFor("old2", "old3", "new1")
+	appendTo("ial\n")
+	waitFor("old2", "old3", "new1", "partial")
+
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendTo("rotated1\n")
+	waitFor("old2", "old3", "new1", "partial", "rotated1")
+
+	if err := os.WriteFile(path, []byte("t1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("old2", "old3", "new1", "partial", "rotated1", "t1")
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("followLogFile: %v", err)
+	}
+}
+
+func TestLogLineMatcher(t *testing.T) {
+	t.Parallel()
+
+	literal, err := logLineMatcher("a.b", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !literal("X A.B y") || literal("axb") {
+		t.Error("literal grep should match case-insensitively and treat . literally")
+	}
+
+	re, err := logLineMatcher(`^err(or)?\b`, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !re("ERROR: boom") || re("no error") {
+		t.Error("regex grep should be anchored as written")
+	}
+
+	if _, err := logLineMatcher("(", true); err == nil {
+		t.Error("invalid regex should be rejected")
+	}
+}
+
+// TestFollowLogFileReadsInChunks covers a log far larger than one read: the
+// initial tail comes from the end of the file and an append spanning several
+// chunks arrives whole.
+func TestFollowLogFileReadsInChunks(t *testing.T) {
+	t.Parallel()
+
+	var content strings.Builder
+	for i := range 20000 {
+		fmt.Fprintf(&content, "line %d\n", i)
+	}
+	content.WriteString("partial")
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte(content.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	offset, err := tailLinesOffset(f, int64(content.Len()), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := content.String()[offset:]; got != "line 19997\nline 19998\nline 19999\npartial" {
+		t.Errorf("tail = %q", got)
+	}
+
+	partial := []byte("held ")
+	var lines []string
+	n, err := emitAppendedFrom(f, 0, &partial, func(line string) error {
+		lines = append(lines, line)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(content.Len()) || len(lines) != 20000 || lines[0] != "held line 0" || lines[19999] != "line 19999" || string(partial) != "partial" {
+		t.Errorf("read %d bytes, %d lines (first %q), partial %q", n, len(lines), lines[0], partial)
+	}
+}
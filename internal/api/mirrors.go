@@ -2,12 +2,16 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"fnos-store/internal/config"
+	"fnos-store/internal/scheduler"
 )
 
 type mirrorCheckResult struct {
@@ -24,6 +28,18 @@ type mirrorCheckResponse struct {
 
 const checkTimeout = 5 * time.Second
 
+// mirrorCheckAsset is fetched through each GitHub mirror by the on-demand
+// check.
+const mirrorCheckAsset = "https://github.com/jqlang/jq/releases/download/jq-1.7.1/jq-linux-amd64"
+
+// dockerRegistryCheckURL is the registry API root behind a Docker mirror
+// prefix; a path-style prefix such as "harbor.lan/cache/" is served by the
+// registry at its host.
+func dockerRegistryCheckURL(prefix string) string {
+	host, _, _ := strings.Cut(prefix, "/")
+	return fmt.Sprintf("https://%s/v2/", host)
+}
+
 func (s *Server) handleCheckMirrors(w http.ResponseWriter, r *http.Request) {
 	checkType := r.URL.Query().Get("type") // "github", "docker", or "" (both)
 
@@ -38,7 +54,7 @@ func (s *Server) handleCheckMirrors(w http.ResponseWriter, r *http.Request) {
 	skipGH := checkType == "docker"
 	skipDK := checkType == "github"
 
-	total := len(ghMirrors) + len(dkMirrors)
+	total := len(ghMirrors) + len(dkMirrors) + len(cfg.CustomGitHubMirrorList()) + len(cfg.CustomDockerMirrorList())
 	type indexedResult struct {
 		index  int
 		result mirrorCheckResult
@@ -51,14 +67,14 @@ func (s *Server) handleCheckMirrors(w http.ResponseWriter, r *http.Request) {
 	// Check GitHub mirrors concurrently
 	if !skipGH {
 		for i, m := range ghMirrors {
-			if m.Key == "direct" || m.Key == "custom" {
+			if m.Key == "direct" || m.Key == "custom" || m.Key == config.MirrorAuto || m.Retired {
 				continue
 			}
 			wg.Add(1)
 			go func(idx int, mirror config.GitHubMirror) {
 				defer wg.Done()
-				testURL := mirror.URL + "https://github.com/jqlang/jq/releases/download/jq-1.7.1/jq-linux-amd64"
-				latency, status := checkURL(r.Context(), testURL)
+				testURL := config.MirrorURL(mirror.URL, mirrorCheckAsset)
+				latency, status := checkURL(r.Context(), s.outboundClient(), testURL)
 				results <- indexedResult{
 					index:  idx,
 					isGH:   true,
@@ -68,32 +84,32 @@ func (s *Server) handleCheckMirrors(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Check custom GitHub mirror if configured
-	if !skipGH && cfg.CustomGitHubMirror != "" {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			testURL := cfg.CustomGitHubMirror + "https://github.com/jqlang/jq/releases/download/jq-1.7.1/jq-linux-amd64"
-			latency, status := checkURL(r.Context(), testURL)
-			results <- indexedResult{
-				index:  -1,
-				isGH:   true,
-				result: mirrorCheckResult{Key: "custom", Label: "自定义", LatencyMs: latency, Status: status},
-			}
-		}()
+	// Check the enabled custom GitHub mirrors
+	if !skipGH {
+		for _, m := range cfg.CustomGitHubMirrorList() {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				latency, status := checkURL(r.Context(), s.outboundClient(), config.MirrorURL(m.URL, mirrorCheckAsset))
+				results <- indexedResult{
+					index:  -1,
+					isGH:   true,
+					result: mirrorCheckResult{Key: m.Key, Label: m.Label, LatencyMs: latency, Status: status},
+				}
+			}()
+		}
 	}
 
 	// Check Docker mirrors concurrently
 	if !skipDK {
 		for i, m := range dkMirrors {
-			if m.Key == "direct" || m.Key == "custom" {
+			if m.Key == "direct" || m.Key == "custom" || m.Retired {
 				continue
 			}
 			wg.Add(1)
 			go func(idx int, mirror config.DockerMirror) {
 				defer wg.Done()
-				testURL := fmt.Sprintf("https://%sv2/", mirror.URL)
-				latency, status := checkURL(r.Context(), testURL)
+				latency, status := checkURL(r.Context(), s.outboundClient(), dockerRegistryCheckURL(mirror.URL))
 				results <- indexedResult{
 					index:  idx,
 					isGH:   false,
@@ -103,19 +119,20 @@ func (s *Server) handleCheckMirrors(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Check custom Docker mirror if configured
-	if !skipDK && cfg.CustomDockerMirror != "" {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			testURL := fmt.Sprintf("https://%sv2/", cfg.CustomDockerMirror)
-			latency, status := checkURL(r.Context(), testURL)
-			results <- indexedResult{
-				index:  -1,
-				isGH:   false,
-				result: mirrorCheckResult{Key: "custom", Label: "自定义", LatencyMs: latency, Status: status},
-			}
-		}()
+	// Check the enabled custom Docker mirrors
+	if !skipDK {
+		for _, m := range cfg.CustomDockerMirrorList() {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				latency, status := checkURL(r.Context(), s.outboundClient(), dockerRegistryCheckURL(m.URL))
+				results <- indexedResult{
+					index:  -1,
+					isGH:   false,
+					result: mirrorCheckResult{Key: m.Key, Label: m.Label, LatencyMs: latency, Status: status},
+				}
+			}()
+		}
 	}
 
 	go func() {
@@ -135,6 +152,12 @@ func (s *Server) handleCheckMirrors(w http.ResponseWriter, r *http.Request) {
 	if ghResults == nil {
 		ghResults = []mirrorCheckResult{}
 	}
+	// The on-demand check feeds the auto mode's latency scores too.
+	probes := make([]mirrorProbe, 0, len(ghResults))
+	for _, r := range ghResults {
+		probes = append(probes, mirrorProbe{key: r.Key, ok: r.Status == "ok", latency: time.Duration(r.LatencyMs) * time.Millisecond})
+	}
+	s.recordMirrorProbes(probes)
 	if dkResults == nil {
 		dkResults = []mirrorCheckResult{}
 	}
@@ -145,7 +168,7 @@ func (s *Server) handleCheckMirrors(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func checkURL(parent context.Context, url string) (latencyMs int, status string) {
+func checkURL(parent context.Context, client *http.Client, url string) (latencyMs int, status string) {
 	ctx, cancel := context.WithTimeout(parent, checkTimeout)
 	defer cancel()
 
@@ -155,7 +178,7 @@ func checkURL(parent context.Context, url string) (latencyMs int, status string)
 	}
 
 	start := time.Now()
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := client.Do(req)
 	elapsed := time.Since(start)
 
 	if err != nil {
@@ -169,3 +192,188 @@ func checkURL(parent context.Context, url string) (latencyMs int, status string)
 	// Any response (even 401/403) proves the mirror is reachable
 	return int(elapsed.Milliseconds()), "ok"
 }
+
+// outboundClient is the client for requests leaving the NAS.
+func (s *Server) outboundClient() *http.Client {
+	if s.httpClient == nil {
+		return http.DefaultClient
+	}
+	return s.httpClient
+}
+
+// catalogProbeURL is benchmarked when the catalog offers no package yet.
+const catalogProbeURL = "https://raw.githubusercontent.com/conversun/fnos-apps/main/apps.json"
+
+// Throughput probes read at most mirrorProbeBytes, within mirrorProbeTimeout.
+const (
+	mirrorProbeBytes   = 256 << 10
+	mirrorProbeTimeout = 15 * time.Second
+)
+
+// mirrorProbe is one benchmark of one GitHub mirror. kbps is 0 when only the
+// latency was measured.
+type mirrorProbe struct {
+	key     string
+	ok      bool
+	latency time.Duration
+	kbps    float64
+}
+
+type mirrorScoreResponse struct {
+	Key            string  `json:"key"`
+	Label          string  `json:"label"`
+	Success        float64 `json:"success"`
+	LatencyMs      int     `json:"latency_ms"`
+	ThroughputKBps int     `json:"throughput_kbps"`
+	Samples        int     `json:"samples"`
+	CheckedAt      string  `json:"checked_at,omitempty"`
+}
+
+type mirrorScoresResponse struct {
+	// Auto is whether the store currently picks mirrors by score. Mirrors
+	// are listed in the order auto mode tries them either way.
+	Auto    bool                  `json:"auto"`
+	Mirrors []mirrorScoreResponse `json:"mirrors"`
+}
+
+func (s *Server) handleGetMirrorScores(w http.ResponseWriter, _ *http.Request) {
+	var cfg config.Config
+	if s.configMgr != nil {
+		cfg = s.configMgr.Get()
+	}
+	scores := config.MirrorScores()
+	resp := mirrorScoresResponse{Auto: cfg.Mirror == config.MirrorAuto, Mirrors: []mirrorScoreResponse{}}
+	for _, m := range config.RankedMirrors(cfg) {
+		sc := scores[m.ScoreKey()]
+		resp.Mirrors = append(resp.Mirrors, mirrorScoreResponse{
+			Key:            m.Key,
+			Label:          m.Label,
+			Success:        sc.Success,
+			LatencyMs:      int(sc.LatencyMs),
+			ThroughputKBps: int(sc.ThroughputKBps),
+			Samples:        sc.Samples,
+			CheckedAt:      formatTimestamp(sc.CheckedAt),
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// runMirrorBenchmark is the scheduled benchmark behind the auto mirror mode.
+// It only runs while that mode is selected.
+func (s *Server) runMirrorBenchmark(ctx context.Context) error {
+	var cfg config.Config
+	if s.configMgr != nil {
+		cfg = s.configMgr.Get()
+	}
+	if cfg.Mirror != config.MirrorAuto {
+		return fmt.Errorf("%w: mirror mode is not auto", scheduler.ErrSkipped)
+	}
+	return s.benchmarkMirrors(ctx, cfg)
+}
+
+// benchmarkMirrors probes every GitHub mirror concurrently with a ranged
+// download of a real asset and folds the results into the scores.
+func (s *Server) benchmarkMirrors(ctx context.Context, cfg config.Config) error {
+	asset := s.probeAssetURL()
+	mirrors := config.BenchmarkMirrors(cfg)
+	probes := make([]mirrorProbe, len(mirrors))
+
+	var wg sync.WaitGroup
+	for i, m := range mirrors {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			probes[i] = probeMirror(ctx, s.outboundClient(), config.MirrorURL(m.URL, asset))
+			probes[i].key = m.ScoreKey()
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.recordMirrorProbes(probes)
+	for _, p := range probes {
+		if p.ok {
+			return nil
+		}
+	}
+	return errors.New("every mirror failed the benchmark")
+}
+
+// probeAssetURL picks what to benchmark with: a package from the catalog, as
+// some mirrors only proxy the catalog's own repository, else the catalog.
+func (s *Server) probeAssetURL() string {
+	for _, app := range s.listRegistryApps() {
+		if app.DownloadURL != "" {
+			return app.DownloadURL
+		}
+	}
+	return catalogProbeURL
+}
+
+// probeMirror downloads the first mirrorProbeBytes of url, timing the
+// response headers (latency) and the body (throughput).
+func probeMirror(parent context.Context, client *http.Client, url string) mirrorProbe {
+	ctx, cancel := context.WithTimeout(parent, mirrorProbeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return mirrorProbe{}
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", mirrorProbeBytes-1))
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return mirrorProbe{}
+	}
+	defer resp.Body.Close()
+	latency := time.Since(start)
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return mirrorProbe{}
+	}
+
+	bodyStart := time.Now()
+	n, err := io.Copy(io.Discard, io.LimitReader(resp.Body, mirrorProbeBytes))
+	if err != nil || n == 0 {
+		return mirrorProbe{}
+	}
+	elapsed := time.Since(bodyStart).Seconds()
+	if elapsed <= 0 {
+		elapsed = 0.001
+	}
+	return mirrorProbe{ok: true, latency: latency, kbps: float64(n) / 1024 / elapsed}
+}
+
+// recordMirrorProbes folds probes into the mirror scores and persists them.
+func (s *Server) recordMirrorProbes(probes []mirrorProbe) {
+	s.mirrorScoreMu.Lock()
+	defer s.mirrorScoreMu.Unlock()
+
+	scores := config.MirrorScores()
+	if scores == nil {
+		scores = make(map[string]config.MirrorScore)
+	}
+	now := time.Now()
+	for _, p := range probes {
+		scores[p.key] = scores[p.key].Update(p.ok, p.latency, p.kbps, now)
+	}
+	config.SetMirrorScores(scores)
+	if s.cacheStore != nil {
+		s.cacheStore.SetMirrorScores(scores)
+	}
+}
+
+// lastMirrorBenchmark is when the scores were last updated, so a benchmark
+// missed while the store was down runs at start.
+func lastMirrorBenchmark() time.Time {
+	var last time.Time
+	for _, sc := range config.MirrorScores() {
+		if sc.CheckedAt.After(last) {
+			last = sc.CheckedAt
+		}
+	}
+	return last
+}
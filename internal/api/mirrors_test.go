@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"fnos-store/internal/cache"
+	"fnos-store/internal/config"
+	"fnos-store/internal/scheduler"
+)
+
+func TestProbeMirror(t *testing.T) {
+	payload := strings.Repeat("x", mirrorProbeBytes*2)
+	var gotRange string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		gotRange = r.Header.Get("Range")
+		_, _ = w.Write([]byte(payload))
+	}))
+	defer srv.Close()
+
+	p := probeMirror(context.Background(), http.DefaultClient, srv.URL+"/asset")
+	if !p.ok || p.kbps <= 0 || p.latency <= 0 {
+		t.Errorf("probe = %+v, want a successful measurement", p)
+	}
+	if gotRange != "bytes=0-262143" {
+		t.Errorf("Range = %q", gotRange)
+	}
+	if p := probeMirror(context.Background(), http.DefaultClient, srv.URL+"/missing"); p.ok {
+		t.Error("a 404 counted as a working mirror")
+	}
+}
+
+func TestMirrorScoresFeedAutoOrder(t *testing.T) {
+	t.Cleanup(func() { config.SetMirrorScores(nil) })
+	config.SetMirrorScores(nil)
+
+	dir := t.TempDir()
+	store := cache.NewStore(dir)
+	if err := store.Init(); err != nil {
+		t.Fatal(err)
+	}
+	cfgMgr := config.NewManager(dir)
+	if err := cfgMgr.SaveConfig(config.Config{Mirror: config.MirrorAuto}); err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{configMgr: cfgMgr, cacheStore: store}
+
+	s.recordMirrorProbes([]mirrorProbe{
+		{key: "ghfast", ok: true, latency: 80 * time.Millisecond, kbps: 3000},
+		{key: "conversun", ok: false},
+	})
+	if got := store.MirrorScores(); got["ghfast"].Samples != 1 || got["conversun"].Success != 0 {
+		t.Errorf("persisted scores = %+v", got)
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleGetMirrorScores(rec, httptest.NewRequest(http.MethodGet, "/api/mirrors/scores", nil))
+	var resp mirrorScoresResponse
+	decodeResponse(t, rec, &resp)
+	if !resp.Auto || len(resp.Mirrors) == 0 {
+		t.Fatalf("resp = %+v", resp)
+	}
+	if first, last := resp.Mirrors[0], resp.Mirrors[len(resp.Mirrors)-1]; first.Key != "ghfast" || last.Key != "conversun" {
+		t.Errorf("order = %s first, %s last; want ghfast first and the failing conversun last", first.Key, last.Key)
+	}
+
+	if err := cfgMgr.SaveConfig(config.Config{Mirror: "gh-proxy"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.runMirrorBenchmark(context.Background()); !errors.Is(err, scheduler.ErrSkipped) {
+		t.Errorf("benchmark with a fixed mirror: err = %v, want skipped", err)
+	}
+}
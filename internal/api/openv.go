@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+
+	"fnos-store/internal/config"
+	"fnos-store/internal/platform"
+)
+
+// opEnv is what one operation runs with, fixed from the settings when it
+// starts. Every stage reads its mirrors and variables from here rather than
+// from the process environment or the live config, so operations started
+// with different settings run side by side, and a settings change only
+// affects the operations that start after it.
+type opEnv struct {
+	// githubPrefixes are the mirror prefixes the fpk download tries, in
+	// order.
+	githubPrefixes []string
+	// dockerMirror is the registry prefix compose files pull through;
+	// multiRegistry tells whether it proxies every registry, not only Docker
+	// Hub. dockerFallbacks are the mirrors tried after it.
+	dockerMirror    string
+	multiRegistry   bool
+	dockerFallbacks []config.DockerMirror
+	// cliEnv are the "KEY=value" variables appcenter-cli and the app's install
+	// hooks run with: the proxy, the wizard answers under their field names,
+	// and DOCKER_MIRROR for the compose file when a mirror is in use.
+	cliEnv []string
+	// params are the user's answers to the app's install wizard.
+	params []platform.WizardParam
+}
+
+// newOpEnv captures the current settings for an operation.
+func (p *installPipeline) newOpEnv(params []platform.WizardParam) *opEnv {
+	cfg := config.Config{Mirror: config.DefaultMirror, DockerMirror: config.DefaultDockerMirror}
+	if p.configMgr != nil {
+		cfg = p.configMgr.Get()
+	}
+	mirrors := config.DockerFallbackMirrors(cfg.DockerMirror, cfg)
+	cliEnv := cfg.Proxy.Env()
+	for _, param := range params {
+		if isEnvName(param.Key) {
+			cliEnv = append(cliEnv, param.Key+"="+param.Value)
+		}
+	}
+	if mirrors[0].URL != "" {
+		cliEnv = append(cliEnv, "DOCKER_MIRROR="+mirrors[0].URL)
+	}
+	return &opEnv{
+		githubPrefixes:  config.GitHubFallbackPrefixes(cfg.Mirror, cfg),
+		dockerMirror:    mirrors[0].URL,
+		multiRegistry:   mirrors[0].MultiRegistry,
+		dockerFallbacks: mirrors[1:],
+		cliEnv:          cliEnv,
+		params:          params,
+	}
+}
+
+// downloadURLs returns the URLs to try for rawURL, one per mirror.
+func (e *opEnv) downloadURLs(rawURL string) []string {
+	urls := make([]string, 0, len(e.githubPrefixes))
+	for _, prefix := range e.githubPrefixes {
+		urls = append(urls, config.MirrorURL(prefix, rawURL))
+	}
+	return urls
+}
+
+// composeVars are the variables an app's compose file is read with, as its
+// install hooks will see them. The wizard answers cannot override the ones
+// the store sets.
+func (e *opEnv) composeVars(version string) map[string]string {
+	vars := make(map[string]string, len(e.params)+2)
+	for _, param := range e.params {
+		if isEnvName(param.Key) {
+			vars[param.Key] = param.Value
+		}
+	}
+	vars["VERSION"] = version
+	if e.dockerMirror != "" {
+		vars["DOCKER_MIRROR"] = e.dockerMirror
+	}
+	return vars
+}
+
+// cliContext returns ctx carrying the operation's variables to every
+// appcenter-cli command run under it.
+func (e *opEnv) cliContext(ctx context.Context) context.Context {
+	return platform.WithCLIEnv(ctx, e.cliEnv)
+}
+
+// isEnvName reports whether key can be an environment variable name; wizard
+// fields with any other name are left out.
+func isEnvName(key string) bool {
+	for i, c := range key {
+		switch {
+		case c == '_', 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return key != ""
+}
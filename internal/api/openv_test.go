@@ -0,0 +1,90 @@
+package api
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"fnos-store/internal/config"
+	"fnos-store/internal/docker"
+	"fnos-store/internal/platform"
+)
+
+func TestOpEnvKeepsItsSettings(t *testing.T) {
+	cfgMgr := config.NewManager(t.TempDir())
+	p := &installPipeline{configMgr: cfgMgr}
+	setDockerMirror := func(key string) {
+		t.Helper()
+		cfg := cfgMgr.Get()
+		cfg.DockerMirror = key
+		if err := cfgMgr.SaveConfig(cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	setDockerMirror("daocloud")
+	first := p.newOpEnv(nil)
+	setDockerMirror("docker-1ms")
+	second := p.newOpEnv(nil)
+
+	if first.dockerMirror != "m.daocloud.io/" || !first.multiRegistry {
+		t.Errorf("first operation mirror = %q (multi-registry %v), want the one it started with", first.dockerMirror, first.multiRegistry)
+	}
+	if second.dockerMirror != "docker.1ms.run/" || second.multiRegistry {
+		t.Errorf("second operation mirror = %q (multi-registry %v), want docker.1ms.run/", second.dockerMirror, second.multiRegistry)
+	}
+	if !slices.Contains(first.cliEnv, "DOCKER_MIRROR=m.daocloud.io/") {
+		t.Errorf("hook env = %q, want the operation's DOCKER_MIRROR", first.cliEnv)
+	}
+	if got := first.composeVars("1.2")["DOCKER_MIRROR"]; got != "m.daocloud.io/" {
+		t.Errorf("compose DOCKER_MIRROR = %q, want the operation's mirror", got)
+	}
+}
+
+func TestOpEnvDirectHasNoDockerMirror(t *testing.T) {
+	cfgMgr := config.NewManager(t.TempDir())
+	cfg := cfgMgr.Get()
+	cfg.DockerMirror = "direct"
+	if err := cfgMgr.SaveConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	env := (&installPipeline{configMgr: cfgMgr}).newOpEnv(nil)
+	for _, kv := range env.cliEnv {
+		if strings.HasPrefix(kv, "DOCKER_MIRROR=") {
+			t.Errorf("hook env has %q without a mirror, want it unset", kv)
+		}
+	}
+	if _, ok := env.composeVars("1.2")["DOCKER_MIRROR"]; ok {
+		t.Error("compose vars have DOCKER_MIRROR without a mirror")
+	}
+}
+
+func TestOpEnvPassesWizardParams(t *testing.T) {
+	p := &installPipeline{}
+	env := p.newOpEnv([]platform.WizardParam{
+		{Key: "wizard_image_tag", Value: "2.1-lite"},
+		{Key: "VERSION", Value: "9.9"},
+		{Key: "bad key", Value: "x"},
+	})
+
+	if !slices.Contains(env.cliEnv, "wizard_image_tag=2.1-lite") {
+		t.Errorf("hook env = %q, want the wizard answer", env.cliEnv)
+	}
+	for _, kv := range env.cliEnv {
+		if strings.HasPrefix(kv, "bad key=") {
+			t.Errorf("hook env has %q, a field that is not a variable name", kv)
+		}
+	}
+
+	compose := "services:\n  app:\n    image: ${DOCKER_MIRROR}example/app:${wizard_image_tag}\n  sidecar:\n    image: example/sidecar:${VERSION}\n"
+	services, err := docker.ParseCompose([]byte(compose), composeEnv(t.TempDir(), env.composeVars("1.2")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	images := composeImages(services)
+	want := []string{env.dockerMirror + "example/app:2.1-lite", "example/sidecar:1.2"}
+	if !slices.Equal(images, want) {
+		t.Errorf("images = %q, want %q", images, want)
+	}
+}
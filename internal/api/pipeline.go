@@ -1,7 +1,6 @@
 package api
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"fmt"
@@ -11,10 +10,12 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"fnos-store/internal/config"
 	"fnos-store/internal/core"
+	"fnos-store/internal/docker"
 	"fnos-store/internal/platform"
 )
 
@@ -29,18 +30,32 @@ import (
 // by appcenter-cli's own startup time, which is well above 1s.
 const selfUpdateFlushDelay = 750 * time.Millisecond
 
+// maxParallelFetches bounds the operations downloading or pulling images at
+// once: enough to keep the link busy while another app installs, few enough
+// that ten updates do not split the bandwidth ten ways.
+const maxParallelFetches = 3
+
 type installPipeline struct {
 	downloads  *core.Downloader
 	ac         platform.AppCenter
 	queue      *OperationQueue
+	journal    *Journal
 	appsDir    string
 	configMgr  *config.Manager
 	cacheStore cacheTagStore
+	docker     *docker.Client
+
+	// fetchSlots bounds the downloads and image pulls running at once, and
+	// installSlot lets one app at a time through the install stage. Nil
+	// channels leave the stage unbounded.
+	fetchSlots  chan struct{}
+	installSlot chan struct{}
 }
 
 type cacheTagStore interface {
 	SetInstalledTag(appname, releaseTag string)
 	RemoveInstalledTag(appname string)
+	appImageStore
 }
 
 func (p *installPipeline) extractFpk(fpkPath string) (string, error) {
@@ -59,7 +74,7 @@ func (p *installPipeline) extractFpk(fpkPath string) (string, error) {
 	return dir, nil
 }
 
-func (p *installPipeline) downloadFpk(ctx context.Context, stream *sseStream, app core.AppInfo) (string, error) {
+func (p *installPipeline) downloadFpk(ctx context.Context, stream *sseStream, app core.AppInfo, env *opEnv) (string, error) {
 	if p.downloads == nil {
 		return "", errors.New("下载器未配置")
 	}
@@ -74,34 +89,11 @@ func (p *installPipeline) downloadFpk(ctx context.Context, stream *sseStream, ap
 	startTime := time.Now()
 	var lastSend time.Time
 
-	var cfg config.Config
-	if p.configMgr != nil {
-		cfg = p.configMgr.Get()
-	} else {
-		cfg = config.Config{Mirror: config.DefaultMirror, DockerMirror: config.DefaultDockerMirror}
-	}
-
-	dockerPrefix := config.DockerMirrorPrefix(cfg.DockerMirror, cfg)
-	if dockerPrefix != "" {
-		os.Setenv("DOCKER_MIRROR", dockerPrefix)
-	} else {
-		os.Unsetenv("DOCKER_MIRROR")
-	}
-
-	prefixes := config.GitHubFallbackPrefixes(cfg.Mirror, cfg)
-	downloadURLs := make([]string, 0, len(prefixes))
-	for _, prefix := range prefixes {
-		if prefix != "" {
-			downloadURLs = append(downloadURLs, prefix+app.DownloadURL)
-		} else {
-			downloadURLs = append(downloadURLs, app.DownloadURL)
-		}
-	}
-
 	fpkPath, err := p.downloads.Download(ctx, core.DownloadRequest{
-		URLs:     downloadURLs,
-		FileName: fileName,
-		AppName:  app.AppName,
+		URLs:      env.downloadURLs(app.DownloadURL),
+		FileName:  fileName,
+		AppName:   app.AppName,
+		RateLimit: downloadLimit(ctx),
 	}, func(downloaded, total int64) {
 		if total <= 0 {
 			return
@@ -144,8 +136,8 @@ func (p *installPipeline) downloadFpk(ctx context.Context, stream *sseStream, ap
 // the reinstall then fails, and there is no retained copy of the old version
 // to restore. verifyPayloadLanded detects the loss afterwards but cannot undo
 // it (conversun/fnos-apps#189).
-func (p *installPipeline) requireSafeUpgrade() error {
-	cap := p.ac.UpgradeCapability()
+func (p *installPipeline) requireSafeUpgrade(ctx context.Context) error {
+	cap := p.ac.UpgradeCapability(ctx)
 	if cap.Allowed {
 		return nil
 	}
@@ -162,7 +154,7 @@ func (p *installPipeline) requireSafeUpgrade() error {
 // volume when there is exactly one. Only a genuinely ambiguous system (several
 // volumes, no usable default) asks the user to pick, because there is no safe
 // way to guess which disk their apps belong on.
-func (p *installPipeline) resolveVolume() (int, error) {
+func (p *installPipeline) resolveVolume(ctx context.Context) (int, error) {
 	if p.configMgr != nil {
 		if v := p.configMgr.Get().InstallVolume; v > 0 {
 			return v, nil
@@ -171,10 +163,10 @@ func (p *installPipeline) resolveVolume() (int, error) {
 	var volume int
 	err := p.queue.WithCLI(func() error {
 		var e error
-		volume, e = p.ac.DefaultVolume()
+		volume, e = p.ac.DefaultVolume(ctx)
 		return e
 	})
-	mounted, listErr := p.mountedVolumes()
+	mounted, listErr := p.mountedVolumes(ctx)
 	if err != nil {
 		// The getter itself failed. A single mounted volume is still an
 		// unambiguous answer.
@@ -202,8 +194,8 @@ func (p *installPipeline) resolveVolume() (int, error) {
 }
 
 // mountedVolumes returns the indexes of the currently mounted volumes.
-func (p *installPipeline) mountedVolumes() ([]int, error) {
-	volumes, err := p.ac.ListVolumes()
+func (p *installPipeline) mountedVolumes(ctx context.Context) ([]int, error) {
+	volumes, err := p.ac.ListVolumes(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -221,9 +213,9 @@ func (p *installPipeline) mountedVolumes() ([]int, error) {
 // loss reported in conversun/fnos-apps#189). It therefore fails closed rather
 // than falling back to the global default. Fresh installs use the configured
 // or default volume.
-func (p *installPipeline) resolveVolumeFor(opName, appname string) (int, error) {
+func (p *installPipeline) resolveVolumeFor(ctx context.Context, opName, appname string) (int, error) {
 	if opName == "update" {
-		vol, found, err := p.ac.AppInstallVolume(appname)
+		vol, found, err := p.ac.AppInstallVolume(ctx, appname)
 		if err != nil {
 			return 0, fmt.Errorf("无法确定 %s 当前所在的存储卷，已中止更新以保护现有数据: %w", appname, err)
 		}
@@ -232,7 +224,7 @@ func (p *installPipeline) resolveVolumeFor(opName, appname string) (int, error)
 		}
 		return vol, nil
 	}
-	return p.resolveVolume()
+	return p.resolveVolume(ctx)
 }
 
 // preflightInstall fails closed BEFORE the destructive install-local step,
@@ -240,12 +232,12 @@ func (p *installPipeline) resolveVolumeFor(opName, appname string) (int, error)
 // volume is actually mounted and has generous free space, so a missing or full
 // volume aborts the operation instead of leaving the app uninstalled with its
 // data orphaned — the failure mode behind conversun/fnos-apps#189.
-func (p *installPipeline) preflightInstall(volume int, fpkPath string) error {
+func (p *installPipeline) preflightInstall(ctx context.Context, volume int, fpkPath string) error {
 	fi, err := os.Stat(fpkPath)
 	if err != nil {
 		return fmt.Errorf("安装包不可读，已中止安装以保护现有数据: %w", err)
 	}
-	volumes, err := p.ac.ListVolumes()
+	volumes, err := p.ac.ListVolumes(ctx)
 	if err != nil {
 		return fmt.Errorf("无法枚举存储卷，已中止安装以保护现有数据: %w", err)
 	}
@@ -278,12 +270,12 @@ func (p *installPipeline) preflightInstall(volume int, fpkPath string) error {
 //
 // Set and get share one WithCLI block so a concurrent operation cannot slip in
 // between them and invalidate the verification.
-func (p *installPipeline) setDefaultVolume(volume int) error {
+func (p *installPipeline) setDefaultVolume(ctx context.Context, volume int) error {
 	return p.queue.WithCLI(func() error {
-		if err := p.ac.SetDefaultVolume(volume); err != nil {
+		if err := p.ac.SetDefaultVolume(ctx, volume); err != nil {
 			return err
 		}
-		got, err := p.ac.DefaultVolume()
+		got, err := p.ac.DefaultVolume(ctx)
 		if err != nil {
 			return fmt.Errorf("设置后无法读回默认安装卷: %w", err)
 		}
@@ -312,15 +304,15 @@ func (p *installPipeline) installFpkWithWizard(ctx context.Context, fpkPath stri
 	})
 }
 
-func (p *installPipeline) installFpk(fpkPath string, volume int) error {
+func (p *installPipeline) installFpk(ctx context.Context, fpkPath string, volume int) error {
 	return p.queue.WithCLI(func() error {
-		return p.ac.InstallFpk(fpkPath, volume)
+		return p.ac.InstallFpk(ctx, fpkPath, volume)
 	})
 }
 
-func (p *installPipeline) startApp(appname string) error {
+func (p *installPipeline) startApp(ctx context.Context, appname string) error {
 	return p.queue.WithCLI(func() error {
-		return p.ac.Start(appname)
+		return p.ac.Start(ctx, appname)
 	})
 }
 
@@ -385,7 +377,7 @@ func (p *installPipeline) verifyInstalled(ctx context.Context, appname string) e
 		var installed bool
 		err := p.queue.WithCLI(func() error {
 			var e error
-			installed, e = p.ac.Check(appname)
+			installed, e = p.ac.Check(ctx, appname)
 			return e
 		})
 		if err != nil {
@@ -406,7 +398,7 @@ func (p *installPipeline) verifyInstalled(ctx context.Context, appname string) e
 	var apps []platform.InstalledApp
 	listErr := p.queue.WithCLI(func() error {
 		var e error
-		apps, e = p.ac.List()
+		apps, e = p.ac.List(ctx)
 		return e
 	})
 	if listErr != nil {
@@ -449,7 +441,7 @@ func (p *installPipeline) manifestExists(appname string) bool {
 // the package ships no fpk_version. Comparing it against `version` alone would
 // reject a perfectly good install of any revision package — 29 of 145 apps in
 // the catalog carry a -rN suffix (1panel, alist, gitea, gopeed, embyserver …).
-func (p *installPipeline) verifyPayloadLanded(appname string, wantVolume int, wantVersion string) error {
+func (p *installPipeline) verifyPayloadLanded(ctx context.Context, appname string, wantVolume int, wantVersion string) error {
 	targetLink := filepath.Join(p.appsDir, appname, "target")
 	resolved, err := filepath.EvalSymlinks(targetLink)
 	if err != nil {
@@ -467,7 +459,7 @@ func (p *installPipeline) verifyPayloadLanded(appname string, wantVolume int, wa
 	// Confirm the payload landed on the volume we pinned. A mismatch means the
 	// app was relocated, which orphans its data under the old volume.
 	if wantVolume > 0 {
-		volumes, err := p.ac.ListVolumes()
+		volumes, err := p.ac.ListVolumes(ctx)
 		if err == nil {
 			if idx, ok := volumeIndexOf(resolved, volumes); ok && idx != wantVolume {
 				return fmt.Errorf("安装校验失败：%s 被安装到 vol%d，而非预期的 vol%d，原有数据可能已被遗留在 vol%d。请在应用中心确认",
@@ -567,41 +559,49 @@ func runWithVirtualProgress(ctx context.Context, stream *sseStream, step, messag
 	}
 }
 
-func (p *installPipeline) dockerPull(ctx context.Context, stream *sseStream, fpkDir string, app core.AppInfo) error {
+// dockerPull pre-pulls the images an app's compose file names and returns
+// every reference it left on the system (the compose tag plus the mirror tag
+// it was pulled under), so they can be tracked for pruning. A nil result with
+// a nil error means nothing was pulled.
+func (p *installPipeline) dockerPull(ctx context.Context, stream *sseStream, fpkDir string, app core.AppInfo, env *opEnv) ([]string, error) {
 	// docker-compose.yaml is inside app.tgz, not at fpk top level
 	appTgz := filepath.Join(fpkDir, "app.tgz")
 	appDir := filepath.Join(fpkDir, "app-contents")
 	if err := os.MkdirAll(appDir, 0o755); err != nil {
 		fmt.Fprintf(os.Stderr, "dockerPull: create app dir: %v\n", err)
-		return nil // non-fatal: let install handle it
+		return nil, nil // non-fatal: let install handle it
 	}
 	if out, err := exec.CommandContext(ctx, "tar", "xzf", appTgz, "-C", appDir).CombinedOutput(); err != nil {
 		fmt.Fprintf(os.Stderr, "dockerPull: extract app.tgz: %v: %s\n", err, out)
-		return nil // non-fatal: let install handle it
+		return nil, nil // non-fatal: let install handle it
 	}
 
 	composePath := filepath.Join(appDir, "docker", "docker-compose.yaml")
 	data, err := os.ReadFile(composePath)
 	if err != nil {
-		return nil // no compose file — not a docker app
+		return nil, nil // no compose file — not a docker app
 	}
 
-	mirror := os.Getenv("DOCKER_MIRROR")
-	var multiRegistry bool
-	if p.configMgr != nil {
-		multiRegistry = config.IsDockerMirrorMultiRegistry(p.configMgr.Get().DockerMirror)
+	mirror := env.dockerMirror
+	services, err := docker.ParseCompose(data, composeEnv(filepath.Dir(composePath), env.composeVars(app.FpkVersion)))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dockerPull: %v\n", err)
+		return nil, nil // non-fatal: let install handle it
 	}
-
-	images := parseDockerImages(string(data), app, mirror)
+	images := composeImages(services)
 	if len(images) == 0 {
-		return nil // no images found — not a docker app
+		return nil, nil // no images found — not a docker app
 	}
 
-	if _, err := exec.LookPath("docker"); err != nil {
-		fmt.Fprintf(os.Stderr, "dockerPull: docker not found, skipping pre-pull\n")
-		return nil
+	if p.docker == nil {
+		return nil, nil
+	}
+	if err := p.docker.Ping(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "dockerPull: docker daemon unavailable, skipping pre-pull: %v\n", err)
+		return nil, nil
 	}
 
+	pulled := make([]string, 0, len(images))
 	for i, composeRef := range images {
 		msg := fmt.Sprintf("正在拉取 Docker 镜像 (%d/%d)...", i+1, len(images))
 		if len(images) == 1 {
@@ -609,91 +609,64 @@ func (p *installPipeline) dockerPull(ctx context.Context, stream *sseStream, fpk
 		}
 		_ = stream.sendProgress(progressPayload{Step: "pulling", Progress: 0, Message: msg})
 
-		pullRef := normalizeImageForPull(composeRef, mirror, multiRegistry)
-		if err := p.pullSingleImage(ctx, stream, pullRef, msg); err != nil {
-			return err
+		pullRef := normalizeImageForPull(composeRef, mirror, env.multiRegistry)
+		err := p.pullSingleImage(ctx, stream, pullRef, msg)
+		for _, fb := range env.dockerFallbacks {
+			if err == nil || ctx.Err() != nil || mirror == "" || !strings.HasPrefix(composeRef, mirror) {
+				break
+			}
+			log.Printf("dockerPull: %s failed, trying %s: %v", pullRef, fb.Label, err)
+			pullRef = normalizeImageForPull(fb.URL+composeRef[len(mirror):], fb.URL, fb.MultiRegistry)
+			err = p.pullSingleImage(ctx, stream, pullRef, msg)
+		}
+		if err != nil {
+			return nil, err
 		}
+		pulled = append(pulled, composeRef)
 		if pullRef != composeRef {
-			_ = exec.CommandContext(ctx, "docker", "tag", pullRef, composeRef).Run()
+			if err := p.docker.Tag(ctx, pullRef, composeRef); err != nil {
+				log.Printf("dockerPull: tag %s as %s: %v", pullRef, composeRef, err)
+			}
+			pulled = append(pulled, pullRef)
 		}
 	}
 
 	_ = stream.sendProgress(progressPayload{Step: "pulling", Progress: 100, Message: "Docker 镜像拉取完成"})
-	return nil
+	return pulled, nil
 }
 
+// pullSingleImage pulls one image through the Engine API, which streams real
+// per-layer byte counts instead of the CLI's progress bars.
 func (p *installPipeline) pullSingleImage(ctx context.Context, stream *sseStream, image, message string) error {
-	cmd := exec.CommandContext(ctx, "docker", "pull", image)
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return fmt.Errorf("Docker 镜像拉取失败: %w", err)
-	}
-	cmd.Stderr = cmd.Stdout
-
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("Docker 镜像拉取失败: %w", err)
-	}
-
-	var totalLayers, completedLayers int
-	var lastErrLine string
-	scanner := bufio.NewScanner(stdout)
-	for scanner.Scan() {
-		line := scanner.Text()
-		switch {
-		case strings.Contains(line, "Pulling fs layer"), strings.Contains(line, "Waiting"):
-			totalLayers++
-		case strings.Contains(line, "Already exists"):
-			totalLayers++
-			completedLayers++
-		case strings.Contains(line, "Pull complete"), strings.Contains(line, "Digest:"),
-			strings.Contains(line, "Status:"), strings.Contains(line, "Downloading"),
-			strings.Contains(line, "Extracting"), strings.Contains(line, "Verifying"):
-			completedLayers++
-		default:
-			if trimmed := strings.TrimSpace(line); trimmed != "" {
-				lastErrLine = trimmed
-			}
-		}
-		if totalLayers > 0 {
-			pct := completedLayers * 100 / totalLayers
-			if pct > 99 {
-				pct = 99
-			}
-			_ = stream.sendProgress(progressPayload{Step: "pulling", Progress: pct, Message: message})
-		}
-	}
-
-	if err := cmd.Wait(); err != nil {
-		detail := err.Error()
-		if lastErrLine != "" {
-			detail = lastErrLine
+	var lastSend time.Time
+	err := p.docker.Pull(ctx, image, func(pp docker.PullProgress) {
+		now := time.Now()
+		if now.Sub(lastSend) < 200*time.Millisecond {
+			return
 		}
-		return fmt.Errorf("Docker 镜像拉取失败: %s\n请尝试在 Docker 设置中更换镜像加速源后重试", detail)
+		lastSend = now
+		_ = stream.sendProgress(progressPayload{
+			Step:       "pulling",
+			Progress:   pullPercent(pp),
+			Message:    message,
+			Downloaded: pp.Downloaded,
+			Total:      pp.Total,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("Docker 镜像拉取失败: %s\n请尝试在 Docker 设置中更换镜像加速源后重试", err)
 	}
-
 	return nil
 }
 
-func parseDockerImages(content string, app core.AppInfo, mirror string) []string {
-	version := app.FpkVersion
-
-	var images []string
-	for _, line := range strings.Split(content, "\n") {
-		trimmed := strings.TrimSpace(line)
-		if !strings.HasPrefix(trimmed, "image:") {
-			continue
-		}
-		image := strings.TrimSpace(strings.TrimPrefix(trimmed, "image:"))
-		if image == "" {
-			continue
-		}
-
-		image = strings.ReplaceAll(image, "${DOCKER_MIRROR}", mirror)
-		image = strings.ReplaceAll(image, "${VERSION}", version)
-
-		images = append(images, image)
+// pullPercent reports a pull's layer-weighted completion. It stops at 99: the
+// pull is only complete when the stream ends.
+func pullPercent(pp docker.PullProgress) int {
+	pct := int(pp.Completion * 100)
+	if pct > 99 {
+		pct = 99
 	}
-	return images
+	return pct
 }
 
 func normalizeImageForPull(image, mirror string, multiRegistry bool) string {
@@ -742,46 +715,99 @@ func chooseInstallRoute(opName string, daemonAvailable bool) installRoute {
 	return routeInstallLocal
 }
 
-func (p *installPipeline) runStandard(ctx context.Context, stream *sseStream, opName string, app core.AppInfo, params []platform.WizardParam, refreshFn func(context.Context) error) {
+// runStandard installs or updates app. refreshFn, when set, reloads the
+// registry once the app is in place.
+func (p *installPipeline) runStandard(ctx context.Context, stream *sseStream, opName string, app core.AppInfo, env *opEnv, refreshFn func(context.Context) error) {
+	// A build this box cannot run would only fail after the download, or
+	// worse, during an update after the old copy is gone.
+	if app.IncompatibleReason != "" {
+		_ = stream.sendError(app.IncompatibleReason)
+		return
+	}
+
 	// Guard first: refuse before downloading, so an affected system never
 	// reaches the uninstall-then-failed-reinstall path.
 	if opName == "update" {
-		if err := p.requireSafeUpgrade(); err != nil {
+		if err := p.requireSafeUpgrade(ctx); err != nil {
 			_ = stream.sendError(err.Error())
 			return
 		}
+		if app.UpgradeBlockedReason != "" {
+			_ = stream.sendError(app.UpgradeBlockedReason)
+			return
+		}
+	}
+
+	expectedVersion := app.FpkVersion
+	if expectedVersion == "" {
+		expectedVersion = app.LatestVersion
+	}
+
+	// Every stage is journaled before it starts, so a restart after a crash
+	// can tell how far the operation got.
+	entry := JournalEntry{
+		AppName:       app.AppName,
+		Operation:     opName,
+		Stage:         stageDownloading,
+		TargetVersion: expectedVersion,
+		ReleaseTag:    app.ReleaseTag,
+		StartedAt:     time.Now(),
 	}
+	p.journal.Record(entry)
+	defer p.journal.Remove(app.AppName)
 
-	fpkPath, err := p.downloadFpk(ctx, stream, app)
+	// The fetch stage runs alongside other operations' fetches and installs;
+	// the install stage runs one app at a time.
+	release, err := acquireSlot(ctx, stream, p.fetchSlots, progressPayload{Step: "downloading", Message: "等待下载..."})
 	if err != nil {
-		_ = stream.sendError(err.Error())
+		stream.failStage(ctx, err)
+		return
+	}
+	fpkPath, pulledImages, err := p.fetchStage(ctx, stream, app, env, &entry)
+	release()
+	if fpkPath != "" {
+		defer os.Remove(fpkPath)
+	}
+	if err != nil {
+		stream.failStage(ctx, err)
 		return
 	}
-	defer os.Remove(fpkPath)
 
-	if app.AppType == "docker" {
-		dir, err := p.extractFpk(fpkPath)
-		if err == nil {
-			pullErr := p.dockerPull(ctx, stream, dir, app)
-			os.RemoveAll(dir)
-			if pullErr != nil {
-				_ = stream.sendError(pullErr.Error())
-				return
-			}
-		}
+	release, err = acquireSlot(ctx, stream, p.installSlot, progressPayload{Step: "installing", Message: "等待其他应用安装完成..."})
+	if err != nil {
+		stream.failStage(ctx, err)
+		return
 	}
+	// The slot only covers changing the app; the image cleanup and the
+	// catalog refresh after it run outside, alongside the next install.
+	releaseInstall := sync.OnceFunc(release)
+	defer releaseInstall()
 
-	volume, err := p.resolveVolumeFor(opName, app.AppName)
+	volume, err := p.resolveVolumeFor(ctx, opName, app.AppName)
 	if err != nil {
-		_ = stream.sendError(err.Error())
+		stream.failStage(ctx, err)
 		return
 	}
 
-	if err := p.preflightInstall(volume, fpkPath); err != nil {
-		_ = stream.sendError(err.Error())
+	if err := p.preflightInstall(ctx, volume, fpkPath); err != nil {
+		stream.failStage(ctx, err)
 		return
 	}
 
+	// From here on the app is being changed; the rest runs to the end.
+	if !p.queue.BeginDestructive(app.AppName) || ctx.Err() != nil {
+		stream.failStage(ctx, ctx.Err())
+		return
+	}
+	entry.Stage, entry.Volume = stageInstalling, volume
+	p.journal.Record(entry)
+	// A client going away must not stop the install, verification or start
+	// halfway through, nor turn a change still going on into a failure;
+	// commands still stop at the CLI's own time limits. The app's hooks get
+	// the operation's own variables.
+	opCtx := context.WithoutCancel(ctx)
+	cliCtx := env.cliContext(opCtx)
+
 	// Updates go through the daemon's own upgrade channel, which preserves
 // @appdata and can roll back. install-local is uninstall-then-reinstall and
 // destroys the app when the reinstall fails — conversun/fnos-apps#189. A
@@ -797,33 +823,30 @@ func (p *installPipeline) runStandard(ctx context.Context, stream *sseStream, op
 // as the fallback for a box whose daemon is unreachable, where it is safe
 // because a fresh install has no existing app/data to destroy.
 var installStep func() error
-switch chooseInstallRoute(opName, p.ac.UpgradeCapability().Allowed) {
+switch chooseInstallRoute(opName, p.ac.UpgradeCapability(opCtx).Allowed) {
 case routeDaemonUpgrade:
-    installStep = func() error { return p.upgradeFpk(ctx, fpkPath) }
+    installStep = func() error { return p.upgradeFpk(opCtx, fpkPath) }
 case routeDaemonInstall:
-    installStep = func() error { return p.installFpkWithWizard(ctx, fpkPath, volume, params) }
+    installStep = func() error { return p.installFpkWithWizard(opCtx, fpkPath, volume, env.params) }
 default: // routeInstallLocal
-    installStep = func() error { return p.installFpk(fpkPath, volume) }
+    installStep = func() error { return p.installFpk(cliCtx, fpkPath, volume) }
 }
 
-	if err := runWithVirtualProgress(ctx, stream, "installing", "正在安装...", installStep); err != nil {
+	if err := runWithVirtualProgress(opCtx, stream, "installing", "正在安装...", installStep); err != nil {
 		_ = stream.sendError(err.Error())
 		return
 	}
 
-	expectedVersion := app.FpkVersion
-	if expectedVersion == "" {
-		expectedVersion = app.LatestVersion
-	}
-
-	if err := runWithVirtualProgress(ctx, stream, "verifying", "正在验证安装...", func() error {
-		if err := p.verifyInstalled(ctx, app.AppName); err != nil {
+	entry.Stage = stageVerifying
+	p.journal.Record(entry)
+	if err := runWithVirtualProgress(opCtx, stream, "verifying", "正在验证安装...", func() error {
+		if err := p.verifyInstalled(opCtx, app.AppName); err != nil {
 			return err
 		}
 		// The control-plane checks above can pass on a destroyed app, so the
 		// operation is only really successful once the payload is proven on
 		// disk, on the pinned volume, at the shipped version.
-		return p.verifyPayloadLanded(app.AppName, volume, expectedVersion)
+		return p.verifyPayloadLanded(opCtx, app.AppName, volume, expectedVersion)
 	}); err != nil {
 		_ = stream.sendError(err.Error())
 		return
@@ -834,8 +857,10 @@ default: // routeInstallLocal
 	// "[Info]Application [x] is already started." — which the CLI reports as a
 	// failure, turning a successful upgrade into a user-visible error.
 	if opName != "update" {
-    if err := runWithVirtualProgress(ctx, stream, "starting", "正在启动...", func() error {
-        return p.startApp(app.AppName)
+    entry.Stage = stageStarting
+    p.journal.Record(entry)
+    if err := runWithVirtualProgress(opCtx, stream, "starting", "正在启动...", func() error {
+        return p.startApp(cliCtx, app.AppName)
     }); err != nil {
         // Apps with no service port (e.g. nvidia-driver — a root-installed
         // kernel driver with ctl_stop=false) have no startable service, so
@@ -851,43 +876,123 @@ default: // routeInstallLocal
     }
 }
 
+	releaseInstall()
+
 	if p.cacheStore != nil && app.ReleaseTag != "" {
 		p.cacheStore.SetInstalledTag(app.AppName, app.ReleaseTag)
 	}
 
-	_ = refreshFn(ctx)
+	// Only now that the new version is proven running are the images the
+	// previous one used safe to drop.
+	if len(pulledImages) > 0 {
+		p.afterImageChange(opCtx, stream, app.AppName, p.resolveAppImages(opCtx, pulledImages))
+	}
+
+	if refreshFn != nil {
+		_ = refreshFn(opCtx)
+	}
 
 	newVersion := expectedVersion
 	_ = stream.sendProgress(progressPayload{Step: "done", NewVersion: newVersion, Message: "操作完成"})
 }
 
+// fetchStage downloads the app's fpk and pre-pulls its images. It only
+// fetches things, so a cancel stops it at once and leaves the installed app
+// untouched. The fpk is returned even when the pull fails, for the caller to
+// remove.
+func (p *installPipeline) fetchStage(ctx context.Context, stream *sseStream, app core.AppInfo, env *opEnv, entry *JournalEntry) (fpkPath string, pulledImages []string, err error) {
+	fpkPath, err = p.downloadFpk(ctx, stream, app, env)
+	if err != nil {
+		return "", nil, err
+	}
+	if app.AppType != "docker" {
+		return fpkPath, nil, nil
+	}
+	dir, err := p.extractFpk(fpkPath)
+	if err != nil {
+		return fpkPath, nil, nil // non-fatal: let install handle it
+	}
+	defer os.RemoveAll(dir)
+	entry.Stage = stagePulling
+	p.journal.Record(*entry)
+	pulledImages, err = p.dockerPull(ctx, stream, dir, app, env)
+	return fpkPath, pulledImages, err
+}
+
+// acquireSlot takes one of slots, reporting waiting while every slot is in
+// use. A nil slots channel does not limit anything. The returned func gives
+// the slot back.
+func acquireSlot(ctx context.Context, stream *sseStream, slots chan struct{}, waiting progressPayload) (func(), error) {
+	if slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	default:
+	}
+	_ = stream.sendProgress(waiting)
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
 func (p *installPipeline) runSelfUpdate(ctx context.Context, stream *sseStream, app core.AppInfo) {
 	// Self-update is the riskiest path: the child is detached and this process
 	// is killed partway through, so a failure cannot even be reported.
-	if err := p.requireSafeUpgrade(); err != nil {
+	if err := p.requireSafeUpgrade(ctx); err != nil {
 		_ = stream.sendError(err.Error())
 		return
 	}
+	if app.UpgradeBlockedReason != "" {
+		_ = stream.sendError(app.UpgradeBlockedReason)
+		return
+	}
 
-	fpkPath, err := p.downloadFpk(ctx, stream, app)
+	// The journal entry outlives a successful launch: this process is killed
+	// before the update finishes, and the next one verifies it on startup.
+	entry := JournalEntry{
+		AppName:       app.AppName,
+		Operation:     "update",
+		Stage:         stageDownloading,
+		TargetVersion: app.FpkVersion,
+		ReleaseTag:    app.ReleaseTag,
+		StartedAt:     time.Now(),
+	}
+	if entry.TargetVersion == "" {
+		entry.TargetVersion = app.LatestVersion
+	}
+	p.journal.Record(entry)
+	launched := false
+	defer func() {
+		if !launched {
+			p.journal.Remove(app.AppName)
+		}
+	}()
+
+	env := p.newOpEnv(nil)
+	fpkPath, err := p.downloadFpk(ctx, stream, app, env)
 	if err != nil {
 		_ = stream.sendError(err.Error())
 		return
 	}
 	defer os.Remove(fpkPath)
 
-	volume, err := p.resolveVolumeFor("update", app.AppName)
+	volume, err := p.resolveVolumeFor(ctx, "update", app.AppName)
 	if err != nil {
 		_ = stream.sendError(err.Error())
 		return
 	}
 
-	if err := p.preflightInstall(volume, fpkPath); err != nil {
+	if err := p.preflightInstall(ctx, volume, fpkPath); err != nil {
 		_ = stream.sendError(err.Error())
 		return
 	}
 
-	if err := p.setDefaultVolume(volume); err != nil {
+	if err := p.setDefaultVolume(ctx, volume); err != nil {
 		_ = stream.sendError(fmt.Sprintf("无法锁定安装目标卷 vol%d，已中止商店更新以保护现有数据: %v", volume, err))
 		return
 	}
@@ -915,8 +1020,10 @@ func (p *installPipeline) runSelfUpdate(ctx context.Context, stream *sseStream,
 	// scheduler's registry refresh calls List() through the same lock, and the
 	// launch must not interleave with it. cmd.Start() does not wait for the
 	// child, so holding the lock here costs nothing.
+	entry.Stage, entry.Volume = stageInstalling, volume
+	p.journal.Record(entry)
 	if err := p.queue.WithCLI(func() error {
-		return p.ac.InstallLocal(dir, volume, true)
+		return p.ac.InstallLocal(env.cliContext(ctx), dir, volume, true)
 	}); err != nil {
 		// The fork itself failed - the child never started, so it's safe
 		// (and necessary) to clean up the extracted directory here.
@@ -925,6 +1032,7 @@ func (p *installPipeline) runSelfUpdate(ctx context.Context, stream *sseStream,
 		_ = os.RemoveAll(dir)
 		return
 	}
+	launched = true
 	// Success path: dir is intentionally NOT cleaned up - the detached child
 	// reads it asynchronously after cmd.Start() returns, and fnOS will kill
 	// this process before any deferred cleanup could run. /tmp is wiped on
@@ -942,22 +1050,11 @@ func (p *installPipeline) downloadFpkQuiet(ctx context.Context, app core.AppInfo
 		return "", errors.New("下载地址无效")
 	}
 
-	var cfg config.Config
-	if p.configMgr != nil {
-		cfg = p.configMgr.Get()
-	} else {
-		cfg = config.Config{Mirror: config.DefaultMirror, DockerMirror: config.DefaultDockerMirror}
-	}
-	prefixes := config.GitHubFallbackPrefixes(cfg.Mirror, cfg)
-	urls := make([]string, 0, len(prefixes))
-	for _, prefix := range prefixes {
-		urls = append(urls, prefix+app.DownloadURL)
-	}
-
 	return p.downloads.Download(ctx, core.DownloadRequest{
-		URLs:     urls,
-		FileName: fileName,
-		AppName:  app.AppName,
+		URLs:      p.newOpEnv(nil).downloadURLs(app.DownloadURL),
+		FileName:  fileName,
+		AppName:   app.AppName,
+		RateLimit: downloadLimit(ctx),
 	}, nil)
 }
 
@@ -54,7 +54,7 @@ type stubCheckResult struct {
 	err       error
 }
 
-func (s *stubAppCenter) Check(appname string) (bool, error) {
+func (s *stubAppCenter) Check(_ context.Context, appname string) (bool, error) {
 	idx := int(atomic.AddInt32(&s.nCheck, 1)) - 1
 	if idx >= len(s.checkScript) {
 		// Script exhausted: repeat the last entry so long loops don't panic.
@@ -64,25 +64,25 @@ func (s *stubAppCenter) Check(appname string) (bool, error) {
 	return r.installed, r.err
 }
 
-func (s *stubAppCenter) List() ([]platform.InstalledApp, error) {
+func (s *stubAppCenter) List(context.Context) ([]platform.InstalledApp, error) {
 	atomic.AddInt32(&s.nList, 1)
 	return s.listResult, s.listErr
 }
 
-func (s *stubAppCenter) Status(string) (string, error) { return "", nil }
-func (s *stubAppCenter) InstallFpk(string, int) error {
+func (s *stubAppCenter) Status(context.Context, string) (string, error) { return "", nil }
+func (s *stubAppCenter) InstallFpk(context.Context, string, int) error {
 	atomic.AddInt32(&s.nInstallFpk, 1)
 	return nil
 }
 
-func (s *stubAppCenter) InstallLocal(string, int, bool) error {
+func (s *stubAppCenter) InstallLocal(context.Context, string, int, bool) error {
 	atomic.AddInt32(&s.nInstallLocal, 1)
 	return nil
 }
-func (s *stubAppCenter) Uninstall(string) error { return nil }
-func (s *stubAppCenter) Start(string) error     { return nil }
-func (s *stubAppCenter) Stop(string) error      { return nil }
-func (s *stubAppCenter) DefaultVolume() (int, error) {
+func (s *stubAppCenter) Uninstall(context.Context, string) error { return nil }
+func (s *stubAppCenter) Start(context.Context, string) error     { return nil }
+func (s *stubAppCenter) Stop(context.Context, string) error      { return nil }
+func (s *stubAppCenter) DefaultVolume(context.Context) (int, error) {
 	if s.getVolErr != nil {
 		return 0, s.getVolErr
 	}
@@ -91,7 +91,9 @@ func (s *stubAppCenter) DefaultVolume() (int, error) {
 	}
 	return s.curVol, nil
 }
-func (s *stubAppCenter) ListVolumes() ([]platform.VolumeInfo, error) { return s.volumes, nil }
+func (s *stubAppCenter) ListVolumes(context.Context) ([]platform.VolumeInfo, error) {
+	return s.volumes, nil
+}
 func (s *stubAppCenter) FetchWizard(_ context.Context, _ string) (*platform.AppWizard, error) {
 	return s.wizard, nil
 }
@@ -107,17 +109,17 @@ func (s *stubAppCenter) UpgradeFpk(_ context.Context, _ string, _ []platform.Wiz
 	return s.upgradeErr
 }
 
-func (s *stubAppCenter) UpgradeCapability() platform.UpgradeCapability {
+func (s *stubAppCenter) UpgradeCapability(context.Context) platform.UpgradeCapability {
 	if s.upgradeBlocked {
 		return platform.UpgradeCapability{Allowed: false, PlatformVersion: "1.2.0203", Reason: "该 fnOS 版本更新会删除应用数据"}
 	}
 	return platform.UpgradeCapability{Allowed: true, PlatformVersion: "test"}
 }
 
-func (s *stubAppCenter) AppInstallVolume(string) (int, bool, error) {
+func (s *stubAppCenter) AppInstallVolume(context.Context, string) (int, bool, error) {
 	return s.appVolIdx, s.appVolFound, s.appVolErr
 }
-func (s *stubAppCenter) SetDefaultVolume(v int) error {
+func (s *stubAppCenter) SetDefaultVolume(_ context.Context, v int) error {
 	s.setVolCalls = append(s.setVolCalls, v)
 	if s.setVolErr != nil {
 		return s.setVolErr
@@ -347,7 +349,7 @@ func TestResolveVolumeFor(t *testing.T) {
 	t.Run("update pins to the app's current volume", func(t *testing.T) {
 		stub := &stubAppCenter{appVolIdx: 2, appVolFound: true}
 		p := &installPipeline{queue: NewOperationQueue(), ac: stub}
-		got, err := p.resolveVolumeFor("update", "emby")
+		got, err := p.resolveVolumeFor(context.Background(), "update", "emby")
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -359,7 +361,7 @@ func TestResolveVolumeFor(t *testing.T) {
 	t.Run("update fails closed when current volume is unresolvable", func(t *testing.T) {
 		stub := &stubAppCenter{appVolFound: false}
 		p := &installPipeline{queue: NewOperationQueue(), ac: stub}
-		_, err := p.resolveVolumeFor("update", "emby")
+		_, err := p.resolveVolumeFor(context.Background(), "update", "emby")
 		if err == nil {
 			t.Fatalf("expected error, got nil")
 		}
@@ -375,7 +377,7 @@ func TestResolveVolumeFor(t *testing.T) {
 			volumes:     []platform.VolumeInfo{{Index: 1, Path: "/vol1"}},
 		}
 		p := &installPipeline{queue: NewOperationQueue(), ac: stub}
-		got, err := p.resolveVolumeFor("install", "emby")
+		got, err := p.resolveVolumeFor(context.Background(), "install", "emby")
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -394,7 +396,7 @@ func TestResolveVolumeFor(t *testing.T) {
 			volumes: []platform.VolumeInfo{{Index: 1, Path: "/vol1"}, {Index: 2, Path: "/vol2"}},
 		}
 		p := &installPipeline{queue: NewOperationQueue(), ac: stub}
-		_, err := p.resolveVolumeFor("install", "emby")
+		_, err := p.resolveVolumeFor(context.Background(), "install", "emby")
 		if err == nil {
 			t.Fatal("expected error for an unmounted default volume, got nil")
 		}
@@ -417,7 +419,7 @@ func TestPreflightInstall(t *testing.T) {
 	t.Run("passes when target volume is mounted with ample space", func(t *testing.T) {
 		stub := &stubAppCenter{volumes: []platform.VolumeInfo{{Index: 1, Path: "/vol1", FreeBytes: 1 << 40}}}
 		p := &installPipeline{queue: NewOperationQueue(), ac: stub}
-		if err := p.preflightInstall(1, fpk); err != nil {
+		if err := p.preflightInstall(context.Background(), 1, fpk); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 	})
@@ -425,7 +427,7 @@ func TestPreflightInstall(t *testing.T) {
 	t.Run("aborts when target volume is not mounted", func(t *testing.T) {
 		stub := &stubAppCenter{volumes: []platform.VolumeInfo{{Index: 1, Path: "/vol1", FreeBytes: 1 << 40}}}
 		p := &installPipeline{queue: NewOperationQueue(), ac: stub}
-		err := p.preflightInstall(9, fpk)
+		err := p.preflightInstall(context.Background(), 9, fpk)
 		if err == nil {
 			t.Fatalf("expected error, got nil")
 		}
@@ -437,7 +439,7 @@ func TestPreflightInstall(t *testing.T) {
 	t.Run("aborts when target volume lacks free space", func(t *testing.T) {
 		stub := &stubAppCenter{volumes: []platform.VolumeInfo{{Index: 1, Path: "/vol1", FreeBytes: 100}}}
 		p := &installPipeline{queue: NewOperationQueue(), ac: stub}
-		err := p.preflightInstall(1, fpk)
+		err := p.preflightInstall(context.Background(), 1, fpk)
 		if err == nil {
 			t.Fatalf("expected error, got nil")
 		}
@@ -454,7 +456,7 @@ func TestSetDefaultVolume(t *testing.T) {
 	t.Run("propagates the target volume to the CLI", func(t *testing.T) {
 		stub := &stubAppCenter{}
 		p := &installPipeline{queue: NewOperationQueue(), ac: stub}
-		if err := p.setDefaultVolume(3); err != nil {
+		if err := p.setDefaultVolume(context.Background(), 3); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 		if len(stub.setVolCalls) != 1 || stub.setVolCalls[0] != 3 {
@@ -465,7 +467,7 @@ func TestSetDefaultVolume(t *testing.T) {
 	t.Run("surfaces a CLI failure", func(t *testing.T) {
 		stub := &stubAppCenter{setVolErr: errors.New("cli boom")}
 		p := &installPipeline{queue: NewOperationQueue(), ac: stub}
-		if err := p.setDefaultVolume(1); err == nil {
+		if err := p.setDefaultVolume(context.Background(), 1); err == nil {
 			t.Fatal("expected error, got nil")
 		}
 	})
@@ -478,7 +480,7 @@ func TestSetDefaultVolume(t *testing.T) {
 	t.Run("fails closed when the setter is silently ignored", func(t *testing.T) {
 		stub := &stubAppCenter{curVol: 0, setVolIgnored: true}
 		p := &installPipeline{queue: NewOperationQueue(), ac: stub}
-		err := p.setDefaultVolume(2)
+		err := p.setDefaultVolume(context.Background(), 2)
 		if err == nil {
 			t.Fatal("expected error when default-volume set is a no-op, got nil")
 		}
@@ -499,7 +501,7 @@ func TestSetDefaultVolume(t *testing.T) {
 			volumes:       []platform.VolumeInfo{{Index: 1, Path: "/vol1"}},
 		}
 		p := &installPipeline{queue: NewOperationQueue(), ac: stub}
-		if err := p.setDefaultVolume(1); err == nil {
+		if err := p.setDefaultVolume(context.Background(), 1); err == nil {
 			t.Fatal("expected the pin verification to still fail closed")
 		}
 	})
@@ -507,7 +509,7 @@ func TestSetDefaultVolume(t *testing.T) {
 	t.Run("fails closed when the value cannot be read back", func(t *testing.T) {
 		stub := &stubAppCenter{getVolErr: errors.New("read boom")}
 		p := &installPipeline{queue: NewOperationQueue(), ac: stub}
-		if err := p.setDefaultVolume(2); err == nil {
+		if err := p.setDefaultVolume(context.Background(), 2); err == nil {
 			t.Fatal("expected error when read-back fails, got nil")
 		}
 	})
@@ -538,7 +540,7 @@ func TestResolveVolume(t *testing.T) {
 		if err := p.configMgr.SaveConfig(cfg); err != nil {
 			t.Fatal(err)
 		}
-		got, err := p.resolveVolume()
+		got, err := p.resolveVolume(context.Background())
 		if err != nil || got != 2 {
 			t.Fatalf("resolveVolume() = (%d, %v), want (2, nil)", got, err)
 		}
@@ -550,7 +552,7 @@ func TestResolveVolume(t *testing.T) {
 			volumes: []platform.VolumeInfo{{Index: 1, Path: "/vol1"}, {Index: 2, Path: "/vol2"}},
 		}
 		p := &installPipeline{queue: NewOperationQueue(), ac: stub}
-		got, err := p.resolveVolume()
+		got, err := p.resolveVolume(context.Background())
 		if err != nil || got != 2 {
 			t.Fatalf("resolveVolume() = (%d, %v), want (2, nil)", got, err)
 		}
@@ -560,7 +562,7 @@ func TestResolveVolume(t *testing.T) {
 	t.Run("falls back to the only mounted volume when the default is unusable", func(t *testing.T) {
 		stub := &stubAppCenter{curVol: -1, volumes: []platform.VolumeInfo{{Index: 1, Path: "/vol1"}}}
 		p := &installPipeline{queue: NewOperationQueue(), ac: stub}
-		got, err := p.resolveVolume()
+		got, err := p.resolveVolume(context.Background())
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -576,7 +578,7 @@ func TestResolveVolume(t *testing.T) {
 			volumes: []platform.VolumeInfo{{Index: 1, Path: "/vol1"}, {Index: 2, Path: "/vol2"}},
 		}
 		p := &installPipeline{queue: NewOperationQueue(), ac: stub}
-		_, err := p.resolveVolume()
+		_, err := p.resolveVolume(context.Background())
 		if err == nil {
 			t.Fatal("expected an error when the choice is ambiguous, got nil")
 		}
@@ -591,7 +593,7 @@ func TestResolveVolume(t *testing.T) {
 			volumes:   []platform.VolumeInfo{{Index: 1, Path: "/vol1"}},
 		}
 		p := &installPipeline{queue: NewOperationQueue(), ac: stub}
-		got, err := p.resolveVolume()
+		got, err := p.resolveVolume(context.Background())
 		if err != nil || got != 1 {
 			t.Fatalf("resolveVolume() = (%d, %v), want (1, nil)", got, err)
 		}
@@ -666,7 +668,7 @@ func TestVerifyPayloadLanded(t *testing.T) {
 	t.Run("accepts a real install at the shipped version", func(t *testing.T) {
 		appsDir, _ := newApp(t, "2.63.19", true)
 		p := &installPipeline{queue: NewOperationQueue(), ac: &stubAppCenter{}, appsDir: appsDir}
-		if err := p.verifyPayloadLanded(appName, 0, "2.63.19"); err != nil {
+		if err := p.verifyPayloadLanded(context.Background(), appName, 0, "2.63.19"); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 	})
@@ -676,7 +678,7 @@ func TestVerifyPayloadLanded(t *testing.T) {
 	t.Run("rejects an update whose version never changed", func(t *testing.T) {
 		appsDir, _ := newApp(t, "2.63.18", true)
 		p := &installPipeline{queue: NewOperationQueue(), ac: &stubAppCenter{}, appsDir: appsDir}
-		err := p.verifyPayloadLanded(appName, 0, "2.63.19")
+		err := p.verifyPayloadLanded(context.Background(), appName, 0, "2.63.19")
 		if err == nil {
 			t.Fatal("expected error when the version did not change, got nil")
 		}
@@ -689,7 +691,7 @@ func TestVerifyPayloadLanded(t *testing.T) {
 	t.Run("rejects an empty install directory", func(t *testing.T) {
 		appsDir, _ := newApp(t, "2.63.19", false)
 		p := &installPipeline{queue: NewOperationQueue(), ac: &stubAppCenter{}, appsDir: appsDir}
-		err := p.verifyPayloadLanded(appName, 0, "2.63.19")
+		err := p.verifyPayloadLanded(context.Background(), appName, 0, "2.63.19")
 		if err == nil {
 			t.Fatal("expected error for an empty install dir, got nil")
 		}
@@ -704,7 +706,7 @@ func TestVerifyPayloadLanded(t *testing.T) {
 			t.Fatal(err)
 		}
 		p := &installPipeline{queue: NewOperationQueue(), ac: &stubAppCenter{}, appsDir: appsDir}
-		if err := p.verifyPayloadLanded(appName, 0, "2.63.19"); err == nil {
+		if err := p.verifyPayloadLanded(context.Background(), appName, 0, "2.63.19"); err == nil {
 			t.Fatal("expected error for a missing install dir, got nil")
 		}
 	})
@@ -717,7 +719,7 @@ func TestVerifyPayloadLanded(t *testing.T) {
 	t.Run("accepts a revision package by fpk_version", func(t *testing.T) {
 		appsDir := newAppRev(t, "1.9.3", "1.9.3-r2")
 		p := &installPipeline{queue: NewOperationQueue(), ac: &stubAppCenter{}, appsDir: appsDir}
-		if err := p.verifyPayloadLanded(appName, 0, "1.9.3-r2"); err != nil {
+		if err := p.verifyPayloadLanded(context.Background(), appName, 0, "1.9.3-r2"); err != nil {
 			t.Fatalf("revision package must be accepted, got: %v", err)
 		}
 	})
@@ -725,7 +727,7 @@ func TestVerifyPayloadLanded(t *testing.T) {
 	t.Run("still rejects a revision package that did not upgrade", func(t *testing.T) {
 		appsDir := newAppRev(t, "1.9.3", "1.9.3-r1")
 		p := &installPipeline{queue: NewOperationQueue(), ac: &stubAppCenter{}, appsDir: appsDir}
-		err := p.verifyPayloadLanded(appName, 0, "1.9.3-r2")
+		err := p.verifyPayloadLanded(context.Background(), appName, 0, "1.9.3-r2")
 		if err == nil {
 			t.Fatal("expected error when fpk_version did not change, got nil")
 		}
@@ -737,7 +739,7 @@ func TestVerifyPayloadLanded(t *testing.T) {
 	t.Run("falls back to version when no fpk_version is shipped", func(t *testing.T) {
 		appsDir := newAppRev(t, "2.63.19", "")
 		p := &installPipeline{queue: NewOperationQueue(), ac: &stubAppCenter{}, appsDir: appsDir}
-		if err := p.verifyPayloadLanded(appName, 0, "2.63.19"); err != nil {
+		if err := p.verifyPayloadLanded(context.Background(), appName, 0, "2.63.19"); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 	})
@@ -755,7 +757,7 @@ func TestVerifyPayloadLanded(t *testing.T) {
 		volRoot := filepath.Dir(filepath.Dir(resolvedVol)) // .../vol1
 		stub := &stubAppCenter{volumes: []platform.VolumeInfo{{Index: 2, Path: volRoot}}}
 		p := &installPipeline{queue: NewOperationQueue(), ac: stub, appsDir: appsDir}
-		err = p.verifyPayloadLanded(appName, 1, "2.63.19")
+		err = p.verifyPayloadLanded(context.Background(), appName, 1, "2.63.19")
 		if err == nil {
 			t.Fatal("expected error when the app landed on another volume, got nil")
 		}
@@ -790,7 +792,7 @@ func TestUpgradeGuardBlocksDestructivePath(t *testing.T) {
 
 	t.Run("update is refused before any destructive call", func(t *testing.T) {
 		p, stub := newPipeline(true)
-		if err := p.requireSafeUpgrade(); err == nil {
+		if err := p.requireSafeUpgrade(context.Background()); err == nil {
 			t.Fatal("expected the update to be refused on an unsafe build")
 		}
 		if n := atomic.LoadInt32(&stub.nInstallLocal); n != 0 {
@@ -803,7 +805,7 @@ func TestUpgradeGuardBlocksDestructivePath(t *testing.T) {
 
 	t.Run("refusal explains the manual route", func(t *testing.T) {
 		p, _ := newPipeline(true)
-		err := p.requireSafeUpgrade()
+		err := p.requireSafeUpgrade(context.Background())
 		if err == nil {
 			t.Fatal("expected an error")
 		}
@@ -816,7 +818,7 @@ func TestUpgradeGuardBlocksDestructivePath(t *testing.T) {
 	// install-fpk on a not-installed app works fine on the affected build.
 	t.Run("safe builds are unaffected", func(t *testing.T) {
 		p, _ := newPipeline(false)
-		if err := p.requireSafeUpgrade(); err != nil {
+		if err := p.requireSafeUpgrade(context.Background()); err != nil {
 			t.Fatalf("update must proceed on a safe build, got: %v", err)
 		}
 	})
@@ -888,3 +890,42 @@ func TestChooseInstallRoute(t *testing.T) {
 }
 
 var errSimulatedUpgrade = errors.New("simulated upgrade failure")
+
+// TestAcquireSlot locks the stage limits: a full stage reports waiting and
+// admits the next operation once a slot is given back, and a wait can be
+// cancelled.
+func TestAcquireSlot(t *testing.T) {
+	slots := make(chan struct{}, 1)
+	waiting := progressPayload{Step: "installing", Message: "waiting"}
+	release, err := acquireSlot(context.Background(), newBackgroundStream(context.Background(), "a"), slots, waiting)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream := newBackgroundStream(context.Background(), "b")
+	acquired := make(chan func())
+	go func() {
+		next, _ := acquireSlot(context.Background(), stream, slots, waiting)
+		acquired <- next
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("second operation entered a full stage")
+	case <-time.After(50 * time.Millisecond):
+	}
+	release()
+	(<-acquired)()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	slots <- struct{}{}
+	cancel()
+	if _, err := acquireSlot(ctx, newBackgroundStream(ctx, "c"), slots, waiting); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled wait: err = %v, want context.Canceled", err)
+	}
+
+	if release, err := acquireSlot(ctx, stream, nil, waiting); err != nil {
+		t.Errorf("unbounded stage: err = %v", err)
+	} else {
+		release()
+	}
+}
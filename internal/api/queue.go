@@ -1,6 +1,11 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 )
@@ -8,6 +13,52 @@ import (
 type activeOp struct {
 	Operation string
 	StartedAt time.Time
+	Result    string
+	Message   string
+
+	// cancel stops the operation; nil when it cannot be cancelled.
+	cancel context.CancelCauseFunc
+	// destructive is set once the operation has started changing the
+	// installed app, after which it runs to the end.
+	destructive bool
+	cancelled   bool
+	done        chan struct{} // closed when the operation finishes
+}
+
+func newActiveOp(operation string) *activeOp {
+	return &activeOp{Operation: operation, StartedAt: time.Now(), done: make(chan struct{})}
+}
+
+// errOperationCancelled is the cause of an operation cancelled through the
+// API, telling it apart from a client that went away.
+var errOperationCancelled = errors.New("operation cancelled")
+
+// Reasons Cancel refuses.
+var (
+	errNoOperation    = errors.New("no operation is running for this app")
+	errNotCancellable = errors.New("this operation cannot be cancelled")
+	errPastCancel     = errors.New("the operation is already changing the app and cannot be cancelled")
+)
+
+// Operation results recorded in the history.
+const (
+	opResultDone        = "done"
+	opResultError       = "error"
+	opResultCancelled   = "cancelled"
+	opResultInterrupted = "interrupted" // finished without reporting either
+)
+
+// maxOperationHistory bounds the in-memory history across all apps.
+const maxOperationHistory = 100
+
+// OperationRecord is one finished operation.
+type OperationRecord struct {
+	AppName    string    `json:"appname"`
+	Operation  string    `json:"operation"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Result     string    `json:"result"`
+	Message    string    `json:"message,omitempty"`
 }
 
 type QueueStatus struct {
@@ -22,11 +73,16 @@ type OperationQueue struct {
 	cliMu            sync.Mutex
 	activeOps        map[string]*activeOp
 	selfUpdateActive bool
+	history          []OperationRecord
+	// recovered holds the interrupted operations found on startup that did
+	// not complete, until the app's next operation starts.
+	recovered map[string]OperationRecord
 }
 
 func NewOperationQueue() *OperationQueue {
 	return &OperationQueue{
 		activeOps: make(map[string]*activeOp),
+		recovered: make(map[string]OperationRecord),
 	}
 }
 
@@ -42,10 +98,8 @@ func (q *OperationQueue) TryStart(operation, appname string) bool {
 		return false
 	}
 
-	q.activeOps[appname] = &activeOp{
-		Operation: operation,
-		StartedAt: time.Now(),
-	}
+	q.activeOps[appname] = newActiveOp(operation)
+	delete(q.recovered, appname)
 	return true
 }
 
@@ -59,10 +113,8 @@ func (q *OperationQueue) TryStartExclusive(operation, appname string) bool {
 	}
 
 	q.selfUpdateActive = true
-	q.activeOps[appname] = &activeOp{
-		Operation: operation,
-		StartedAt: time.Now(),
-	}
+	q.activeOps[appname] = newActiveOp(operation)
+	delete(q.recovered, appname)
 	return true
 }
 
@@ -70,6 +122,9 @@ func (q *OperationQueue) TryStartExclusive(operation, appname string) bool {
 func (q *OperationQueue) Finish() {
 	q.mu.Lock()
 	defer q.mu.Unlock()
+	for _, op := range q.activeOps {
+		close(op.done)
+	}
 	q.activeOps = make(map[string]*activeOp)
 	q.selfUpdateActive = false
 }
@@ -77,16 +132,160 @@ func (q *OperationQueue) Finish() {
 func (q *OperationQueue) FinishApp(appname string) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
-	delete(q.activeOps, appname)
+	q.recordLocked(appname)
+	q.removeLocked(appname)
 }
 
 func (q *OperationQueue) FinishExclusive(appname string) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
-	delete(q.activeOps, appname)
+	q.recordLocked(appname)
+	q.removeLocked(appname)
 	q.selfUpdateActive = false
 }
 
+// SetResult records how appname's active operation ended; it is kept in the
+// history once the operation finishes. Without it the operation is recorded
+// as interrupted.
+func (q *OperationQueue) SetResult(appname, result, message string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if op, ok := q.activeOps[appname]; ok {
+		op.Result = result
+		op.Message = message
+	}
+}
+
+// FinishRecovered finishes the reconciliation of an interrupted operation
+// like FinishApp, keeping it listed as recovered unless it completed.
+func (q *OperationQueue) FinishRecovered(appname string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	op, ok := q.activeOps[appname]
+	if !ok {
+		return
+	}
+	q.recordLocked(appname)
+	q.removeLocked(appname)
+	if op.Result != opResultDone {
+		q.recovered[appname] = q.history[len(q.history)-1]
+	}
+}
+
+// RecoveredOperation returns appname's interrupted operation that did not
+// complete, if any.
+func (q *OperationQueue) RecoveredOperation(appname string) (OperationRecord, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	rec, ok := q.recovered[appname]
+	return rec, ok
+}
+
+// Recovered returns every interrupted operation that did not complete.
+func (q *OperationQueue) Recovered() []OperationRecord {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	records := slices.Collect(maps.Values(q.recovered))
+	slices.SortFunc(records, func(a, b OperationRecord) int {
+		return strings.Compare(a.AppName, b.AppName)
+	})
+	return records
+}
+
+func (q *OperationQueue) removeLocked(appname string) {
+	if op, ok := q.activeOps[appname]; ok {
+		close(op.done)
+		delete(q.activeOps, appname)
+	}
+}
+
+// Cancellable derives the context of appname's active operation and lets
+// Cancel stop it until BeginDestructive. The returned func releases the
+// context once the operation is over.
+func (q *OperationQueue) Cancellable(parent context.Context, appname string) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(parent)
+	q.mu.Lock()
+	if op, ok := q.activeOps[appname]; ok {
+		op.cancel = cancel
+	}
+	q.mu.Unlock()
+	return ctx, func() { cancel(nil) }
+}
+
+// BeginDestructive marks appname's operation as past the point where it can
+// be cancelled: it is about to change the installed app, and stopping
+// partway would leave it broken. It reports false when the operation was
+// cancelled already and must not go on.
+func (q *OperationQueue) BeginDestructive(appname string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	op, ok := q.activeOps[appname]
+	if !ok {
+		return true
+	}
+	if op.cancelled {
+		return false
+	}
+	op.destructive = true
+	return true
+}
+
+// Cancel stops appname's active operation. It returns a channel closed once
+// the operation has finished and recorded its result.
+func (q *OperationQueue) Cancel(appname string) (<-chan struct{}, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	op, ok := q.activeOps[appname]
+	switch {
+	case !ok:
+		return nil, errNoOperation
+	case op.cancel == nil:
+		return nil, errNotCancellable
+	case op.destructive:
+		return nil, errPastCancel
+	}
+	op.cancelled = true
+	op.cancel(errOperationCancelled)
+	return op.done, nil
+}
+
+func (q *OperationQueue) recordLocked(appname string) {
+	op, ok := q.activeOps[appname]
+	if !ok {
+		return
+	}
+	result := op.Result
+	if result == "" {
+		result = opResultInterrupted
+	}
+	q.history = append(q.history, OperationRecord{
+		AppName:    appname,
+		Operation:  op.Operation,
+		StartedAt:  op.StartedAt,
+		FinishedAt: time.Now(),
+		Result:     result,
+		Message:    op.Message,
+	})
+	if len(q.history) > maxOperationHistory {
+		q.history = q.history[len(q.history)-maxOperationHistory:]
+	}
+}
+
+// History returns appname's finished operations, newest first; an empty
+// appname returns every app's.
+func (q *OperationQueue) History(appname string) []OperationRecord {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	records := make([]OperationRecord, 0, len(q.history))
+	for i := len(q.history) - 1; i >= 0; i-- {
+		if appname == "" || q.history[i].AppName == appname {
+			records = append(records, q.history[i])
+		}
+	}
+	return records
+}
+
 // Status returns first active op. Task 3 migrates call sites to ActiveOps.
 func (q *OperationQueue) Status() QueueStatus {
 	q.mu.Lock()
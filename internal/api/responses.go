@@ -1,6 +1,9 @@
 package api
 
-import "fnos-store/internal/diagnostics"
+import (
+	"fnos-store/internal/diagnostics"
+	"fnos-store/internal/platform"
+)
 
 type appResponse struct {
 	AppName          string `json:"appname"`
@@ -24,6 +27,13 @@ type appResponse struct {
 	AppType          string `json:"app_type,omitempty"`
 	Category         string `json:"category,omitempty"`
 	PostInstallNote  string `json:"post_install_note,omitempty"`
+	// Compatible is false when the catalog's build cannot run on this box;
+	// IncompatibleReason says why.
+	Compatible         bool   `json:"compatible"`
+	IncompatibleReason string `json:"incompatible_reason,omitempty"`
+	// UpgradeBlockedReason is set when the catalog's safety list refuses
+	// updating this app.
+	UpgradeBlockedReason string `json:"upgrade_blocked_reason,omitempty"`
 }
 
 type appsListResponse struct {
@@ -34,8 +44,14 @@ type appsListResponse struct {
 	UpgradeAllowed       bool   `json:"upgrade_allowed"`
 	UpgradeBlockedReason string `json:"upgrade_blocked_reason,omitempty"`
 
-	Apps      []appResponse `json:"apps"`
-	LastCheck string        `json:"last_check"`
+	Apps []appResponse `json:"apps"`
+	// Total counts the apps matching the query, across all pages. Page and
+	// PageSize are set when the request asked for a page.
+	Total     int              `json:"total"`
+	Page      int              `json:"page,omitempty"`
+	PageSize  int              `json:"page_size,omitempty"`
+	LastCheck string           `json:"last_check"`
+	Catalog   *catalogResponse `json:"catalog,omitempty"`
 }
 
 type recommendedAppResponse struct {
@@ -60,14 +76,25 @@ type checkResponse struct {
 }
 
 type statusResponse struct {
-	Status    string        `json:"status"`
-	Busy      bool          `json:"busy"`
-	Operation string        `json:"operation"`
-	AppName   string        `json:"appname"`
-	StartedAt string        `json:"started_at"`
-	LastCheck string        `json:"last_check"`
-	Platform  string        `json:"platform"`
-	ActiveOps []QueueStatus `json:"active_operations,omitempty"`
+	Status    string           `json:"status"`
+	Busy      bool             `json:"busy"`
+	Operation string           `json:"operation"`
+	AppName   string           `json:"appname"`
+	StartedAt string           `json:"started_at"`
+	LastCheck string           `json:"last_check"`
+	Platform  string           `json:"platform"`
+	ActiveOps []QueueStatus    `json:"active_operations,omitempty"`
+	Catalog   *catalogResponse `json:"catalog,omitempty"`
+
+	// Recovered lists the operations interrupted by a restart that did not
+	// complete; POST /api/apps/{appname}/resume runs one again.
+	Recovered []OperationRecord `json:"recovered_operations,omitempty"`
+	// HungCommands lists the appcenter-cli commands running far longer than
+	// they should; they are killed once past their time limit.
+	HungCommands []platform.HungCommand `json:"hung_commands,omitempty"`
+	// SigningKeyWarning says why the signed safety list and mirror registry
+	// are ignored: the build carries no key to verify them with.
+	SigningKeyWarning string `json:"signing_key_warning,omitempty"`
 }
 
 type storeUpdateResponse struct {
@@ -5,6 +5,7 @@ import (
 	"fnos-store/internal/cache"
 	"fnos-store/internal/config"
 	"fnos-store/internal/core"
+	"fnos-store/internal/docker"
 	"fnos-store/internal/platform"
 	"fnos-store/internal/scheduler"
 	"fnos-store/internal/source"
@@ -21,21 +22,27 @@ type Server struct {
 	ac                platform.AppCenter
 	source            source.Source
 	recommendedSource *source.RecommendedSource
+	safetySource      *source.SafetySource
+	mirrorsSource     *source.MirrorsSource
 	registry          *core.Registry
 	queue             *OperationQueue
 	pipeline          *installPipeline
 	configMgr         *config.Manager
 	cacheStore        *cache.Store
+	docker            *docker.Client
+	httpClient        *http.Client // outbound requests, through the configured proxy
 	scheduler         *scheduler.Scheduler
 	appsDir           string
 	platform          string
 	storeApp          string
 	staticFS          fs.FS
 	lastCheck         time.Time
+	catalog           source.Provenance
 	statusByApp       map[string]string
 	recommendedApps   []source.RecommendedApp
 
 	mu               sync.RWMutex
+	mirrorScoreMu    sync.Mutex
 	refreshDebouncer *refreshDebouncer
 }
 
@@ -43,11 +50,15 @@ type Config struct {
 	AppCenter         platform.AppCenter
 	Source            source.Source
 	RecommendedSource *source.RecommendedSource
+	SafetySource      *source.SafetySource
+	MirrorsSource     *source.MirrorsSource
 	Registry          *core.Registry
 	Downloader        *core.Downloader
 	ConfigMgr         *config.Manager
 	CacheStore        *cache.Store
+	Docker            *docker.Client
 	Scheduler         *scheduler.Scheduler
+	Journal           *Journal
 	AppsDir           string
 	Platform          string
 	StoreApp          string
@@ -61,18 +72,27 @@ func NewServer(cfg Config) *Server {
 		ac:                cfg.AppCenter,
 		source:            cfg.Source,
 		recommendedSource: cfg.RecommendedSource,
+		safetySource:      cfg.SafetySource,
+		mirrorsSource:     cfg.MirrorsSource,
 		registry:          cfg.Registry,
 		queue:             queue,
 		pipeline: &installPipeline{
 			downloads:  cfg.Downloader,
 			ac:         cfg.AppCenter,
 			queue:      queue,
+			journal:    cfg.Journal,
 			appsDir:    cfg.AppsDir,
 			configMgr:  cfg.ConfigMgr,
 			cacheStore: cfg.CacheStore,
+			docker:     cfg.Docker,
+
+			fetchSlots:  make(chan struct{}, maxParallelFetches),
+			installSlot: make(chan struct{}, 1),
 		},
 		configMgr:        cfg.ConfigMgr,
 		cacheStore:       cfg.CacheStore,
+		docker:           cfg.Docker,
+		httpClient:       &http.Client{Transport: cfg.ConfigMgr.HTTPTransport()},
 		scheduler:        cfg.Scheduler,
 		appsDir:          cfg.AppsDir,
 		platform:         cfg.Platform,
@@ -81,6 +101,10 @@ func NewServer(cfg Config) *Server {
 		statusByApp:      make(map[string]string),
 		refreshDebouncer: &refreshDebouncer{},
 	}
+	if cfg.ConfigMgr != nil {
+		applyProxy(cfg.ConfigMgr.Get())
+		s.applyDownloadLimit(cfg.ConfigMgr.Get())
+	}
 	s.routes()
 	_ = s.refreshRecommended(context.Background())
 	_ = s.refreshRegistry(context.Background())
@@ -93,10 +117,18 @@ func (s *Server) routes() {
 	s.Mux.HandleFunc("POST /api/apps/{appname}/install", s.handleInstall)
 	s.Mux.HandleFunc("POST /api/apps/{appname}/update", s.handleUpdate)
 	s.Mux.HandleFunc("POST /api/apps/{appname}/uninstall", s.handleUninstall)
+	s.Mux.HandleFunc("DELETE /api/apps/{appname}/operation", s.handleCancelOperation)
+	s.Mux.HandleFunc("POST /api/apps/{appname}/resume", s.handleResumeOperation)
 	s.Mux.HandleFunc("GET /api/apps/{appname}/download", s.handleDownloadFpk)
 	s.Mux.HandleFunc("GET /api/apps/{appname}/wizard", s.handleGetWizard)
+	s.Mux.HandleFunc("GET /api/apps/{appname}/changelog", s.handleGetChangelog)
 	s.Mux.HandleFunc("GET /api/apps/{appname}/logs", s.handleGetAppLogs)
+	s.Mux.HandleFunc("GET /api/apps/{appname}/logs/stream", s.handleStreamAppLogs)
 	s.Mux.HandleFunc("GET /api/apps/{appname}/diagnostic", s.handleGetAppDiagnostic)
+	s.Mux.HandleFunc("GET /api/apps/{appname}/support-bundle", s.handleGetSupportBundle)
+	s.Mux.HandleFunc("GET /api/apps/{appname}/services", s.handleGetAppServices)
+	s.Mux.HandleFunc("GET /api/apps/{appname}/images", s.handleGetAppImages)
+	s.Mux.HandleFunc("POST /api/apps/{appname}/images/prune", s.handlePruneAppImages)
 	s.Mux.HandleFunc("PUT /api/apps/{appname}/ignore-update", s.handleIgnoreUpdate)
 	s.Mux.HandleFunc("DELETE /api/apps/{appname}/ignore-update", s.handleUnignoreUpdate)
 	s.Mux.HandleFunc("POST /api/apps/reload", s.handleReloadApps)
@@ -107,6 +139,8 @@ func (s *Server) routes() {
 	s.Mux.HandleFunc("GET /api/store-update", s.handleGetStoreUpdate)
 	s.Mux.HandleFunc("POST /api/store-update", s.handlePostStoreUpdate)
 	s.Mux.HandleFunc("POST /api/mirrors/check", s.handleCheckMirrors)
+	s.Mux.HandleFunc("GET /api/mirrors/scores", s.handleGetMirrorScores)
+	s.Mux.HandleFunc("GET /api/scheduler", s.handleGetScheduler)
 	s.Mux.HandleFunc("/", s.handleSPA)
 }
 
@@ -140,7 +174,3 @@ func (s *Server) handleSPA(w http.ResponseWriter, r *http.Request) {
 
 	http.ServeFileFS(w, r, s.staticFS, "web/index.html")
 }
-
-func (s *Server) SetScheduler(sched *scheduler.Scheduler) {
-	s.scheduler = sched
-}
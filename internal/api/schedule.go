@@ -0,0 +1,295 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"fnos-store/internal/config"
+	"fnos-store/internal/core"
+	"fnos-store/internal/scheduler"
+)
+
+// Maintenance jobs run by the scheduler.
+const (
+	jobCatalogRefresh     = "catalog-refresh"
+	jobRecommendedRefresh = "recommended-refresh"
+	jobAutoUpdate         = "auto-update"
+	jobCacheCleanup       = "cache-cleanup"
+	jobHealthCheck        = "health-check"
+	jobMirrorBenchmark    = "mirror-benchmark"
+)
+
+// defaultJobSpecs are the schedules used when the config does not override
+// them. catalog-refresh follows check_interval_hours instead (see jobSpec);
+// auto-update is off until the user turns it on. mirror-benchmark only does
+// anything while the "auto" mirror mode is selected.
+var defaultJobSpecs = map[string]string{
+	jobRecommendedRefresh: "@every 12h",
+	jobAutoUpdate:         "",
+	jobCacheCleanup:       "30 3 * * *",
+	jobHealthCheck:        "*/15 * * * *",
+	jobMirrorBenchmark:    "@every 6h",
+}
+
+type schedulerResponse struct {
+	Timezone string                `json:"timezone"`
+	Jobs     []scheduler.JobStatus `json:"jobs"`
+}
+
+// jobSpec returns the schedule of a job under cfg.
+func jobSpec(name string, cfg config.Config) string {
+	if spec, ok := cfg.Schedules[name]; ok {
+		return spec
+	}
+	if name == jobCatalogRefresh {
+		hours := cfg.CheckIntervalHours
+		if hours < 1 {
+			hours = config.DefaultCheckIntervalHours
+		}
+		return fmt.Sprintf("@every %dh", hours)
+	}
+	return defaultJobSpecs[name]
+}
+
+// validateSchedules checks a schedules override and timezone before they are
+// saved.
+func validateSchedules(schedules map[string]string, timezone string) error {
+	loc := time.Local
+	if timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(timezone); err != nil {
+			return fmt.Errorf("invalid timezone %q", timezone)
+		}
+	}
+	for name, spec := range schedules {
+		if _, known := defaultJobSpecs[name]; !known && name != jobCatalogRefresh {
+			return fmt.Errorf("unknown job %q", name)
+		}
+		if spec == "" {
+			continue
+		}
+		if _, err := scheduler.Parse(spec, loc); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// SetScheduler hands the server its scheduler and registers the maintenance
+// jobs on it with the configured schedules. A schedule that no longer parses
+// falls back to the job's default.
+func (s *Server) SetScheduler(sched *scheduler.Scheduler) {
+	s.scheduler = sched
+	if sched == nil {
+		return
+	}
+
+	var cfg config.Config
+	if s.configMgr != nil {
+		cfg = s.configMgr.Get()
+	}
+	if err := sched.SetLocation(cfg.ScheduleLocation()); err != nil {
+		log.Printf("scheduler: %v", err)
+	}
+
+	var lastCheck func() time.Time
+	if s.cacheStore != nil {
+		lastCheck = s.cacheStore.LastCheckAt
+	}
+	jobs := []scheduler.Job{
+		{Name: jobCatalogRefresh, Jitter: 10 * time.Minute, Run: s.refreshRegistry, LastRun: lastCheck},
+		{Name: jobRecommendedRefresh, Jitter: 10 * time.Minute, Run: s.refreshRecommended},
+		{Name: jobAutoUpdate, Jitter: 30 * time.Minute, Run: s.runAutoUpdate},
+		{Name: jobCacheCleanup, Jitter: 5 * time.Minute, Run: s.runCacheCleanup},
+		{Name: jobHealthCheck, Jitter: time.Minute, Run: s.runHealthCheck},
+		{Name: jobMirrorBenchmark, Jitter: 10 * time.Minute, Run: s.runMirrorBenchmark, LastRun: lastMirrorBenchmark},
+	}
+	for _, job := range jobs {
+		job.Spec = jobSpec(job.Name, cfg)
+		if err := sched.Add(job); err != nil {
+			log.Printf("scheduler: %v; using default schedule", err)
+			job.Spec = jobSpec(job.Name, config.Config{CheckIntervalHours: cfg.CheckIntervalHours})
+			if err := sched.Add(job); err != nil {
+				log.Printf("scheduler: %v", err)
+			}
+		}
+	}
+}
+
+// applySchedules updates the running scheduler after the config changed.
+func (s *Server) applySchedules(cfg config.Config) {
+	if s.scheduler == nil {
+		return
+	}
+	if err := s.scheduler.SetLocation(cfg.ScheduleLocation()); err != nil {
+		log.Printf("scheduler: %v", err)
+	}
+	for _, name := range []string{jobCatalogRefresh, jobRecommendedRefresh, jobAutoUpdate, jobCacheCleanup, jobHealthCheck, jobMirrorBenchmark} {
+		if err := s.scheduler.SetSpec(name, jobSpec(name, cfg)); err != nil {
+			log.Printf("scheduler: %v", err)
+		}
+	}
+}
+
+func (s *Server) handleGetScheduler(w http.ResponseWriter, _ *http.Request) {
+	if s.scheduler == nil {
+		writeAPIError(w, http.StatusServiceUnavailable, "scheduler not running")
+		return
+	}
+	writeJSON(w, http.StatusOK, schedulerResponse{
+		Timezone: s.scheduler.Location().String(),
+		Jobs:     s.scheduler.Status(),
+	})
+}
+
+// runAutoUpdate updates every app with an update available, skipping ignored
+// apps, apps whose new build this box cannot run or the safety list blocks,
+// and the store itself (its self-update restarts the process). The updates
+// run together: the pipeline overlaps their downloads with each other's
+// installs, which still happen one at a time. Apps busy with another
+// operation wait for the next run; one the user cancels is not a failure.
+func (s *Server) runAutoUpdate(ctx context.Context) error {
+	if upgradeCap := s.ac.UpgradeCapability(ctx); !upgradeCap.Allowed {
+		return fmt.Errorf("%w: %s", scheduler.ErrSkipped, upgradeCap.Reason)
+	}
+	if len(s.autoUpdateCandidates(s.configMgr.Get())) == 0 {
+		return nil
+	}
+
+	waited, err := waitOffPeak(ctx, s.configMgr.Get())
+	if err != nil {
+		return err
+	}
+	if waited {
+		// Hours may have passed: the catalog, the settings and what the
+		// platform allows are all taken again as the window opens.
+		if err := s.refreshRegistry(ctx); err != nil {
+			log.Printf("auto-update: refresh before updating: %v", err)
+		}
+		if upgradeCap := s.ac.UpgradeCapability(ctx); !upgradeCap.Allowed {
+			return fmt.Errorf("%w: %s", scheduler.ErrSkipped, upgradeCap.Reason)
+		}
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		failed  []string
+		started bool
+	)
+	for _, app := range s.autoUpdateCandidates(s.configMgr.Get()) {
+		if !s.queue.TryStart("update", app.AppName) {
+			continue
+		}
+		started = true
+		log.Printf("auto-update: updating %s to %s", app.AppName, app.LatestVersion)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stream := newBackgroundStream(ctx, app.AppName)
+			opCtx, release := s.queue.Cancellable(ctx, app.AppName)
+			s.pipeline.runStandard(opCtx, stream, "update", app, s.pipeline.newOpEnv(nil), nil)
+			release()
+			stream.recordResult(s.queue)
+			s.queue.FinishApp(app.AppName)
+			if stream.result != opResultDone && stream.result != opResultCancelled {
+				mu.Lock()
+				failed = append(failed, fmt.Sprintf("%s: %s", app.AppName, stream.resultMessage))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if started {
+		// One refresh for the whole run, not one per app inside the install
+		// stage.
+		if err := s.refreshRegistry(context.WithoutCancel(ctx)); err != nil {
+			log.Printf("auto-update: refresh after updating: %v", err)
+		}
+	}
+	if len(failed) > 0 {
+		slices.Sort(failed)
+		return errors.New(strings.Join(failed, "; "))
+	}
+	return ctx.Err()
+}
+
+// autoUpdateCandidates lists the installed apps auto-update would update
+// under cfg.
+func (s *Server) autoUpdateCandidates(cfg config.Config) []core.AppInfo {
+	var apps []core.AppInfo
+	for _, app := range s.listRegistryApps() {
+		if app.Status != core.AppStatusUpdateAvailable || app.IncompatibleReason != "" || app.UpgradeBlockedReason != "" || cfg.IsAppIgnored(app.AppName) || app.AppName == s.storeApp {
+			continue
+		}
+		apps = append(apps, app)
+	}
+	return apps
+}
+
+// waitOffPeak holds a scheduled download until the off-peak window is open,
+// so daytime traffic keeps the link. It returns at once without a window,
+// reports whether it had to wait, and only fails when ctx ends.
+func waitOffPeak(ctx context.Context, cfg config.Config) (bool, error) {
+	if err := ctx.Err(); err != nil || cfg.OffPeakWindow == "" {
+		return false, err
+	}
+	window, err := scheduler.ParseWindow(cfg.OffPeakWindow)
+	if err != nil {
+		log.Printf("auto-update: ignoring off-peak window: %v", err)
+		return false, nil
+	}
+	wait := window.Until(time.Now().In(cfg.ScheduleLocation()))
+	if wait == 0 {
+		return false, nil
+	}
+	log.Printf("auto-update: waiting %s for the off-peak window %s", wait.Round(time.Minute), cfg.OffPeakWindow)
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return true, ctx.Err()
+	case <-t.C:
+		return true, nil
+	}
+}
+
+// staleDownloadAge is how long a partial download must sit untouched before
+// the cache cleanup takes it for a leftover. An operation can start while the
+// cleanup runs, so the age, not the queue, is what keeps a live download safe.
+const staleDownloadAge = time.Hour
+
+// runCacheCleanup removes stale cache files and leftover partial downloads.
+// It stays out of the way of running operations.
+func (s *Server) runCacheCleanup(context.Context) error {
+	if s.queue.IsBusy() {
+		return fmt.Errorf("%w: an operation is running", scheduler.ErrSkipped)
+	}
+	if s.cacheStore != nil {
+		s.cacheStore.CleanupStaleFiles()
+	}
+	if s.pipeline.downloads != nil {
+		return s.pipeline.downloads.CleanupStaleTmpFiles(staleDownloadAge)
+	}
+	return nil
+}
+
+// runHealthCheck refreshes the running state of installed apps and checks the
+// docker daemon is still reachable.
+func (s *Server) runHealthCheck(ctx context.Context) error {
+	s.refreshRuntimeStatus(ctx)
+	if s.docker == nil {
+		return nil
+	}
+	if err := s.docker.Ping(ctx); err != nil {
+		return fmt.Errorf("docker: %w", err)
+	}
+	return nil
+}
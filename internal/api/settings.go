@@ -1,17 +1,25 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"log"
 	"net/http"
-	"time"
+	"strings"
 
 	"fnos-store/internal/config"
+	"fnos-store/internal/docker"
+	"fnos-store/internal/platform"
+	"fnos-store/internal/scheduler"
 )
 
 type mirrorOptionResponse struct {
 	Key         string `json:"key"`
 	Label       string `json:"label"`
 	Description string `json:"description"`
+	// Retired mirrors were withdrawn by the catalog's mirror registry; they
+	// are listed so a config still naming one can be shown.
+	Retired bool `json:"retired,omitempty"`
 }
 
 type volumeOptionResponse struct {
@@ -29,8 +37,64 @@ type settingsResponse struct {
 	DockerMirrorOptions []mirrorOptionResponse `json:"docker_mirror_options"`
 	CustomGitHubMirror  string                 `json:"custom_github_mirror,omitempty"`
 	CustomDockerMirror  string                 `json:"custom_docker_mirror,omitempty"`
+	CustomGitHubMirrors []config.CustomMirror  `json:"custom_github_mirrors"`
+	CustomDockerMirrors []config.CustomMirror  `json:"custom_docker_mirrors"`
 	InstallVolume       int                    `json:"install_volume"`
 	VolumeOptions       []volumeOptionResponse `json:"volume_options"`
+	ImagePrune          string                 `json:"image_prune"`
+	RedactIPs           bool                   `json:"redact_ips"`
+	Schedules           map[string]string      `json:"schedules"`
+	ScheduleTimezone    string                 `json:"schedule_timezone"`
+	StaleCatalogHours   int                    `json:"stale_catalog_hours"`
+	Proxy               proxySettingsResponse  `json:"proxy"`
+	DockerProxy         dockerProxyResponse    `json:"docker_proxy"`
+	DownloadLimitKBps   int                    `json:"download_limit_kbps"`
+	OffPeakWindow       string                 `json:"off_peak_window"`
+}
+
+// proxySettingsResponse is the saved proxy without its password.
+type proxySettingsResponse struct {
+	URL         string   `json:"url"`
+	Username    string   `json:"username,omitempty"`
+	PasswordSet bool     `json:"password_set"`
+	NoProxy     []string `json:"no_proxy"`
+}
+
+func proxyResponse(p config.ProxyConfig) proxySettingsResponse {
+	resp := proxySettingsResponse{URL: p.URL, Username: p.Username, PasswordSet: p.Password != "", NoProxy: p.NoProxy}
+	if resp.NoProxy == nil {
+		resp.NoProxy = []string{}
+	}
+	return resp
+}
+
+// dockerProxyResponse is the proxy dockerd pulls images through. Image pulls
+// go through the daemon, which never sees the store's proxy setting: it takes
+// its own from the "proxies" key of /etc/docker/daemon.json.
+type dockerProxyResponse struct {
+	// Available is false when the daemon could not be asked.
+	Available  bool   `json:"available"`
+	HTTPProxy  string `json:"http_proxy"`
+	HTTPSProxy string `json:"https_proxy"`
+	NoProxy    string `json:"no_proxy"`
+	// Warning is set when the store uses a proxy that dockerd is not known
+	// to have.
+	Warning string `json:"warning,omitempty"`
+}
+
+func dockerProxyStatus(ctx context.Context, dc *docker.Client, proxy config.ProxyConfig) dockerProxyResponse {
+	var resp dockerProxyResponse
+	if dc != nil {
+		if info, err := dc.Info(ctx); err == nil {
+			resp = dockerProxyResponse{Available: true, HTTPProxy: info.HTTPProxy, HTTPSProxy: info.HTTPSProxy, NoProxy: info.NoProxy}
+		} else {
+			log.Printf("settings: docker info: %v", err)
+		}
+	}
+	if proxy.URL != "" && resp.HTTPProxy == "" && resp.HTTPSProxy == "" {
+		resp.Warning = "Docker 镜像由 dockerd 拉取，不经过此代理。如需代理，请在 /etc/docker/daemon.json 的 \"proxies\" 中配置后重启 Docker"
+	}
+	return resp
 }
 
 type settingsRequest struct {
@@ -40,13 +104,29 @@ type settingsRequest struct {
 	CustomGitHubMirror string `json:"custom_github_mirror"`
 	CustomDockerMirror string `json:"custom_docker_mirror"`
 	InstallVolume      int    `json:"install_volume"`
+	// CustomGitHubMirrors and CustomDockerMirrors replace the saved lists;
+	// they are left unchanged when absent.
+	CustomGitHubMirrors []config.CustomMirror `json:"custom_github_mirrors"`
+	CustomDockerMirrors []config.CustomMirror `json:"custom_docker_mirrors"`
+	ImagePrune          string                `json:"image_prune"`
+	RedactIPs           *bool                 `json:"redact_ips"`
+	StaleCatalogHours   int                   `json:"stale_catalog_hours"`
+	// Schedules and ScheduleTimezone are left unchanged when absent.
+	Schedules        map[string]string `json:"schedules"`
+	ScheduleTimezone *string           `json:"schedule_timezone"`
+	// Proxy is left unchanged when absent. An empty password keeps the saved
+	// one while the username stays the same.
+	Proxy *config.ProxyConfig `json:"proxy"`
+	// DownloadLimitKBps and OffPeakWindow are left unchanged when absent.
+	DownloadLimitKBps *int    `json:"download_limit_kbps"`
+	OffPeakWindow     *string `json:"off_peak_window"`
 }
 
 func githubMirrorOptionsResponse() []mirrorOptionResponse {
 	mirrors := config.GitHubMirrorOptions()
 	opts := make([]mirrorOptionResponse, len(mirrors))
 	for i, m := range mirrors {
-		opts[i] = mirrorOptionResponse{Key: m.Key, Label: m.Label, Description: m.Description}
+		opts[i] = mirrorOptionResponse{Key: m.Key, Label: m.Label, Description: m.Description, Retired: m.Retired}
 	}
 	return opts
 }
@@ -55,12 +135,12 @@ func dockerMirrorOptionsResponse() []mirrorOptionResponse {
 	mirrors := config.DockerMirrorOptions()
 	opts := make([]mirrorOptionResponse, len(mirrors))
 	for i, m := range mirrors {
-		opts[i] = mirrorOptionResponse{Key: m.Key, Label: m.Label, Description: m.Description}
+		opts[i] = mirrorOptionResponse{Key: m.Key, Label: m.Label, Description: m.Description, Retired: m.Retired}
 	}
 	return opts
 }
 
-func (s *Server) handleGetSettings(w http.ResponseWriter, _ *http.Request) {
+func (s *Server) handleGetSettings(w http.ResponseWriter, r *http.Request) {
 	if s.configMgr == nil {
 		writeAPIError(w, http.StatusInternalServerError, "config not available")
 		return
@@ -69,7 +149,7 @@ func (s *Server) handleGetSettings(w http.ResponseWriter, _ *http.Request) {
 	cfg := s.configMgr.Get()
 
 	var volOpts []volumeOptionResponse
-	if volumes, err := s.ac.ListVolumes(); err == nil {
+	if volumes, err := s.ac.ListVolumes(r.Context()); err == nil {
 		volOpts = make([]volumeOptionResponse, len(volumes))
 		for i, v := range volumes {
 			volOpts[i] = volumeOptionResponse{Index: v.Index, Path: v.Path, TotalBytes: v.TotalBytes, FreeBytes: v.FreeBytes}
@@ -84,11 +164,52 @@ func (s *Server) handleGetSettings(w http.ResponseWriter, _ *http.Request) {
 		DockerMirrorOptions: dockerMirrorOptionsResponse(),
 		CustomGitHubMirror:  cfg.CustomGitHubMirror,
 		CustomDockerMirror:  cfg.CustomDockerMirror,
+		CustomGitHubMirrors: nonNilMirrors(cfg.CustomGitHubMirrors),
+		CustomDockerMirrors: nonNilMirrors(cfg.CustomDockerMirrors),
 		InstallVolume:       cfg.InstallVolume,
 		VolumeOptions:       volOpts,
+		ImagePrune:          cfg.ImagePrune,
+		RedactIPs:           cfg.RedactIPs,
+		Schedules:           effectiveSchedules(cfg),
+		ScheduleTimezone:    cfg.ScheduleTimezone,
+		StaleCatalogHours:   cfg.StaleCatalogHours,
+		Proxy:               proxyResponse(cfg.Proxy),
+		DockerProxy:         dockerProxyStatus(r.Context(), s.docker, cfg.Proxy),
+		DownloadLimitKBps:   cfg.DownloadLimitKBps,
+		OffPeakWindow:       cfg.OffPeakWindow,
 	})
 }
 
+// applyProxy passes the proxy settings on to appcenter-cli. The store's own
+// HTTP clients read them from the config on every request.
+func applyProxy(cfg config.Config) {
+	platform.SetCLIEnv(cfg.Proxy.Env())
+}
+
+// applyDownloadLimit sets the global download limit, which running downloads
+// follow at once.
+func (s *Server) applyDownloadLimit(cfg config.Config) {
+	if s.pipeline != nil && s.pipeline.downloads != nil {
+		s.pipeline.downloads.SetRateLimit(int64(cfg.DownloadLimitKBps) * 1024)
+	}
+}
+
+func nonNilMirrors(mirrors []config.CustomMirror) []config.CustomMirror {
+	if mirrors == nil {
+		return []config.CustomMirror{}
+	}
+	return mirrors
+}
+
+// effectiveSchedules lists every job's schedule under cfg, defaults included.
+func effectiveSchedules(cfg config.Config) map[string]string {
+	out := map[string]string{jobCatalogRefresh: jobSpec(jobCatalogRefresh, cfg)}
+	for name := range defaultJobSpecs {
+		out[name] = jobSpec(name, cfg)
+	}
+	return out
+}
+
 func (s *Server) handlePutSettings(w http.ResponseWriter, r *http.Request) {
 	if s.configMgr == nil {
 		writeAPIError(w, http.StatusInternalServerError, "config not available")
@@ -114,13 +235,85 @@ func (s *Server) handlePutSettings(w http.ResponseWriter, r *http.Request) {
 
 	existing := s.configMgr.Get()
 	cfg := config.Config{
-		CheckIntervalHours: req.CheckIntervalHours,
-		Mirror:             req.Mirror,
-		DockerMirror:       req.DockerMirror,
-		CustomGitHubMirror: req.CustomGitHubMirror,
-		CustomDockerMirror: req.CustomDockerMirror,
-		InstallVolume:      req.InstallVolume,
-		IgnoredApps:        existing.IgnoredApps,
+		CheckIntervalHours:  req.CheckIntervalHours,
+		Mirror:              req.Mirror,
+		DockerMirror:        req.DockerMirror,
+		CustomGitHubMirror:  req.CustomGitHubMirror,
+		CustomDockerMirror:  req.CustomDockerMirror,
+		CustomGitHubMirrors: existing.CustomGitHubMirrors,
+		CustomDockerMirrors: existing.CustomDockerMirrors,
+		InstallVolume:       req.InstallVolume,
+		IgnoredApps:         existing.IgnoredApps,
+		ImagePrune:          req.ImagePrune,
+		RedactIPs:           existing.RedactIPs,
+		Schedules:           existing.Schedules,
+		ScheduleTimezone:    existing.ScheduleTimezone,
+		StaleCatalogHours:   existing.StaleCatalogHours,
+		Proxy:               existing.Proxy,
+		DownloadLimitKBps:   existing.DownloadLimitKBps,
+		OffPeakWindow:       existing.OffPeakWindow,
+	}
+	if cfg.ImagePrune == "" {
+		cfg.ImagePrune = existing.ImagePrune
+	} else if !config.ValidImagePrune(cfg.ImagePrune) {
+		writeAPIError(w, http.StatusBadRequest, "image_prune must be auto, dry-run or off")
+		return
+	}
+	if req.RedactIPs != nil {
+		cfg.RedactIPs = *req.RedactIPs
+	}
+	if req.StaleCatalogHours > 0 {
+		cfg.StaleCatalogHours = req.StaleCatalogHours
+	}
+	if req.Schedules != nil {
+		cfg.Schedules = req.Schedules
+	}
+	if req.ScheduleTimezone != nil {
+		cfg.ScheduleTimezone = *req.ScheduleTimezone
+	}
+	if req.CustomGitHubMirrors != nil {
+		cfg.CustomGitHubMirrors = req.CustomGitHubMirrors
+	}
+	if req.CustomDockerMirrors != nil {
+		cfg.CustomDockerMirrors = req.CustomDockerMirrors
+	}
+	if req.Proxy != nil {
+		cfg.Proxy = *req.Proxy
+		if cfg.Proxy.Password == "" && cfg.Proxy.Username == existing.Proxy.Username {
+			cfg.Proxy.Password = existing.Proxy.Password
+		}
+	}
+	if req.DownloadLimitKBps != nil {
+		if *req.DownloadLimitKBps < 0 {
+			writeAPIError(w, http.StatusBadRequest, "download_limit_kbps must not be negative")
+			return
+		}
+		cfg.DownloadLimitKBps = *req.DownloadLimitKBps
+	}
+	if req.OffPeakWindow != nil {
+		cfg.OffPeakWindow = strings.TrimSpace(*req.OffPeakWindow)
+		if cfg.OffPeakWindow != "" {
+			if _, err := scheduler.ParseWindow(cfg.OffPeakWindow); err != nil {
+				writeAPIError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+	}
+	if err := config.ValidateProxy(cfg.Proxy); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := config.ValidateCustomGitHubMirrors(cfg.CustomGitHubMirrors); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := config.ValidateCustomDockerMirrors(cfg.CustomDockerMirrors); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validateSchedules(cfg.Schedules, cfg.ScheduleTimezone); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
 	if err := s.configMgr.SaveConfig(cfg); err != nil {
@@ -128,12 +321,18 @@ func (s *Server) handlePutSettings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if s.scheduler != nil {
-		s.scheduler.SetInterval(time.Duration(req.CheckIntervalHours) * time.Hour)
+	s.applySchedules(cfg)
+	applyProxy(cfg)
+	s.applyDownloadLimit(cfg)
+	// Switching to auto should not wait hours for the first ranking.
+	if cfg.Mirror == config.MirrorAuto && existing.Mirror != config.MirrorAuto && s.scheduler != nil {
+		if err := s.scheduler.RunNow(context.Background(), jobMirrorBenchmark); err != nil {
+			log.Printf("mirror benchmark: %v", err)
+		}
 	}
 
 	var volOpts []volumeOptionResponse
-	if volumes, err := s.ac.ListVolumes(); err == nil {
+	if volumes, err := s.ac.ListVolumes(r.Context()); err == nil {
 		volOpts = make([]volumeOptionResponse, len(volumes))
 		for i, v := range volumes {
 			volOpts[i] = volumeOptionResponse{Index: v.Index, Path: v.Path, TotalBytes: v.TotalBytes, FreeBytes: v.FreeBytes}
@@ -148,7 +347,18 @@ func (s *Server) handlePutSettings(w http.ResponseWriter, r *http.Request) {
 		DockerMirrorOptions: dockerMirrorOptionsResponse(),
 		CustomGitHubMirror:  req.CustomGitHubMirror,
 		CustomDockerMirror:  req.CustomDockerMirror,
+		CustomGitHubMirrors: nonNilMirrors(cfg.CustomGitHubMirrors),
+		CustomDockerMirrors: nonNilMirrors(cfg.CustomDockerMirrors),
 		InstallVolume:       req.InstallVolume,
 		VolumeOptions:       volOpts,
+		ImagePrune:          s.configMgr.Get().ImagePrune,
+		RedactIPs:           cfg.RedactIPs,
+		Schedules:           effectiveSchedules(cfg),
+		ScheduleTimezone:    cfg.ScheduleTimezone,
+		StaleCatalogHours:   s.configMgr.Get().StaleCatalogHours,
+		Proxy:               proxyResponse(cfg.Proxy),
+		DockerProxy:         dockerProxyStatus(r.Context(), s.docker, cfg.Proxy),
+		DownloadLimitKBps:   cfg.DownloadLimitKBps,
+		OffPeakWindow:       cfg.OffPeakWindow,
 	})
 }
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"fnos-store/internal/config"
+	"fnos-store/internal/platform"
+)
+
+func TestDockerProxyStatusWarnsWithoutDaemonProxy(t *testing.T) {
+	got := dockerProxyStatus(context.Background(), nil, config.ProxyConfig{URL: "http://192.168.1.2:7890"})
+	if got.Available || got.Warning == "" {
+		t.Errorf("store proxy without a known dockerd proxy: %+v, want a warning", got)
+	}
+	if got := dockerProxyStatus(context.Background(), nil, config.ProxyConfig{}); got.Warning != "" {
+		t.Errorf("no store proxy: warning %q", got.Warning)
+	}
+}
+
+func TestPutSettingsReportsDockerProxy(t *testing.T) {
+	defer platform.SetCLIEnv(nil)
+	s := &Server{configMgr: config.NewManager(t.TempDir()), ac: &stubAppCenter{}}
+	req := httptest.NewRequest(http.MethodPut, "/api/settings", strings.NewReader(`{"proxy":{"url":"http://192.168.1.2:7890"}}`))
+	rec := httptest.NewRecorder()
+	s.handlePutSettings(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp settingsResponse
+	decodeResponse(t, rec, &resp)
+	if resp.DockerProxy.Warning == "" {
+		t.Errorf("docker_proxy = %+v after saving a proxy, want the dockerd warning", resp.DockerProxy)
+	}
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -19,10 +20,15 @@ type progressPayload struct {
 }
 
 type sseStream struct {
-	w       http.ResponseWriter
-	r       *http.Request
+	w       http.ResponseWriter // nil for a background operation
+	ctx     context.Context
 	flusher http.Flusher
 	appname string
+
+	// result and resultMessage hold the last terminal step sent ("done" or
+	// "error"), so the operation's outcome can be recorded afterwards.
+	result        string
+	resultMessage string
 }
 
 func newSSEStream(w http.ResponseWriter, r *http.Request, appname string) (*sseStream, error) {
@@ -36,29 +42,73 @@ func newSSEStream(w http.ResponseWriter, r *http.Request, appname string) (*sseS
 		return nil, errors.New("streaming not supported")
 	}
 
-	return &sseStream{w: w, r: r, flusher: flusher, appname: appname}, nil
+	return &sseStream{w: w, ctx: r.Context(), flusher: flusher, appname: appname}, nil
+}
+
+// newBackgroundStream returns a stream for an operation nobody is watching,
+// such as a scheduled auto-update. Events are dropped; the outcome is still
+// recorded.
+func newBackgroundStream(ctx context.Context, appname string) *sseStream {
+	return &sseStream{ctx: ctx, appname: appname}
 }
 
 func (s *sseStream) sendProgress(payload progressPayload) error {
-	if err := s.r.Context().Err(); err != nil {
+	// Record the outcome even when the client has gone: the operation still
+	// ran to this point.
+	if payload.Step == opResultDone || payload.Step == opResultError {
+		s.result, s.resultMessage = payload.Step, payload.Message
+	}
+	if err := s.ctx.Err(); err != nil {
 		return err
 	}
 
 	payload.AppName = s.appname
+	return s.sendEvent("progress", payload)
+}
 
+// sendEvent writes one named event with a JSON payload.
+func (s *sseStream) sendEvent(event string, payload any) error {
+	if s.w == nil {
+		return s.ctx.Err()
+	}
 	raw, err := json.Marshal(payload)
 	if err != nil {
 		return err
 	}
 
-	if _, err := fmt.Fprintf(s.w, "event: progress\ndata: %s\n\n", raw); err != nil {
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, raw); err != nil {
 		return err
 	}
 
 	s.flusher.Flush()
-	return s.r.Context().Err()
+	return s.ctx.Err()
+}
+
+// recordResult stores the stream's outcome as the result of appname's active
+// operation.
+func (s *sseStream) recordResult(q *OperationQueue) {
+	q.SetResult(s.appname, s.result, s.resultMessage)
 }
 
 func (s *sseStream) sendError(message string) error {
 	return s.sendProgress(progressPayload{Step: "error", Message: message})
 }
+
+// sendCancelled ends the stream of an operation cancelled through the API.
+// Clients see an error step, which every client already treats as the end;
+// the history records the operation as cancelled.
+func (s *sseStream) sendCancelled() error {
+	err := s.sendError(cancelledMessage)
+	s.result = opResultCancelled
+	return err
+}
+
+// failStage ends the stream after a stage failed, reporting a failure caused
+// by cancelling the operation as the cancellation it is.
+func (s *sseStream) failStage(ctx context.Context, err error) {
+	if errors.Is(context.Cause(ctx), errOperationCancelled) {
+		_ = s.sendCancelled()
+		return
+	}
+	_ = s.sendError(err.Error())
+}
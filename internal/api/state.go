@@ -3,9 +3,11 @@ package api
 import (
 	"context"
 	"errors"
+	"log"
 	"sync"
 	"time"
 
+	"fnos-store/internal/config"
 	"fnos-store/internal/core"
 	"fnos-store/internal/platform"
 	"fnos-store/internal/source"
@@ -27,7 +29,10 @@ func (s *Server) refreshRegistry(ctx context.Context) error {
 		return err
 	}
 
-	remoteApps, fetchErr := s.source.FetchApps(ctx)
+	s.refreshMirrorRegistry(ctx)
+
+	remoteApps, prov, fetchErr := s.source.FetchApps(ctx)
+	blocks, haveBlocks := s.refreshSafetyList(ctx)
 
 	var installedTags map[string]string
 	if s.cacheStore != nil {
@@ -37,27 +42,86 @@ func (s *Server) refreshRegistry(ctx context.Context) error {
 	now := time.Now()
 	s.mu.Lock()
 	// Preserve existing registry when all remote/cache/local fallbacks fail.
+	if haveBlocks {
+		s.registry.SetUpgradeBlocks(blocks)
+	}
 	if remoteApps != nil || fetchErr == nil {
 		s.registry.Merge(localApps, remoteApps, installedTags)
 	}
 	s.lastCheck = now
 	s.mu.Unlock()
 
+	if remoteApps != nil || fetchErr == nil {
+		s.setCatalogProvenance(prov)
+	}
 	if s.cacheStore != nil {
 		s.cacheStore.SetLastCheckAt(now)
 	}
 
 	_ = s.refreshRecommended(ctx)
 
-	s.refreshRuntimeStatus()
+	s.refreshRuntimeStatus(ctx)
 	return fetchErr
 }
 
+// refreshSafetyList fetches the catalog's signed upgrade safety list and
+// applies its fnOS deny-list. It returns the per-app blocks for the registry,
+// with ok false when no list could be read, in which case whatever was
+// applied before stays in place.
+func (s *Server) refreshSafetyList(ctx context.Context) (blocks []source.AppUpgradeBlock, ok bool) {
+	if s.safetySource == nil {
+		return nil, false
+	}
+	list, err := s.safetySource.FetchSafetyList(ctx)
+	if err != nil {
+		// A build without a signing key is logged at startup and shown in
+		// the status; it need not be repeated on every refresh.
+		if !errors.Is(err, source.ErrNoSafetyKey) {
+			log.Printf("upgrade safety list unavailable: %v", err)
+		}
+		return nil, false
+	}
+	platform.SetRemoteUnsafeVersions(list.UnsafeFnOSVersions)
+	return list.AppBlocks, true
+}
+
+// refreshMirrorRegistry fetches the catalog's signed mirror registry, merges
+// it over the built-in mirrors, and moves the config off mirrors it retired.
+// Without a list the mirrors applied before stay in place.
+func (s *Server) refreshMirrorRegistry(ctx context.Context) {
+	if s.mirrorsSource == nil {
+		return
+	}
+	list, err := s.mirrorsSource.FetchMirrorList(ctx)
+	if err != nil {
+		// Like the safety list, a missing signing key is reported once at
+		// startup and in the status.
+		if !errors.Is(err, source.ErrNoMirrorsKey) {
+			log.Printf("mirror registry unavailable: %v", err)
+		}
+		return
+	}
+	config.SetRemoteMirrors(list.GitHubMirrors, list.DockerMirrors)
+
+	if s.configMgr == nil {
+		return
+	}
+	cfg := s.configMgr.Get()
+	migrated, changed := config.MigrateMirrors(cfg)
+	if !changed {
+		return
+	}
+	log.Printf("mirror registry: switching mirror %s -> %s, docker mirror %s -> %s", cfg.Mirror, migrated.Mirror, cfg.DockerMirror, migrated.DockerMirror)
+	if err := s.configMgr.SaveConfig(migrated); err != nil {
+		log.Printf("mirror registry: save config: %v", err)
+	}
+}
+
 func (s *Server) RefreshRegistry(ctx context.Context) error {
 	return s.refreshRegistry(ctx)
 }
 
-func (s *Server) refreshRuntimeStatus() {
+func (s *Server) refreshRuntimeStatus(ctx context.Context) {
 	if s.ac == nil || s.queue == nil {
 		return
 	}
@@ -65,7 +129,7 @@ func (s *Server) refreshRuntimeStatus() {
 	var apps []platform.InstalledApp
 	err := s.queue.WithCLI(func() error {
 		var listErr error
-		apps, listErr = s.ac.List()
+		apps, listErr = s.ac.List(ctx)
 		return listErr
 	})
 	if err != nil {
@@ -108,6 +172,15 @@ func (s *Server) listRegistryApps() []core.AppInfo {
 	return s.registry.List()
 }
 
+func (s *Server) searchRegistryApps(query string) []core.AppInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.registry == nil {
+		return nil
+	}
+	return s.registry.Search(query)
+}
+
 func (s *Server) listRecommendedApps() []source.RecommendedApp {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
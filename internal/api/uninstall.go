@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
@@ -25,15 +26,21 @@ func (s *Server) handleUninstall(w http.ResponseWriter, r *http.Request) {
 		writeAPIError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	defer stream.recordResult(s.queue)
+
+	// Closing the page must not kill the CLI halfway through an uninstall, nor
+	// the image cleanup and refresh after it; the commands still stop at their
+	// own time limits.
+	ctx := context.WithoutCancel(r.Context())
 
 	// Stop is best-effort: an app that is already stopped (or whose service
 	// entry is gone) must not block the uninstall the user asked for. The
 	// error is surfaced only if the uninstall itself then fails.
 	_ = stream.sendProgress(progressPayload{Step: "stopping", Message: "正在停止..."})
-	stopErr := s.queue.WithCLI(func() error { return s.ac.Stop(appname) })
+	stopErr := s.queue.WithCLI(func() error { return s.ac.Stop(ctx, appname) })
 
 	_ = stream.sendProgress(progressPayload{Step: "uninstalling", Message: "正在卸载..."})
-	if err := s.queue.WithCLI(func() error { return s.ac.Uninstall(appname) }); err != nil {
+	if err := s.queue.WithCLI(func() error { return s.ac.Uninstall(ctx, appname) }); err != nil {
 		if stopErr != nil {
 			_ = stream.sendError(fmt.Sprintf("%v（停止阶段也失败: %v）", err, stopErr))
 			return
@@ -60,8 +67,9 @@ func (s *Server) handleUninstall(w http.ResponseWriter, r *http.Request) {
 	if s.cacheStore != nil {
 		s.cacheStore.RemoveInstalledTag(appname)
 	}
+	s.pipeline.afterImageChange(ctx, stream, appname, nil)
 
-	if err := s.refreshRegistry(r.Context()); err != nil {
+	if err := s.refreshRegistry(ctx); err != nil {
 		_ = stream.sendError(err.Error())
 		return
 	}
@@ -18,6 +18,14 @@ func (s *Server) handleUpdate(w http.ResponseWriter, r *http.Request) {
 		writeAPIError(w, http.StatusBadRequest, "app is not installed")
 		return
 	}
+	if app.IncompatibleReason != "" {
+		writeAPIError(w, http.StatusBadRequest, app.IncompatibleReason)
+		return
+	}
+	if app.UpgradeBlockedReason != "" {
+		writeAPIError(w, http.StatusBadRequest, app.UpgradeBlockedReason)
+		return
+	}
 
 	if s.storeApp != "" && appname == s.storeApp {
 		s.runSelfUpdate(w, r, app)
@@ -3,10 +3,13 @@ package cache
 import (
 	"encoding/json"
 	"log"
+	"maps"
 	"os"
 	"path/filepath"
 	"sync"
 	"time"
+
+	"fnos-store/internal/config"
 )
 
 type Store struct {
@@ -18,6 +21,68 @@ type Store struct {
 type metadata struct {
 	LastCheckAt   time.Time         `json:"last_check_at"`
 	InstalledTags map[string]string `json:"installed_tags,omitempty"`
+	// AppImages records the docker image references each docker-type app was
+	// installed with, so an update or uninstall knows which images it may
+	// prune without touching images that belong to other apps.
+	AppImages map[string][]AppImage `json:"app_images,omitempty"`
+	// HTTPValidators holds the ETag/Last-Modified each catalog URL last
+	// answered with, for conditional requests.
+	HTTPValidators map[string]HTTPValidator `json:"http_validators,omitempty"`
+	// Catalogs records where each cached catalog file came from, keyed by
+	// file name.
+	Catalogs map[string]CatalogFetch `json:"catalogs,omitempty"`
+	// ReleaseNotes holds the GitHub release notes fetched per app.
+	ReleaseNotes map[string]ReleaseNotes `json:"release_notes,omitempty"`
+	// MirrorScores keeps the GitHub mirror benchmark scores across restarts.
+	MirrorScores map[string]config.MirrorScore `json:"mirror_scores,omitempty"`
+}
+
+// ReleaseNotes are the release notes fetched for one app. They stay valid
+// while ReleaseTag is still the app's latest release: only a new release adds
+// notes.
+type ReleaseNotes struct {
+	ReleaseTag string        `json:"release_tag"`
+	FetchedAt  time.Time     `json:"fetched_at"`
+	Notes      []ReleaseNote `json:"notes"`
+	// Complete is set when Notes hold every release of the app.
+	Complete bool `json:"complete,omitempty"`
+}
+
+// ReleaseNote is one GitHub release of an app.
+type ReleaseNote struct {
+	Version string `json:"version"`
+	Date    string `json:"date,omitempty"`
+	Body    string `json:"body"`
+}
+
+// HTTPValidator is what a server said about a response body we cached.
+// SHA256 ties it to that body: once the cached file holds a copy from
+// another mirror, the validator no longer describes it and must not be sent.
+type HTTPValidator struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	SHA256       string `json:"sha256"`
+}
+
+// CatalogFetch is the provenance of a cached catalog file.
+type CatalogFetch struct {
+	// Mirror is the label of the mirror that served the cached copy and URL
+	// the address it was fetched from.
+	Mirror string `json:"mirror"`
+	URL    string `json:"url"`
+	// FetchedAt is when the copy was downloaded; CheckedAt when a mirror
+	// last confirmed it current, by serving it again or answering 304.
+	FetchedAt time.Time `json:"fetched_at"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// AppImage is one docker image an app was installed with. ID is the image ID
+// the reference resolved to at pull time: when a later pull moves the tag,
+// the old ID is left dangling and is only attributable to this app through
+// this record.
+type AppImage struct {
+	Ref string `json:"ref"`
+	ID  string `json:"id,omitempty"`
 }
 
 func NewStore(dataDir string) *Store {
@@ -107,6 +172,124 @@ func (s *Store) InstalledTags() map[string]string {
 	return out
 }
 
+func (s *Store) AppImages(appname string) []AppImage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]AppImage(nil), s.meta.AppImages[appname]...)
+}
+
+func (s *Store) SetAppImages(appname string, images []AppImage) {
+	s.mu.Lock()
+	if s.meta.AppImages == nil {
+		s.meta.AppImages = make(map[string][]AppImage)
+	}
+	s.meta.AppImages[appname] = append([]AppImage(nil), images...)
+	s.mu.Unlock()
+
+	s.persistMeta()
+}
+
+func (s *Store) RemoveAppImages(appname string) {
+	s.mu.Lock()
+	delete(s.meta.AppImages, appname)
+	s.mu.Unlock()
+
+	s.persistMeta()
+}
+
+// AllAppImages returns every tracked image keyed by app, used to make sure a
+// prune never removes an image another installed app still references.
+func (s *Store) AllAppImages() map[string][]AppImage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string][]AppImage, len(s.meta.AppImages))
+	for k, v := range s.meta.AppImages {
+		out[k] = append([]AppImage(nil), v...)
+	}
+	return out
+}
+
+// HTTPValidator returns the validator stored for url.
+func (s *Store) HTTPValidator(url string) (HTTPValidator, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.meta.HTTPValidators[url]
+	return v, ok
+}
+
+// SetHTTPValidator stores the validator for url. A validator with neither an
+// ETag nor a Last-Modified date removes url's entry.
+func (s *Store) SetHTTPValidator(url string, v HTTPValidator) {
+	s.mu.Lock()
+	if v.ETag == "" && v.LastModified == "" {
+		delete(s.meta.HTTPValidators, url)
+	} else {
+		if s.meta.HTTPValidators == nil {
+			s.meta.HTTPValidators = make(map[string]HTTPValidator)
+		}
+		s.meta.HTTPValidators[url] = v
+	}
+	s.mu.Unlock()
+
+	s.persistMeta()
+}
+
+// CatalogFetch returns the provenance recorded for a cached catalog file.
+func (s *Store) CatalogFetch(name string) (CatalogFetch, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	f, ok := s.meta.Catalogs[name]
+	return f, ok
+}
+
+// SetCatalogFetch records the provenance of a cached catalog file.
+func (s *Store) SetCatalogFetch(name string, f CatalogFetch) {
+	s.mu.Lock()
+	if s.meta.Catalogs == nil {
+		s.meta.Catalogs = make(map[string]CatalogFetch)
+	}
+	s.meta.Catalogs[name] = f
+	s.mu.Unlock()
+
+	s.persistMeta()
+}
+
+// ReleaseNotes returns the release notes cached for appname.
+func (s *Store) ReleaseNotes(appname string) (ReleaseNotes, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n, ok := s.meta.ReleaseNotes[appname]
+	return n, ok
+}
+
+// SetReleaseNotes caches the release notes for appname.
+func (s *Store) SetReleaseNotes(appname string, n ReleaseNotes) {
+	s.mu.Lock()
+	if s.meta.ReleaseNotes == nil {
+		s.meta.ReleaseNotes = make(map[string]ReleaseNotes)
+	}
+	s.meta.ReleaseNotes[appname] = n
+	s.mu.Unlock()
+
+	s.persistMeta()
+}
+
+// MirrorScores returns the stored mirror benchmark scores.
+func (s *Store) MirrorScores() map[string]config.MirrorScore {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return maps.Clone(s.meta.MirrorScores)
+}
+
+// SetMirrorScores stores the mirror benchmark scores.
+func (s *Store) SetMirrorScores(scores map[string]config.MirrorScore) {
+	s.mu.Lock()
+	s.meta.MirrorScores = maps.Clone(scores)
+	s.mu.Unlock()
+
+	s.persistMeta()
+}
+
 // CleanupStaleFiles removes temporary/orphaned cache files on startup.
 func (s *Store) CleanupStaleFiles() {
 	entries, err := os.ReadDir(s.cacheDir)
@@ -121,7 +304,9 @@ func (s *Store) CleanupStaleFiles() {
 		}
 
 		name := entry.Name()
-		if name == "meta.json" || name == "apps.json" {
+		// Catalog files are kept however old: a 304 confirms them current
+		// without rewriting them.
+		if name == "meta.json" || name == "apps.json" || name == "recommended.json" || name == "upgrade-safety.json" || name == "mirrors.json" {
 			continue
 		}
 
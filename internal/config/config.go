@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
+	"time"
 )
 
 const (
@@ -12,21 +14,35 @@ const (
 	DefaultDataDir            = "/var/apps/fnos-apps-store/var"
 	DefaultMirror             = "gh-proxy"
 	DefaultDockerMirror       = "daocloud"
+	DefaultImagePrune         = ImagePruneAuto
+	DefaultStaleCatalogHours  = 48
+)
+
+// Image prune modes decide what happens to an app's superseded docker images
+// after a successful update or uninstall.
+const (
+	ImagePruneAuto   = "auto"    // remove them
+	ImagePruneDryRun = "dry-run" // only report what would be removed
+	ImagePruneOff    = "off"     // leave them alone
 )
 
 type GitHubMirror struct {
-	Key         string
-	Label       string
-	URL         string
-	Description string
+	Key         string `json:"key"`
+	Label       string `json:"label"`
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+	// Retired mirrors are still listed but no longer used (see
+	// SetRemoteMirrors).
+	Retired bool `json:"retired,omitempty"`
 }
 
 type DockerMirror struct {
-	Key           string
-	Label         string
-	URL           string
-	Description   string
-	MultiRegistry bool // supports proxying multiple registries (docker.io, ghcr.io, lscr.io, etc.)
+	Key           string `json:"key"`
+	Label         string `json:"label"`
+	URL           string `json:"url"`
+	Description   string `json:"description,omitempty"`
+	MultiRegistry bool   `json:"multi_registry,omitempty"` // supports proxying multiple registries (docker.io, ghcr.io, lscr.io, etc.)
+	Retired       bool   `json:"retired,omitempty"`
 }
 
 var gitHubMirrors = []GitHubMirror{
@@ -37,6 +53,7 @@ var gitHubMirrors = []GitHubMirror{
 	{Key: "cdn-ghproxy", Label: "CDN GHProxy", URL: "https://cdn.gh-proxy.org/", Description: "CDN 节点 GitHub 加速"},
 	{Key: "cors-isteed", Label: "Cors Proxy", URL: "https://cors.isteed.cc/", Description: "Cloudflare Workers GitHub 代理"},
 	{Key: "gh-ddlc", Label: "GH DDLC", URL: "https://gh.ddlc.top/", Description: "GitHub 文件下载加速"},
+	{Key: MirrorAuto, Label: "自动选择", URL: "", Description: "定期测速，自动使用当前最快的加速地址"},
 	{Key: "custom", Label: "自定义", URL: "", Description: "使用自定义加速地址"},
 	{Key: "direct", Label: "直连 GitHub", URL: "", Description: "直接从 GitHub 下载，适合有代理的用户"},
 }
@@ -53,55 +70,72 @@ var dockerMirrors = []DockerMirror{
 	{Key: "direct", Label: "直连 Docker Hub", URL: "", Description: "直接拉取，适合有代理的用户"},
 }
 
-func GitHubMirrorOptions() []GitHubMirror { return gitHubMirrors }
-func DockerMirrorOptions() []DockerMirror { return dockerMirrors }
+func GitHubMirrorOptions() []GitHubMirror { return gitHubMirrorList() }
+func DockerMirrorOptions() []DockerMirror { return dockerMirrorList() }
 
 func GitHubMirrorPrefix(key string, cfg Config) string {
-	if key == "custom" && cfg.CustomGitHubMirror != "" {
-		return cfg.CustomGitHubMirror
+	if key == MirrorAuto {
+		return rankedPrefixes(cfg)[0]
+	}
+	if key == "custom" {
+		if custom := cfg.CustomGitHubMirrorList(); len(custom) > 0 {
+			return custom[0].URL
+		}
 	}
-	for _, m := range gitHubMirrors {
+	mirrors := activeGitHubMirrors()
+	for _, m := range mirrors {
 		if m.Key == key {
 			return m.URL
 		}
 	}
-	return gitHubMirrors[0].URL
+	return mirrors[0].URL
 }
 
 func DockerMirrorPrefix(key string, cfg Config) string {
-	if key == "custom" && cfg.CustomDockerMirror != "" {
-		return cfg.CustomDockerMirror
-	}
-	for _, m := range dockerMirrors {
-		if m.Key == key {
-			return m.URL
-		}
-	}
-	return dockerMirrors[0].URL
+	return DockerFallbackMirrors(key, cfg)[0].URL
+}
+
+func IsDockerMirrorMultiRegistry(key string, cfg Config) bool {
+	return DockerFallbackMirrors(key, cfg)[0].MultiRegistry
 }
 
-func IsDockerMirrorMultiRegistry(key string) bool {
-	if key == "custom" || key == "direct" || key == "" {
-		return false
+// DockerFallbackMirrors lists the Docker mirrors to pull through, in order:
+// the enabled custom mirrors for the custom mode, otherwise just the selected
+// mirror. It is never empty.
+func DockerFallbackMirrors(key string, cfg Config) []DockerMirror {
+	if key == "custom" {
+		if custom := cfg.CustomDockerMirrorList(); len(custom) > 0 {
+			return custom
+		}
 	}
-	for _, m := range dockerMirrors {
+	mirrors := activeDockerMirrors()
+	for _, m := range mirrors {
 		if m.Key == key {
-			return m.MultiRegistry
+			return []DockerMirror{m}
 		}
 	}
-	return false
+	return []DockerMirror{mirrors[0]}
 }
 
 func GitHubFallbackPrefixes(selectedKey string, cfg Config) []string {
+	if selectedKey == MirrorAuto {
+		return rankedPrefixes(cfg)
+	}
 	prefixes := make([]string, 0, len(gitHubMirrors))
-	selected := GitHubMirrorPrefix(selectedKey, cfg)
-	prefixes = append(prefixes, selected)
-	for _, m := range gitHubMirrors {
-		if m.Key != selectedKey && m.Key != "direct" && m.Key != "custom" && m.URL != "" && m.URL != selected {
+	if selectedKey == "custom" {
+		for _, m := range cfg.CustomGitHubMirrorList() {
 			prefixes = append(prefixes, m.URL)
 		}
 	}
-	if selected != "" {
+	if len(prefixes) == 0 {
+		prefixes = append(prefixes, GitHubMirrorPrefix(selectedKey, cfg))
+	}
+	for _, m := range activeGitHubMirrors() {
+		if m.Key != selectedKey && m.Key != "direct" && m.Key != "custom" && m.URL != "" && !slices.Contains(prefixes, m.URL) {
+			prefixes = append(prefixes, m.URL)
+		}
+	}
+	if !slices.Contains(prefixes, "") {
 		prefixes = append(prefixes, "")
 	}
 	return prefixes
@@ -109,13 +143,58 @@ func GitHubFallbackPrefixes(selectedKey string, cfg Config) []string {
 
 // Config holds the persistent store configuration.
 type Config struct {
-	CheckIntervalHours int      `json:"check_interval_hours"`
-	Mirror             string   `json:"mirror"`
-	DockerMirror       string   `json:"docker_mirror"`
-	CustomGitHubMirror string   `json:"custom_github_mirror,omitempty"`
-	CustomDockerMirror string   `json:"custom_docker_mirror,omitempty"`
-	InstallVolume      int      `json:"install_volume"`
-	IgnoredApps        []string `json:"ignored_apps,omitempty"`
+	CheckIntervalHours int    `json:"check_interval_hours"`
+	Mirror             string `json:"mirror"`
+	DockerMirror       string `json:"docker_mirror"`
+	CustomGitHubMirror string `json:"custom_github_mirror,omitempty"`
+	CustomDockerMirror string `json:"custom_docker_mirror,omitempty"`
+	// CustomGitHubMirrors and CustomDockerMirrors are the custom mode's
+	// mirrors in priority order. They replace the single custom mirror
+	// fields above, which are still honoured while the lists are empty.
+	CustomGitHubMirrors []CustomMirror `json:"custom_github_mirrors,omitempty"`
+	CustomDockerMirrors []CustomMirror `json:"custom_docker_mirrors,omitempty"`
+	InstallVolume       int            `json:"install_volume"`
+	IgnoredApps         []string       `json:"ignored_apps,omitempty"`
+	ImagePrune          string         `json:"image_prune,omitempty"`
+	// RedactIPs also masks IP addresses in logs, diagnostics and support
+	// bundles. Secrets are always masked.
+	RedactIPs bool `json:"redact_ips,omitempty"`
+	// Schedules overrides the schedule of a maintenance job by name (see
+	// GET /api/scheduler). An empty value disables the job; a job that is
+	// not listed keeps its default.
+	Schedules map[string]string `json:"schedules,omitempty"`
+	// StaleCatalogHours is how old the served catalog may get, when no
+	// mirror can be reached, before the store warns that it is stale.
+	StaleCatalogHours int `json:"stale_catalog_hours,omitempty"`
+	// ScheduleTimezone is the IANA zone schedules are evaluated in; empty
+	// means the system's local time.
+	ScheduleTimezone string `json:"schedule_timezone,omitempty"`
+	// Proxy is used for the store's outbound traffic: catalog and package
+	// downloads and mirror checks. It is also passed to appcenter-cli, whose
+	// hooks run docker compose; pulls the Docker daemon makes itself follow
+	// the daemon's own proxy settings.
+	Proxy ProxyConfig `json:"proxy,omitzero"`
+	// DownloadLimitKBps caps all package downloads together; 0 is
+	// unlimited. A single operation can be capped further with
+	// ?limit_kbps=.
+	DownloadLimitKBps int `json:"download_limit_kbps,omitempty"`
+	// OffPeakWindow, "HH:MM-HH:MM" in the schedule timezone, holds scheduled
+	// update downloads until the window opens. Empty means no window; manual
+	// operations never wait.
+	OffPeakWindow string `json:"off_peak_window,omitempty"`
+}
+
+// ScheduleLocation returns the timezone for schedules, falling back to local
+// time when ScheduleTimezone is empty or unknown.
+func (c Config) ScheduleLocation() *time.Location {
+	if c.ScheduleTimezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(c.ScheduleTimezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
 }
 
 // IsAppIgnored returns true if the given app is in the ignored list.
@@ -152,7 +231,32 @@ func defaultConfig() Config {
 		CheckIntervalHours: DefaultCheckIntervalHours,
 		Mirror:             DefaultMirror,
 		DockerMirror:       DefaultDockerMirror,
+		ImagePrune:         DefaultImagePrune,
+		StaleCatalogHours:  DefaultStaleCatalogHours,
+	}
+}
+
+// normalizeImagePrune maps an empty mode to the default, so a config written
+// before the setting existed keeps pruning on. An unknown mode, such as a
+// hand-edited typo, turns pruning off: guessing must never delete images.
+func normalizeImagePrune(mode string) string {
+	switch {
+	case mode == "":
+		return DefaultImagePrune
+	case ValidImagePrune(mode):
+		return mode
+	default:
+		return ImagePruneOff
+	}
+}
+
+// ValidImagePrune reports whether mode is one of the image prune modes.
+func ValidImagePrune(mode string) bool {
+	switch mode {
+	case ImagePruneAuto, ImagePruneDryRun, ImagePruneOff:
+		return true
 	}
+	return false
 }
 
 // LoadConfig reads the config file from disk.
@@ -184,6 +288,10 @@ func (m *Manager) LoadConfig() (Config, error) {
 	if cfg.DockerMirror == "" {
 		cfg.DockerMirror = DefaultDockerMirror
 	}
+	cfg.ImagePrune = normalizeImagePrune(cfg.ImagePrune)
+	if cfg.StaleCatalogHours < 1 {
+		cfg.StaleCatalogHours = DefaultStaleCatalogHours
+	}
 
 	m.cfg = cfg
 	return m.cfg, nil
@@ -200,6 +308,10 @@ func (m *Manager) SaveConfig(cfg Config) error {
 	if cfg.DockerMirror == "" {
 		cfg.DockerMirror = DefaultDockerMirror
 	}
+	cfg.ImagePrune = normalizeImagePrune(cfg.ImagePrune)
+	if cfg.StaleCatalogHours < 1 {
+		cfg.StaleCatalogHours = DefaultStaleCatalogHours
+	}
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -0,0 +1,214 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Custom mirror styles say how a mirror URL is combined with the upstream
+// reference.
+const (
+	// MirrorStylePath appends the whole upstream reference to the mirror:
+	// "https://proxy.lan/" + "https://github.com/..." for GitHub, or
+	// "harbor.lan/cache/" + "docker.io/library/redis" for Docker.
+	MirrorStylePath = "path"
+	// MirrorStyleHost puts the mirror in place of the upstream host:
+	// "https://proxy.lan/" + "owner/repo/..." for GitHub, or
+	// "mirror.lan/" + "library/redis" for a Docker Hub-only registry mirror.
+	MirrorStyleHost = "host"
+)
+
+// MaxCustomMirrors caps each custom mirror list.
+const MaxCustomMirrors = 10
+
+// CustomMirror is one user-defined mirror. The lists are tried in order, and
+// disabled entries are kept but skipped.
+//
+// A GitHub URL may use the placeholders {url} (the whole upstream URL),
+// {host} and {path} (the upstream URL without scheme and host); without any,
+// the style decides where the upstream reference goes.
+type CustomMirror struct {
+	Label   string `json:"label"`
+	URL     string `json:"url"`
+	Style   string `json:"style,omitempty"`
+	Enabled bool   `json:"enabled"`
+}
+
+func (m CustomMirror) style() string {
+	if m.Style == "" {
+		return MirrorStylePath
+	}
+	return m.Style
+}
+
+// customMirrorKey names the i-th enabled custom mirror. The first keeps the
+// plain "custom" key the single custom mirror had.
+func customMirrorKey(i int) string {
+	if i == 0 {
+		return "custom"
+	}
+	return fmt.Sprintf("custom-%d", i+1)
+}
+
+// CustomGitHubMirrorList returns the enabled custom GitHub mirrors in
+// priority order. A config from before the lists existed yields its single
+// custom mirror.
+func (c Config) CustomGitHubMirrorList() []GitHubMirror {
+	entries := c.CustomGitHubMirrors
+	if len(entries) == 0 && c.CustomGitHubMirror != "" {
+		entries = []CustomMirror{{URL: c.CustomGitHubMirror, Enabled: true}}
+	}
+	var out []GitHubMirror
+	for _, e := range entries {
+		if !e.Enabled {
+			continue
+		}
+		out = append(out, GitHubMirror{
+			Key:   customMirrorKey(len(out)),
+			Label: customLabel(e, len(out)),
+			URL:   gitHubTemplate(e),
+		})
+	}
+	return out
+}
+
+// CustomDockerMirrorList returns the enabled custom Docker mirrors in
+// priority order. The single custom mirror of an older config rewrote the
+// host, so it keeps doing that.
+func (c Config) CustomDockerMirrorList() []DockerMirror {
+	entries := c.CustomDockerMirrors
+	if len(entries) == 0 && c.CustomDockerMirror != "" {
+		entries = []CustomMirror{{URL: c.CustomDockerMirror, Style: MirrorStyleHost, Enabled: true}}
+	}
+	var out []DockerMirror
+	for _, e := range entries {
+		if !e.Enabled {
+			continue
+		}
+		out = append(out, DockerMirror{
+			Key:           customMirrorKey(len(out)),
+			Label:         customLabel(e, len(out)),
+			URL:           e.URL,
+			MultiRegistry: e.style() == MirrorStylePath,
+		})
+	}
+	return out
+}
+
+func customLabel(m CustomMirror, i int) string {
+	if m.Label != "" {
+		return m.Label
+	}
+	if i == 0 {
+		return "自定义"
+	}
+	return fmt.Sprintf("自定义 %d", i+1)
+}
+
+// gitHubTemplate turns a custom GitHub mirror into the prefix form the rest
+// of the store passes to MirrorURL.
+func gitHubTemplate(m CustomMirror) string {
+	if strings.Contains(m.URL, "{") || m.style() == MirrorStylePath {
+		return m.URL
+	}
+	return m.URL + "{path}"
+}
+
+// MirrorURL routes upstream through a GitHub mirror prefix. Built-in
+// prefixes are prepended; a custom mirror's placeholders are filled in. An
+// empty prefix is direct access.
+func MirrorURL(prefix, upstream string) string {
+	if !strings.Contains(prefix, "{") {
+		return prefix + upstream
+	}
+	host, path := "", strings.TrimPrefix(upstream, "/")
+	if u, err := url.Parse(upstream); err == nil && u.Host != "" {
+		host = u.Host
+		path = strings.TrimPrefix(u.RequestURI(), "/")
+	}
+	return strings.NewReplacer("{url}", upstream, "{host}", host, "{path}", path).Replace(prefix)
+}
+
+// ValidateCustomGitHubMirrors checks a custom GitHub mirror list before it
+// is saved.
+func ValidateCustomGitHubMirrors(mirrors []CustomMirror) error {
+	if len(mirrors) > MaxCustomMirrors {
+		return fmt.Errorf("自定义 GitHub 加速地址最多 %d 个", MaxCustomMirrors)
+	}
+	for i, m := range mirrors {
+		if err := validateStyle(m); err != nil {
+			return fmt.Errorf("自定义 GitHub 加速地址 #%d: %w", i+1, err)
+		}
+		if err := validateGitHubTemplate(m); err != nil {
+			return fmt.Errorf("自定义 GitHub 加速地址 #%d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func validateGitHubTemplate(m CustomMirror) error {
+	rest := strings.NewReplacer("{url}", "", "{host}", "", "{path}", "").Replace(m.URL)
+	if strings.ContainsAny(rest, "{}") {
+		return fmt.Errorf("未知占位符，仅支持 {url}、{host}、{path}")
+	}
+	// {host} may sit in the host name, so check the URL with it filled in.
+	u, err := url.Parse(strings.NewReplacer("{url}", "", "{host}", "github.com", "{path}", "").Replace(m.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("地址需以 http:// 或 https:// 开头: %q", m.URL)
+	}
+	if !strings.Contains(m.URL, "{") && !strings.HasSuffix(m.URL, "/") {
+		return fmt.Errorf("地址需以 / 结尾: %q", m.URL)
+	}
+	if m.style() == MirrorStyleHost && strings.Contains(m.URL, "{url}") {
+		return fmt.Errorf("替换主机模式不能使用 {url}")
+	}
+	return nil
+}
+
+// ValidateCustomDockerMirrors checks a custom Docker mirror list before it
+// is saved. Docker mirrors are registry prefixes such as "harbor.lan/cache/",
+// without a scheme.
+func ValidateCustomDockerMirrors(mirrors []CustomMirror) error {
+	if len(mirrors) > MaxCustomMirrors {
+		return fmt.Errorf("自定义 Docker 加速地址最多 %d 个", MaxCustomMirrors)
+	}
+	for i, m := range mirrors {
+		if err := validateStyle(m); err != nil {
+			return fmt.Errorf("自定义 Docker 加速地址 #%d: %w", i+1, err)
+		}
+		if err := validateDockerPrefix(m.URL); err != nil {
+			return fmt.Errorf("自定义 Docker 加速地址 #%d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func validateDockerPrefix(prefix string) error {
+	if strings.Contains(prefix, "://") {
+		return fmt.Errorf("地址不需要 http:// 前缀: %q", prefix)
+	}
+	if !strings.HasSuffix(prefix, "/") || strings.HasPrefix(prefix, "/") {
+		return fmt.Errorf("地址需形如 registry.example.com/ 并以 / 结尾: %q", prefix)
+	}
+	if strings.ContainsAny(prefix, " {}@") || strings.Contains(prefix, "//") {
+		return fmt.Errorf("地址包含非法字符: %q", prefix)
+	}
+	host, _, _ := strings.Cut(prefix, "/")
+	if _, err := url.Parse("https://" + host); err != nil {
+		return fmt.Errorf("无效的主机名: %q", host)
+	}
+	return nil
+}
+
+func validateStyle(m CustomMirror) error {
+	if strings.TrimSpace(m.URL) == "" {
+		return fmt.Errorf("地址不能为空")
+	}
+	switch m.Style {
+	case "", MirrorStylePath, MirrorStyleHost:
+		return nil
+	default:
+		return fmt.Errorf("未知模式 %q，应为 %s 或 %s", m.Style, MirrorStylePath, MirrorStyleHost)
+	}
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMirrorURL(t *testing.T) {
+	const upstream = "https://github.com/conversun/fnos-apps/releases/download/x.fpk"
+	tests := []struct {
+		prefix, want string
+	}{
+		{"", upstream},
+		{"https://gh-proxy.com/", "https://gh-proxy.com/" + upstream},
+		{"https://proxy.lan/{path}", "https://proxy.lan/conversun/fnos-apps/releases/download/x.fpk"},
+		{"https://{host}.proxy.lan/{path}", "https://github.com.proxy.lan/conversun/fnos-apps/releases/download/x.fpk"},
+		{"https://proxy.lan/fetch?u={url}", "https://proxy.lan/fetch?u=" + upstream},
+	}
+	for _, tt := range tests {
+		if got := MirrorURL(tt.prefix, upstream); got != tt.want {
+			t.Errorf("MirrorURL(%q) = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestCustomMirrorPriority(t *testing.T) {
+	cfg := Config{
+		Mirror:       "custom",
+		DockerMirror: "custom",
+		CustomGitHubMirrors: []CustomMirror{
+			{Label: "off", URL: "https://off.lan/", Enabled: false},
+			{Label: "primary", URL: "https://gh1.lan/", Enabled: true},
+			{Label: "secondary", URL: "https://gh2.lan/", Style: MirrorStyleHost, Enabled: true},
+		},
+		CustomDockerMirrors: []CustomMirror{
+			{Label: "harbor", URL: "harbor.lan/cache/", Enabled: true},
+			{Label: "hub", URL: "hub.lan/", Style: MirrorStyleHost, Enabled: true},
+		},
+	}
+
+	prefixes := GitHubFallbackPrefixes("custom", cfg)
+	if prefixes[0] != "https://gh1.lan/" || prefixes[1] != "https://gh2.lan/{path}" || prefixes[len(prefixes)-1] != "" {
+		t.Errorf("prefixes = %v", prefixes)
+	}
+	if slices.Contains(prefixes, "https://off.lan/") {
+		t.Error("disabled mirror used")
+	}
+	if got := GitHubMirrorPrefix("custom", cfg); got != "https://gh1.lan/" {
+		t.Errorf("GitHubMirrorPrefix = %q", got)
+	}
+	// Custom mirrors are only used in the custom mode.
+	if p := GitHubFallbackPrefixes("gh-proxy", cfg); slices.Contains(p, "https://gh1.lan/") {
+		t.Errorf("gh-proxy prefixes = %v", p)
+	}
+
+	docker := DockerFallbackMirrors("custom", cfg)
+	if len(docker) != 2 || DockerMirrorPrefix("custom", cfg) != "harbor.lan/cache/" {
+		t.Fatalf("docker mirrors = %+v", docker)
+	}
+	if !docker[0].MultiRegistry || docker[1].MultiRegistry {
+		t.Errorf("path style should keep the registry, host style rewrite it: %+v", docker)
+	}
+
+	keys := []string{}
+	for _, m := range BenchmarkMirrors(cfg) {
+		keys = append(keys, m.Key)
+	}
+	if !slices.Contains(keys, "custom") || !slices.Contains(keys, "custom-2") {
+		t.Errorf("benchmark keys = %v", keys)
+	}
+}
+
+func TestLegacyCustomMirror(t *testing.T) {
+	cfg := Config{CustomGitHubMirror: "https://old.lan/", CustomDockerMirror: "old.lan/"}
+	if got := GitHubMirrorPrefix("custom", cfg); got != "https://old.lan/" {
+		t.Errorf("GitHubMirrorPrefix = %q", got)
+	}
+	if DockerMirrorPrefix("custom", cfg) != "old.lan/" || IsDockerMirrorMultiRegistry("custom", cfg) {
+		t.Error("legacy docker mirror should rewrite the host")
+	}
+	if got := GitHubMirrorPrefix("custom", Config{}); got != "" {
+		t.Errorf("custom without a mirror = %q, want direct", got)
+	}
+}
+
+func TestValidateCustomMirrors(t *testing.T) {
+	gitHub := []struct {
+		m  CustomMirror
+		ok bool
+	}{
+		{CustomMirror{URL: "https://proxy.lan/"}, true},
+		{CustomMirror{URL: "https://{host}.proxy.lan/", Style: MirrorStyleHost}, true},
+		{CustomMirror{URL: "https://proxy.lan/?u={url}"}, true},
+		{CustomMirror{URL: "https://proxy.lan"}, false},
+		{CustomMirror{URL: "proxy.lan/"}, false},
+		{CustomMirror{URL: "https://proxy.lan/{repo}"}, false},
+		{CustomMirror{URL: "https://proxy.lan/{url}", Style: MirrorStyleHost}, false},
+		{CustomMirror{URL: "https://proxy.lan/", Style: "rewrite"}, false},
+		{CustomMirror{URL: " "}, false},
+	}
+	for _, tt := range gitHub {
+		if err := ValidateCustomGitHubMirrors([]CustomMirror{tt.m}); (err == nil) != tt.ok {
+			t.Errorf("GitHub %+v: err = %v, want ok=%v", tt.m, err, tt.ok)
+		}
+	}
+
+	docker := []struct {
+		url string
+		ok  bool
+	}{
+		{"harbor.lan/cache/", true},
+		{"mirror.lan:5000/", true},
+		{"https://harbor.lan/", false},
+		{"harbor.lan", false},
+		{"/cache/", false},
+		{"harbor.lan//", false},
+	}
+	for _, tt := range docker {
+		if err := ValidateCustomDockerMirrors([]CustomMirror{{URL: tt.url}}); (err == nil) != tt.ok {
+			t.Errorf("Docker %q: err = %v, want ok=%v", tt.url, err, tt.ok)
+		}
+	}
+
+	if err := ValidateCustomGitHubMirrors(make([]CustomMirror, MaxCustomMirrors+1)); err == nil {
+		t.Error("oversized list accepted")
+	}
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MirrorAuto is the GitHub mirror mode that orders mirrors by their measured
+// score instead of using a fixed one.
+const MirrorAuto = "auto"
+
+// mirrorScoreAlpha weighs a new benchmark against the rolling score: high
+// enough that a mirror going down drops within a couple of runs, low enough
+// that one slow probe does not reorder everything.
+const mirrorScoreAlpha = 0.3
+
+// probeSizeKB is the download size a score is estimated for, roughly a small
+// fpk package.
+const probeSizeKB = 1024
+
+// MirrorScore is the rolling benchmark record of one GitHub mirror.
+type MirrorScore struct {
+	// Success is the moving average of probe outcomes, 1 for a mirror that
+	// always answered.
+	Success float64 `json:"success"`
+	// LatencyMs and ThroughputKBps average the successful probes only.
+	LatencyMs      float64   `json:"latency_ms"`
+	ThroughputKBps float64   `json:"throughput_kbps,omitempty"`
+	Samples        int       `json:"samples"`
+	CheckedAt      time.Time `json:"checked_at"`
+}
+
+// Update folds one probe into the score. A throughput of 0 means it was not
+// measured and leaves the average alone.
+func (s MirrorScore) Update(ok bool, latency time.Duration, throughputKBps float64, now time.Time) MirrorScore {
+	ewma := func(old, sample float64) float64 {
+		if s.Samples == 0 || old == 0 {
+			return sample
+		}
+		return old + mirrorScoreAlpha*(sample-old)
+	}
+	success := 0.0
+	if ok {
+		success = 1
+		s.LatencyMs = ewma(s.LatencyMs, float64(latency.Milliseconds()))
+		if throughputKBps > 0 {
+			s.ThroughputKBps = ewma(s.ThroughputKBps, throughputKBps)
+		}
+	}
+	if s.Samples == 0 {
+		s.Success = success
+	} else {
+		s.Success += mirrorScoreAlpha * (success - s.Success)
+	}
+	s.Samples++
+	s.CheckedAt = now
+	return s
+}
+
+// Value ranks the mirror: the chance it answers divided by the estimated
+// seconds to fetch probeSizeKB from it. Higher is better.
+func (s MirrorScore) Value() float64 {
+	if s.Samples == 0 || s.Success == 0 {
+		return 0
+	}
+	seconds := s.LatencyMs / 1000
+	if s.ThroughputKBps > 0 {
+		seconds += probeSizeKB / s.ThroughputKBps
+	} else {
+		seconds += probeSizeKB / 256.0 // unmeasured: assume a slow 256 KB/s
+	}
+	return s.Success / seconds
+}
+
+var mirrorScores struct {
+	sync.RWMutex
+	m map[string]MirrorScore // by GitHubMirror.ScoreKey
+}
+
+// ScoreKey is what m's score is kept under. Custom mirrors are keyed by
+// their place among the enabled ones, which moves when an entry is disabled
+// or reordered, so their scores follow the URL instead.
+func (m GitHubMirror) ScoreKey() string {
+	if m.Key == "custom" || strings.HasPrefix(m.Key, "custom-") {
+		return "custom:" + m.URL
+	}
+	return m.Key
+}
+
+// SetMirrorScores replaces the scores the auto mirror mode ranks by.
+func SetMirrorScores(scores map[string]MirrorScore) {
+	mirrorScores.Lock()
+	defer mirrorScores.Unlock()
+	mirrorScores.m = maps.Clone(scores)
+}
+
+// MirrorScores returns the current scores, by ScoreKey.
+func MirrorScores() map[string]MirrorScore {
+	mirrorScores.RLock()
+	defer mirrorScores.RUnlock()
+	return maps.Clone(mirrorScores.m)
+}
+
+// BenchmarkMirrors lists the GitHub mirrors the auto mode chooses from, with
+// their prefixes: every built-in mirror, direct access, and the enabled
+// custom mirrors.
+func BenchmarkMirrors(cfg Config) []GitHubMirror {
+	var out []GitHubMirror
+	for _, m := range activeGitHubMirrors() {
+		switch m.Key {
+		case MirrorAuto:
+			continue
+		case "custom":
+			out = append(out, cfg.CustomGitHubMirrorList()...)
+			continue
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// RankedMirrors orders the benchmark mirrors by score, best first. Mirrors
+// not measured yet follow in their static order; ones that keep failing go
+// last.
+func RankedMirrors(cfg Config) []GitHubMirror {
+	mirrors := BenchmarkMirrors(cfg)
+	scores := MirrorScores()
+	slices.SortStableFunc(mirrors, func(a, b GitHubMirror) int {
+		return cmp.Compare(scoreRank(scores, b.ScoreKey()), scoreRank(scores, a.ScoreKey()))
+	})
+	return mirrors
+}
+
+func rankedPrefixes(cfg Config) []string {
+	mirrors := RankedMirrors(cfg)
+	prefixes := make([]string, 0, len(mirrors))
+	for _, m := range mirrors {
+		prefixes = append(prefixes, m.URL)
+	}
+	return prefixes
+}
+
+// scoreRank puts unmeasured mirrors (0) between working ones (positive) and
+// failing ones (negative).
+func scoreRank(scores map[string]MirrorScore, key string) float64 {
+	s, ok := scores[key]
+	if !ok || s.Samples == 0 {
+		return 0
+	}
+	if s.Success < 0.5 {
+		return s.Success - 1
+	}
+	return s.Value()
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMirrorScoreUpdate(t *testing.T) {
+	now := time.Now()
+	var s MirrorScore
+	s = s.Update(true, 200*time.Millisecond, 1000, now)
+	if s.Success != 1 || s.LatencyMs != 200 || s.ThroughputKBps != 1000 || s.Samples != 1 {
+		t.Fatalf("first sample = %+v", s)
+	}
+	s = s.Update(false, 0, 0, now)
+	if s.Success != 0.7 || s.LatencyMs != 200 {
+		t.Errorf("after a failure = %+v, want success 0.7 and latency kept", s)
+	}
+	s = s.Update(true, 400*time.Millisecond, 0, now)
+	if s.LatencyMs != 260 || s.ThroughputKBps != 1000 {
+		t.Errorf("after a latency-only sample = %+v, want latency 260 and throughput kept", s)
+	}
+}
+
+func TestAutoMirrorRanking(t *testing.T) {
+	t.Cleanup(func() { SetMirrorScores(nil) })
+	now := time.Now()
+	fast := MirrorScore{}.Update(true, 50*time.Millisecond, 4000, now)
+	slow := MirrorScore{}.Update(true, 900*time.Millisecond, 100, now)
+	down := MirrorScore{}.Update(false, 0, 0, now)
+	SetMirrorScores(map[string]MirrorScore{"ghfast": slow, "gh-ddlc": fast, "conversun": down})
+
+	prefixes := GitHubFallbackPrefixes(MirrorAuto, Config{Mirror: MirrorAuto})
+	if len(prefixes) != len(BenchmarkMirrors(Config{})) {
+		t.Fatalf("prefixes = %v", prefixes)
+	}
+	if prefixes[0] != "https://gh.ddlc.top/" || prefixes[1] != "https://ghfast.top/" {
+		t.Errorf("best first: got %v", prefixes[:2])
+	}
+	// Unmeasured mirrors keep their static order, ahead of the failing one.
+	if prefixes[2] != "https://gh-proxy.com/" || prefixes[len(prefixes)-1] != "https://hub.conversun.com/" {
+		t.Errorf("prefixes = %v", prefixes)
+	}
+	if got := GitHubMirrorPrefix(MirrorAuto, Config{}); got != "https://gh.ddlc.top/" {
+		t.Errorf("GitHubMirrorPrefix(auto) = %q", got)
+	}
+
+	withCustom := BenchmarkMirrors(Config{CustomGitHubMirror: "https://proxy.lan/"})
+	found := false
+	for _, m := range withCustom {
+		found = found || m.URL == "https://proxy.lan/"
+	}
+	if !found {
+		t.Error("custom mirror not benchmarked")
+	}
+}
+
+func TestCustomMirrorScoresFollowTheURL(t *testing.T) {
+	t.Cleanup(func() { SetMirrorScores(nil) })
+	now := time.Now()
+	cfg := Config{Mirror: MirrorAuto, CustomGitHubMirrors: []CustomMirror{
+		{URL: "https://slow.lan/", Enabled: true},
+		{URL: "https://fast.lan/", Enabled: true},
+	}}
+	scores := make(map[string]MirrorScore)
+	for _, m := range cfg.CustomGitHubMirrorList() {
+		if m.URL == "https://fast.lan/" {
+			scores[m.ScoreKey()] = MirrorScore{}.Update(true, 10*time.Millisecond, 50000, now)
+		} else {
+			scores[m.ScoreKey()] = MirrorScore{}.Update(false, 0, 0, now)
+		}
+	}
+	SetMirrorScores(scores)
+
+	// Disabling the first entry renumbers the second as "custom"; its score
+	// must stay with it, not pass to whatever now holds its old key.
+	cfg.CustomGitHubMirrors[0].Enabled = false
+	cfg.CustomGitHubMirrors = append(cfg.CustomGitHubMirrors, CustomMirror{URL: "https://new.lan/", Enabled: true})
+	ranked := RankedMirrors(cfg)
+	if ranked[0].URL != "https://fast.lan/" {
+		t.Errorf("best mirror = %s, want the fast custom mirror", ranked[0].URL)
+	}
+	for _, m := range ranked {
+		if m.URL == "https://new.lan/" && scoreRank(MirrorScores(), m.ScoreKey()) != 0 {
+			t.Error("a new custom mirror inherited another mirror's score")
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ProxyConfig routes the store's outbound traffic through a LAN proxy. It
+// covers the store's own requests and appcenter-cli, not Docker image pulls:
+// dockerd makes those itself, with the proxy from its daemon.json "proxies".
+type ProxyConfig struct {
+	// URL is the proxy, with an http, https or socks5 scheme:
+	// "http://192.168.1.2:7890" or "socks5://proxy.lan:1080". Empty means no
+	// proxy beyond what the environment sets.
+	URL      string `json:"url"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// NoProxy lists destinations reached directly: host names, which also
+	// match their subdomains with or without a leading "." or "*.", IP
+	// addresses, CIDR ranges, or "*". Loopback never uses the proxy.
+	NoProxy []string `json:"no_proxy,omitempty"`
+}
+
+// Enabled reports whether a proxy is configured.
+func (p ProxyConfig) Enabled() bool { return p.URL != "" }
+
+// proxyURL returns the proxy URL with its credentials, as HTTP transports
+// and the HTTP(S)_PROXY variables take it.
+func (p ProxyConfig) proxyURL() (*url.URL, error) {
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return nil, err
+	}
+	if p.Username != "" {
+		u.User = url.UserPassword(p.Username, p.Password)
+	}
+	return u, nil
+}
+
+// bypass reports whether host (without port) is reached directly.
+func (p ProxyConfig) bypass(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	ip := net.ParseIP(host)
+	if host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return true
+	}
+	for _, entry := range p.NoProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+		case entry == "*":
+			return true
+		case strings.Contains(entry, "/"):
+			if _, cidr, err := net.ParseCIDR(entry); err == nil && ip != nil && cidr.Contains(ip) {
+				return true
+			}
+		default:
+			domain := strings.TrimPrefix(strings.TrimPrefix(entry, "*"), ".")
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Env returns the proxy as HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables,
+// in both spellings, for child processes. It is empty when no proxy is set.
+func (p ProxyConfig) Env() []string {
+	u, err := p.proxyURL()
+	if !p.Enabled() || err != nil {
+		return nil
+	}
+	noProxy := strings.Join(append([]string{"localhost", "127.0.0.1", "::1"}, p.NoProxy...), ",")
+	var env []string
+	for _, kv := range [][2]string{{"HTTP_PROXY", u.String()}, {"HTTPS_PROXY", u.String()}, {"NO_PROXY", noProxy}} {
+		env = append(env, kv[0]+"="+kv[1], strings.ToLower(kv[0])+"="+kv[1])
+	}
+	return env
+}
+
+// ValidateProxy checks proxy settings before they are saved.
+func ValidateProxy(p ProxyConfig) error {
+	if !p.Enabled() {
+		return nil
+	}
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return fmt.Errorf("代理地址无效: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return fmt.Errorf("代理地址需以 http://、https:// 或 socks5:// 开头: %q", p.URL)
+	}
+	if u.Hostname() == "" || u.Port() == "" {
+		return fmt.Errorf("代理地址需包含主机和端口: %q", p.URL)
+	}
+	if u.Path != "" && u.Path != "/" {
+		return fmt.Errorf("代理地址不能包含路径: %q", p.URL)
+	}
+	if u.User != nil && p.Username != "" {
+		return fmt.Errorf("用户名已在代理地址中设置")
+	}
+	if p.Password != "" && p.Username == "" {
+		return fmt.Errorf("设置了代理密码但没有用户名")
+	}
+	for _, entry := range p.NoProxy {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.ContainsAny(entry, " ,") {
+			return fmt.Errorf("不走代理列表中的条目无效: %q", entry)
+		}
+		if strings.Contains(entry, "/") {
+			if _, _, err := net.ParseCIDR(entry); err != nil {
+				return fmt.Errorf("不走代理列表中的网段无效: %q", entry)
+			}
+		}
+	}
+	return nil
+}
+
+// Proxy is an http.Transport Proxy function following the saved proxy
+// settings, so a change applies to the next request without rebuilding any
+// client. Without a proxy configured it falls back to the environment. A nil
+// manager always uses the environment.
+func (m *Manager) Proxy(req *http.Request) (*url.URL, error) {
+	if m == nil {
+		return http.ProxyFromEnvironment(req)
+	}
+	p := m.Get().Proxy
+	if !p.Enabled() {
+		return http.ProxyFromEnvironment(req)
+	}
+	if p.bypass(req.URL.Hostname()) {
+		return nil, nil
+	}
+	return p.proxyURL()
+}
+
+// HTTPTransport returns a transport like http.DefaultTransport that routes
+// through the configured proxy.
+func (m *Manager) HTTPTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = m.Proxy
+	return t
+}
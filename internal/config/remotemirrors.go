@@ -0,0 +1,158 @@
+package config
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+// remoteMirrors holds the catalog's mirror registry (mirrors.json), merged
+// over the built-in lists so a dead public proxy can be retired, or a new one
+// added, without a store release.
+var remoteMirrors struct {
+	sync.RWMutex
+	github []GitHubMirror
+	docker []DockerMirror
+}
+
+// SetRemoteMirrors replaces the catalog's mirror registry. An entry with a
+// built-in key updates that mirror in place; a new key is added after the
+// built-in proxies. The modes auto, custom and direct cannot be changed.
+func SetRemoteMirrors(github []GitHubMirror, docker []DockerMirror) {
+	remoteMirrors.Lock()
+	defer remoteMirrors.Unlock()
+	remoteMirrors.github = slices.Clone(github)
+	remoteMirrors.docker = slices.Clone(docker)
+}
+
+func isModeKey(key string) bool {
+	return key == MirrorAuto || key == "custom" || key == "direct" || strings.HasPrefix(key, "custom-")
+}
+
+func gitHubMirrorList() []GitHubMirror {
+	remoteMirrors.RLock()
+	defer remoteMirrors.RUnlock()
+	return mergeMirrors(gitHubMirrors, remoteMirrors.github,
+		func(m GitHubMirror) (string, bool) { return m.Key, m.Retired },
+		func(old, m GitHubMirror) GitHubMirror {
+			if m.Retired {
+				old.Retired = true
+				return old
+			}
+			return m
+		})
+}
+
+func dockerMirrorList() []DockerMirror {
+	remoteMirrors.RLock()
+	defer remoteMirrors.RUnlock()
+	return mergeMirrors(dockerMirrors, remoteMirrors.docker,
+		func(m DockerMirror) (string, bool) { return m.Key, m.Retired },
+		func(old, m DockerMirror) DockerMirror {
+			if m.Retired {
+				old.Retired = true
+				return old
+			}
+			return m
+		})
+}
+
+func activeGitHubMirrors() []GitHubMirror {
+	return slices.DeleteFunc(gitHubMirrorList(), func(m GitHubMirror) bool { return m.Retired })
+}
+
+func activeDockerMirrors() []DockerMirror {
+	return slices.DeleteFunc(dockerMirrorList(), func(m DockerMirror) bool { return m.Retired })
+}
+
+// mergeMirrors lays remote over builtin. info returns a mirror's key and
+// whether it is retired; overlay applies a remote entry to the mirror with
+// its key, so retiring one keeps what the store knew about it.
+func mergeMirrors[M any](builtin, remote []M, info func(M) (string, bool), overlay func(old, m M) M) []M {
+	key := func(m M) string { k, _ := info(m); return k }
+	out := slices.Clone(builtin)
+	// New mirrors go before the first mode entry, which the built-in lists
+	// keep at the end.
+	insertAt := slices.IndexFunc(out, func(m M) bool { return isModeKey(key(m)) })
+	if insertAt < 0 {
+		insertAt = len(out)
+	}
+	for _, r := range remote {
+		k, retired := info(r)
+		if k == "" || isModeKey(k) {
+			continue
+		}
+		if i := slices.IndexFunc(out, func(m M) bool { return key(m) == k }); i >= 0 {
+			out[i] = overlay(out[i], r)
+			continue
+		}
+		if retired {
+			continue // nothing to retire
+		}
+		out = slices.Insert(out, insertAt, r)
+		insertAt++
+	}
+	return out
+}
+
+// MigrateMirrors moves cfg off mirrors the registry retired or no longer
+// lists: the GitHub mirror to the best ranked proxy, the Docker mirror to the
+// default or else the first active one. It reports whether cfg changed.
+func MigrateMirrors(cfg Config) (Config, bool) {
+	changed := false
+	if !isModeKey(cfg.Mirror) && !slices.ContainsFunc(activeGitHubMirrors(), func(m GitHubMirror) bool { return m.Key == cfg.Mirror }) {
+		cfg.Mirror = bestGitHubMirror(cfg)
+		changed = true
+	}
+	if !isModeKey(cfg.DockerMirror) && !slices.ContainsFunc(activeDockerMirrors(), func(m DockerMirror) bool { return m.Key == cfg.DockerMirror }) {
+		cfg.DockerMirror = bestDockerMirror()
+		changed = true
+	}
+	return cfg, changed
+}
+
+// bestGitHubMirror picks the proxy with the best benchmark score, else the
+// default, else the first one still active.
+func bestGitHubMirror(cfg Config) string {
+	scores := MirrorScores()
+	for _, m := range RankedMirrors(cfg) {
+		if !isModeKey(m.Key) && scoreRank(scores, m.ScoreKey()) > 0 {
+			return m.Key
+		}
+	}
+	var first string
+	for _, m := range activeGitHubMirrors() {
+		if isModeKey(m.Key) {
+			continue
+		}
+		if m.Key == DefaultMirror {
+			return m.Key
+		}
+		if first == "" {
+			first = m.Key
+		}
+	}
+	if first == "" {
+		return "direct"
+	}
+	return first
+}
+
+func bestDockerMirror() string {
+	var first string
+	for _, m := range activeDockerMirrors() {
+		if isModeKey(m.Key) {
+			continue
+		}
+		if m.Key == DefaultDockerMirror {
+			return m.Key
+		}
+		if first == "" {
+			first = m.Key
+		}
+	}
+	if first == "" {
+		return "direct"
+	}
+	return first
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRemoteMirrorsMerge(t *testing.T) {
+	t.Cleanup(func() { SetRemoteMirrors(nil, nil) })
+	SetRemoteMirrors([]GitHubMirror{
+		{Key: "ghfast", Retired: true},
+		{Key: "gh-ddlc", Label: "GH DDLC", URL: "https://gh2.ddlc.top/"},
+		{Key: "new-proxy", Label: "New", URL: "https://new.example/"},
+		{Key: "direct", Label: "hijacked", URL: "https://evil.example/"},
+		{Key: "gone", Retired: true},
+	}, []DockerMirror{
+		{Key: "ratdev", Retired: true},
+	})
+
+	opts := GitHubMirrorOptions()
+	keys := make([]string, len(opts))
+	for i, m := range opts {
+		keys[i] = m.Key
+	}
+	if i := slices.Index(keys, "new-proxy"); i < 0 || keys[i+1] != MirrorAuto {
+		t.Errorf("new mirror should follow the built-in proxies: %v", keys)
+	}
+	if slices.Contains(keys, "gone") {
+		t.Errorf("unknown retired mirror listed: %v", keys)
+	}
+	for _, m := range opts {
+		switch m.Key {
+		case "ghfast":
+			if !m.Retired || m.URL != "https://ghfast.top/" {
+				t.Errorf("retired mirror = %+v, want built-in entry marked retired", m)
+			}
+		case "direct":
+			if m.URL != "" {
+				t.Errorf("direct mode overridden: %+v", m)
+			}
+		}
+	}
+
+	prefixes := GitHubFallbackPrefixes("gh-proxy", Config{})
+	if slices.Contains(prefixes, "https://ghfast.top/") || !slices.Contains(prefixes, "https://gh2.ddlc.top/") || !slices.Contains(prefixes, "https://new.example/") {
+		t.Errorf("prefixes = %v", prefixes)
+	}
+	if got := GitHubMirrorPrefix("ghfast", Config{}); got == "https://ghfast.top/" {
+		t.Error("retired mirror still used")
+	}
+}
+
+func TestMigrateMirrors(t *testing.T) {
+	t.Cleanup(func() {
+		SetRemoteMirrors(nil, nil)
+		SetMirrorScores(nil)
+	})
+	SetRemoteMirrors([]GitHubMirror{{Key: "ghfast", Retired: true}}, []DockerMirror{{Key: "ratdev", Retired: true}})
+
+	cfg, changed := MigrateMirrors(Config{Mirror: "ghfast", DockerMirror: "ratdev"})
+	if !changed || cfg.Mirror != DefaultMirror || cfg.DockerMirror != DefaultDockerMirror {
+		t.Errorf("migrated = %+v, changed %v", cfg, changed)
+	}
+
+	SetMirrorScores(map[string]MirrorScore{"cdn-ghproxy": MirrorScore{}.Update(true, 50*time.Millisecond, 2000, time.Now())})
+	if cfg, _ := MigrateMirrors(Config{Mirror: "removed-key"}); cfg.Mirror != "cdn-ghproxy" {
+		t.Errorf("migrated to %q, want the best scored mirror", cfg.Mirror)
+	}
+
+	for _, key := range []string{MirrorAuto, "custom", "direct", "gh-proxy"} {
+		if _, changed := MigrateMirrors(Config{Mirror: key, DockerMirror: "daocloud"}); changed {
+			t.Errorf("%s migrated", key)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package core
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"fnos-store/internal/source"
+)
+
+// Host describes the machine the store runs on, to decide which catalog apps
+// it can run. The zero Host accepts every app.
+type Host struct {
+	// Arch is the host's fpk platform name, as platform.DetectPlatform
+	// reports it ("x86", "arm").
+	Arch string
+	// FnOSVersion is the host's fnOS build ("1.2.0203"), as
+	// platform.FnOSVersion reports it. Empty or unparseable means unknown,
+	// and version ranges are not enforced.
+	FnOSVersion string
+}
+
+// incompatibility returns why item cannot run on h, or "" when it can. An app
+// that lists no platforms runs everywhere; one without a version range runs
+// on every fnOS release.
+func (h Host) incompatibility(item source.RemoteApp) string {
+	if h.Arch != "" && len(item.Platforms) > 0 && !slices.Contains(item.Platforms, h.Arch) {
+		return fmt.Sprintf("该应用不支持当前设备架构（%s），仅支持 %s", h.Arch, strings.Join(item.Platforms, "、"))
+	}
+
+	host, err := ParseFnOSVersion(h.FnOSVersion)
+	if err != nil {
+		return ""
+	}
+	if minV, err := ParseFnOSVersion(item.MinFnOSVersion); err == nil && host.Compare(minV) < 0 {
+		return fmt.Sprintf("该应用需要 fnOS %s 或更高版本，当前系统为 %s，请先升级飞牛系统", item.MinFnOSVersion, h.FnOSVersion)
+	}
+	// A shortened upper bound covers every build under it: "1.2" includes
+	// 1.2.0203.
+	if maxV, err := ParseFnOSVersion(item.MaxFnOSVersion); err == nil && host.ComparePrefix(maxV) > 0 {
+		return fmt.Sprintf("该应用仅支持 fnOS %s 及以下版本，当前系统为 %s", item.MaxFnOSVersion, h.FnOSVersion)
+	}
+	return ""
+}
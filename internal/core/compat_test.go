@@ -0,0 +1,137 @@
+package core
+
+import (
+	"testing"
+
+	"fnos-store/internal/source"
+)
+
+func TestMergeMarksIncompatibleApps(t *testing.T) {
+	r := NewRegistry()
+	r.SetHost(Host{Arch: "arm"})
+	apps := r.Merge([]Manifest{
+		{AppName: "emby", Version: "4.8.0"},
+	}, []source.RemoteApp{
+		{AppName: "jellyfin", Version: "10.10.7", Platforms: []string{"x86", "arm"}},
+		{AppName: "thunder", Version: "1.0", Platforms: []string{"x86"}},
+		{AppName: "emby", Version: "4.9.0", Platforms: []string{"x86"}},
+		{AppName: "any", Version: "1.0"},
+	}, nil)
+
+	byName := make(map[string]AppInfo)
+	for _, app := range apps {
+		byName[app.AppName] = app
+	}
+	if app := byName["jellyfin"]; app.IncompatibleReason != "" || app.Status == AppStatusIncompatible {
+		t.Errorf("jellyfin = %q (%s), want compatible", app.IncompatibleReason, app.Status)
+	}
+	if app := byName["any"]; app.IncompatibleReason != "" {
+		t.Errorf("app without platforms = %q, want compatible", app.IncompatibleReason)
+	}
+	if app := byName["thunder"]; app.Status != AppStatusIncompatible || app.IncompatibleReason == "" {
+		t.Errorf("thunder = %q (%s), want incompatible", app.IncompatibleReason, app.Status)
+	}
+	// An installed app keeps its install status; only its update is blocked.
+	if app := byName["emby"]; app.Status == AppStatusIncompatible || app.IncompatibleReason == "" {
+		t.Errorf("emby = %q (%s), want installed with a reason", app.IncompatibleReason, app.Status)
+	}
+}
+
+func TestParseFnOSVersion(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.0203", "1.2.0203", 0},
+		{"1.2.0203", "1.2.0204", -1},
+		{"1.2.0203", "1.2.12", 1},
+		{"1.3", "1.2.9999", 1},
+		{"1.2", "1.2.0", 0},
+		{"1.2.0203-beta", "1.2.0203", 0},
+		{" 1.1.2188\n", "1.2.0203", -1},
+	}
+	for _, tt := range tests {
+		a, err := ParseFnOSVersion(tt.a)
+		if err != nil {
+			t.Fatalf("ParseFnOSVersion(%q): %v", tt.a, err)
+		}
+		b, err := ParseFnOSVersion(tt.b)
+		if err != nil {
+			t.Fatalf("ParseFnOSVersion(%q): %v", tt.b, err)
+		}
+		if got := a.Compare(b); got != tt.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "mock", "1.2.3.4", "1.x", "1..2", "+1.2"} {
+		if _, err := ParseFnOSVersion(bad); err == nil {
+			t.Errorf("ParseFnOSVersion(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestMergeEnforcesFnOSVersionRange(t *testing.T) {
+	remote := []source.RemoteApp{
+		{AppName: "newer", Version: "1.0", MinFnOSVersion: "1.2.0300"},
+		{AppName: "older", Version: "1.0", MaxFnOSVersion: "1.1"},
+		{AppName: "inside", Version: "1.0", MinFnOSVersion: "1.2", MaxFnOSVersion: "1.2.0203"},
+		{AppName: "shortmax", Version: "1.0", MaxFnOSVersion: "1.2"},
+		{AppName: "majormax", Version: "1.0", MaxFnOSVersion: "1"},
+	}
+
+	r := NewRegistry()
+	r.SetHost(Host{FnOSVersion: "1.2.0203"})
+	status := make(map[string]AppStatus)
+	for _, app := range r.Merge(nil, remote, nil) {
+		status[app.AppName] = app.Status
+	}
+	want := map[string]AppStatus{
+		"newer":  AppStatusIncompatible,
+		"older":  AppStatusIncompatible,
+		"inside": AppStatusNotInstalled,
+		// "1.2" bounds the whole 1.2 series, not just 1.2.0.
+		"shortmax": AppStatusNotInstalled,
+		"majormax": AppStatusNotInstalled,
+	}
+	for name, st := range want {
+		if status[name] != st {
+			t.Errorf("%s: status = %s, want %s", name, status[name], st)
+		}
+	}
+
+	// An unknown host version does not block anything.
+	r.SetHost(Host{FnOSVersion: "mock"})
+	for _, app := range r.Merge(nil, remote, nil) {
+		if app.Status == AppStatusIncompatible {
+			t.Errorf("%s incompatible on an unknown fnOS version", app.AppName)
+		}
+	}
+}
+
+func TestMergeAppliesUpgradeBlocks(t *testing.T) {
+	r := NewRegistry()
+	r.SetHost(Host{FnOSVersion: "1.2.0203"})
+	r.SetUpgradeBlocks([]source.AppUpgradeBlock{
+		{AppName: "gopeed", Versions: []string{"1.7.0"}, Reason: "loses downloads"},
+		{AppName: "emby", FnOSVersions: []string{"1.3.0"}, Reason: "not on this build"},
+	})
+	apps := r.Merge([]Manifest{
+		{AppName: "gopeed", Version: "1.6.0"},
+		{AppName: "emby", Version: "4.8.0"},
+	}, []source.RemoteApp{
+		{AppName: "gopeed", Version: "1.7.0"},
+		{AppName: "emby", Version: "4.9.0"},
+		{AppName: "fresh", Version: "1.0"},
+	}, nil)
+
+	for _, app := range apps {
+		want := ""
+		if app.AppName == "gopeed" {
+			want = "loses downloads"
+		}
+		if app.UpgradeBlockedReason != want {
+			t.Errorf("%s: blocked = %q, want %q", app.AppName, app.UpgradeBlockedReason, want)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -18,12 +19,16 @@ type DownloadRequest struct {
 	URLs     []string
 	FileName string
 	AppName  string
+	// RateLimit caps this download in bytes per second, on top of the
+	// downloader's global limit. 0 means only the global limit applies.
+	RateLimit int64
 }
 
 type Downloader struct {
 	httpClient  *http.Client
 	downloadDir string
 	tmpDir      string
+	limiter     *RateLimiter // shared by all downloads
 }
 
 func NewDownloader(downloadDir string) *Downloader {
@@ -41,10 +46,25 @@ func NewDownloader(downloadDir string) *Downloader {
 		httpClient:  &http.Client{Transport: transport},
 		downloadDir: downloadDir,
 		tmpDir:      os.TempDir(),
+		limiter:     NewRateLimiter(0),
 	}
 }
 
-func (d *Downloader) CleanupStaleTmpFiles() error {
+// SetRateLimit caps all downloads together at bytesPerSec; 0 is unlimited.
+// Running downloads follow the new limit.
+func (d *Downloader) SetRateLimit(bytesPerSec int64) {
+	d.limiter.SetRate(bytesPerSec)
+}
+
+// SetProxy routes downloads through proxy, an http.Transport Proxy function.
+func (d *Downloader) SetProxy(proxy func(*http.Request) (*url.URL, error)) {
+	d.httpClient.Transport.(*http.Transport).Proxy = proxy
+}
+
+// CleanupStaleTmpFiles removes partial downloads not written to for at least
+// olderThan. A running download keeps its file fresh, so it is left alone;
+// 0 removes every partial download, for startup before any can run.
+func (d *Downloader) CleanupStaleTmpFiles(olderThan time.Duration) error {
 	entries, err := os.ReadDir(d.downloadDir)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
@@ -57,9 +77,16 @@ func (d *Downloader) CleanupStaleTmpFiles() error {
 		if entry.IsDir() {
 			continue
 		}
-		if strings.HasSuffix(entry.Name(), ".fpk.tmp") {
-			_ = os.Remove(filepath.Join(d.downloadDir, entry.Name()))
+		if !strings.HasSuffix(entry.Name(), ".fpk.tmp") {
+			continue
+		}
+		if olderThan > 0 {
+			info, err := entry.Info()
+			if err != nil || time.Since(info.ModTime()) < olderThan {
+				continue
+			}
 		}
+		_ = os.Remove(filepath.Join(d.downloadDir, entry.Name()))
 	}
 	return nil
 }
@@ -87,9 +114,14 @@ func (d *Downloader) Download(ctx context.Context, req DownloadRequest, progress
 		return "", errors.New("download urls are empty")
 	}
 
+	var opLimiter *RateLimiter
+	if req.RateLimit > 0 {
+		opLimiter = NewRateLimiter(req.RateLimit)
+	}
+
 	var lastErr error
 	for _, url := range urls {
-		if err := d.downloadFromURL(ctx, url, tmpPath, progress); err != nil {
+		if err := d.downloadFromURL(ctx, url, tmpPath, opLimiter, progress); err != nil {
 			lastErr = err
 			_ = os.Remove(tmpPath)
 			continue
@@ -108,7 +140,7 @@ func (d *Downloader) Download(ctx context.Context, req DownloadRequest, progress
 	return "", lastErr
 }
 
-func (d *Downloader) downloadFromURL(ctx context.Context, url, dstPath string, progress func(downloaded, total int64)) error {
+func (d *Downloader) downloadFromURL(ctx context.Context, url, dstPath string, opLimiter *RateLimiter, progress func(downloaded, total int64)) error {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return err
@@ -143,6 +175,12 @@ func (d *Downloader) downloadFromURL(ctx context.Context, url, dstPath string, p
 			if progress != nil {
 				progress(downloaded, total)
 			}
+			if err := opLimiter.WaitN(ctx, n); err != nil {
+				return err
+			}
+			if err := d.limiter.WaitN(ctx, n); err != nil {
+				return err
+			}
 		}
 		if readErr != nil {
 			if errors.Is(readErr, io.EOF) {
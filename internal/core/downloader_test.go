@@ -0,0 +1,74 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDownloaderUsesProxy(t *testing.T) {
+	payload := strings.Repeat("x", 16<<10)
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String() // a forward proxy sees the absolute URL
+		_, _ = w.Write([]byte(payload))
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	d := NewDownloader(t.TempDir())
+	d.SetProxy(http.ProxyURL(proxyURL))
+	path, err := d.Download(context.Background(), DownloadRequest{
+		URLs:     []string{"http://github.invalid/app.fpk"},
+		FileName: "app.fpk",
+		AppName:  "app",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proxied != "http://github.invalid/app.fpk" {
+		t.Errorf("proxy saw %q", proxied)
+	}
+	if data, _ := os.ReadFile(path); string(data) != payload {
+		t.Errorf("downloaded %d bytes, want %d", len(data), len(payload))
+	}
+}
+
+func TestCleanupStaleTmpFilesSparesFreshDownloads(t *testing.T) {
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "old.fpk.tmp")
+	fresh := filepath.Join(dir, "new.fpk.tmp")
+	for _, path := range []string{stale, fresh} {
+		if err := os.WriteFile(path, []byte("partial"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDownloader(dir)
+	if err := d.CleanupStaleTmpFiles(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("stale partial download kept")
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Errorf("partial download still being written was removed: %v", err)
+	}
+
+	if err := d.CleanupStaleTmpFiles(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(fresh); !os.IsNotExist(err) {
+		t.Error("cleanup with no age kept a partial download")
+	}
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// minBurst keeps a limit below one read buffer from stalling every read on
+// a full refill.
+const minBurst = 128 * 1024
+
+// RateLimiter is a token bucket of bytes per second. It may run into debt: a
+// read larger than the tokens left is let through and the next caller waits
+// it off. The zero rate is unlimited.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a limiter of bytesPerSec, starting with a full
+// bucket; 0 or less is unlimited.
+func NewRateLimiter(bytesPerSec int64) *RateLimiter {
+	l := &RateLimiter{}
+	l.SetRate(bytesPerSec)
+	l.tokens = l.burst()
+	return l
+}
+
+// SetRate changes the limit; 0 or less is unlimited.
+func (l *RateLimiter) SetRate(bytesPerSec int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = max(float64(bytesPerSec), 0)
+	l.tokens = min(l.tokens, l.burst())
+	l.last = time.Now()
+}
+
+func (l *RateLimiter) burst() float64 {
+	return max(l.rate, minBurst)
+}
+
+// WaitN takes n bytes from the bucket, blocking while it is in debt.
+func (l *RateLimiter) WaitN(ctx context.Context, n int) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	if l.rate == 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*l.rate, l.burst())
+	l.last = now
+	l.tokens -= float64(n)
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	l := NewRateLimiter(1 << 20)
+
+	start := time.Now()
+	if err := l.WaitN(ctx, 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > 50*time.Millisecond {
+		t.Errorf("full bucket waited %s", d)
+	}
+	start = time.Now()
+	if err := l.WaitN(ctx, 256<<10); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 200*time.Millisecond || d > time.Second {
+		t.Errorf("256 KiB over the bucket at 1 MiB/s waited %s, want about 250ms", d)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := l.WaitN(cancelled, 1<<20); err == nil {
+		t.Error("wait in debt ignored the cancelled context")
+	}
+
+	l.SetRate(0)
+	start = time.Now()
+	if err := l.WaitN(ctx, 100<<20); err != nil || time.Since(start) > 50*time.Millisecond {
+		t.Errorf("unlimited: err = %v after %s", err, time.Since(start))
+	}
+	if err := (*RateLimiter)(nil).WaitN(ctx, 1); err != nil {
+		t.Errorf("nil limiter: %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"fnos-store/internal/source"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -13,6 +14,9 @@ const (
 	AppStatusNotInstalled      AppStatus = "not_installed"
 	AppStatusInstalledUpToDate AppStatus = "installed_up_to_date"
 	AppStatusUpdateAvailable   AppStatus = "update_available"
+	// AppStatusIncompatible is a catalog app that is not installed and cannot
+	// run on this host (see AppInfo.IncompatibleReason).
+	AppStatusIncompatible AppStatus = "incompatible"
 )
 
 type AppInfo struct {
@@ -37,12 +41,24 @@ type AppInfo struct {
 	Status            AppStatus
 	HasRevisionUpdate bool
 	PostInstallNote   string
+	RedactPatterns    []string
+	// IncompatibleReason says why the catalog's build of the app cannot run
+	// on this host; empty when it can. Installing or updating it is refused.
+	IncompatibleReason string
+	// UpgradeBlockedReason is set when the catalog's safety list refuses
+	// updating this installed app to LatestVersion.
+	UpgradeBlockedReason string
+	// Changelog is the catalog's changelog for the app, newest first.
+	Changelog []source.ChangelogEntry
 }
 
 type Registry struct {
-	apps       map[string]AppInfo
-	updatedAt  time.Time
-	lastResult []AppInfo
+	host          Host
+	upgradeBlocks []source.AppUpgradeBlock
+	apps          map[string]AppInfo
+	updatedAt     time.Time
+	lastResult    []AppInfo
+	index         *searchIndex
 }
 
 func NewRegistry() *Registry {
@@ -51,6 +67,27 @@ func NewRegistry() *Registry {
 	}
 }
 
+// SetHost sets the host apps are checked against on the next Merge.
+func (r *Registry) SetHost(h Host) {
+	r.host = h
+}
+
+// SetUpgradeBlocks sets the catalog's per-app update blocks applied on the
+// next Merge.
+func (r *Registry) SetUpgradeBlocks(blocks []source.AppUpgradeBlock) {
+	r.upgradeBlocks = slices.Clone(blocks)
+}
+
+// upgradeBlock returns why updating appname to version is refused, or "".
+func (r *Registry) upgradeBlock(appname, version string) string {
+	for _, b := range r.upgradeBlocks {
+		if b.AppName == appname && b.Applies(version, r.host.FnOSVersion) {
+			return b.Reason
+		}
+	}
+	return ""
+}
+
 func (r *Registry) Merge(local []Manifest, remote []source.RemoteApp, installedTags map[string]string) []AppInfo {
 	localByName := make(map[string]Manifest, len(local))
 	for _, item := range local {
@@ -81,10 +118,17 @@ func (r *Registry) Merge(local []Manifest, remote []source.RemoteApp, installedT
 			Category:        item.Category,
 			Status:          AppStatusNotInstalled,
 			PostInstallNote: item.PostInstallNote,
+			RedactPatterns:  item.RedactPatterns,
+			Changelog:       item.Changelog,
+		}
+		app.IncompatibleReason = r.host.incompatibility(item)
+		if !installed && app.IncompatibleReason != "" {
+			app.Status = AppStatusIncompatible
 		}
 
 		if installed {
 			app.InstalledVersion = localManifest.Version
+			app.UpgradeBlockedReason = r.upgradeBlock(item.AppName, item.Version)
 			if app.ServicePort == 0 {
 				app.ServicePort = localManifest.ServicePort
 			}
@@ -128,9 +172,24 @@ func (r *Registry) Merge(local []Manifest, remote []source.RemoteApp, installedT
 
 	r.updatedAt = time.Now()
 	r.lastResult = result
+	r.index = buildSearchIndex(result)
 	return result
 }
 
+// Search returns the apps whose names, pinyin or description match every
+// term of query, best match first. A query without terms returns List().
+func (r *Registry) Search(query string) []AppInfo {
+	if r.index == nil || len(tokenize(query)) == 0 {
+		return r.List()
+	}
+	docs := r.index.search(query)
+	out := make([]AppInfo, 0, len(docs))
+	for _, doc := range docs {
+		out = append(out, r.lastResult[doc])
+	}
+	return out
+}
+
 func (r *Registry) List() []AppInfo {
 	out := make([]AppInfo, len(r.lastResult))
 	copy(out, r.lastResult)
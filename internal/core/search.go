@@ -0,0 +1,191 @@
+package core
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/mozillazg/go-pinyin"
+)
+
+// Field weights: a hit in the name counts more than one in the description.
+const (
+	weightName        = 4
+	weightPinyin      = 3
+	weightDescription = 1
+)
+
+// searchIndex is an inverted index over the catalog's app names, display
+// names (with their pinyin) and descriptions. It is rebuilt on every Merge;
+// the catalog is small enough that rebuilding beats keeping it in sync.
+type searchIndex struct {
+	terms    []string             // sorted, for prefix lookups
+	postings map[string][]posting // term -> documents containing it
+}
+
+type posting struct {
+	doc    int // index into Registry.lastResult
+	weight int
+}
+
+func buildSearchIndex(apps []AppInfo) *searchIndex {
+	idx := &searchIndex{postings: make(map[string][]posting)}
+	for doc, app := range apps {
+		best := make(map[string]int)
+		add := func(terms []string, weight int) {
+			for _, t := range terms {
+				if weight > best[t] {
+					best[t] = weight
+				}
+			}
+		}
+		add(tokenize(app.AppName), weightName)
+		add(tokenize(app.DisplayName), weightName)
+		add(pinyinTerms(app.DisplayName), weightPinyin)
+		add(tokenize(app.Description), weightDescription)
+
+		for term, weight := range best {
+			idx.postings[term] = append(idx.postings[term], posting{doc: doc, weight: weight})
+		}
+	}
+	idx.terms = make([]string, 0, len(idx.postings))
+	for term := range idx.postings {
+		idx.terms = append(idx.terms, term)
+	}
+	sort.Strings(idx.terms)
+	return idx
+}
+
+// search returns the documents matching every term of query, best first. An
+// ASCII query term matches index terms it is a prefix of, so "jelly" finds
+// Jellyfin and "xunl" finds 迅雷; a Chinese character must match exactly.
+func (idx *searchIndex) search(query string) []int {
+	qterms := tokenize(query)
+	if len(qterms) == 0 {
+		return nil
+	}
+
+	var scores map[int]int
+	for _, qt := range qterms {
+		hits := make(map[int]int)
+		for _, term := range idx.matching(qt) {
+			exact := 1
+			if term == qt {
+				exact = 2
+			}
+			for _, p := range idx.postings[term] {
+				if s := p.weight * exact; s > hits[p.doc] {
+					hits[p.doc] = s
+				}
+			}
+		}
+		if scores == nil {
+			scores = hits
+			continue
+		}
+		for doc, s := range scores {
+			if h, ok := hits[doc]; ok {
+				scores[doc] = s + h
+			} else {
+				delete(scores, doc)
+			}
+		}
+	}
+
+	docs := make([]int, 0, len(scores))
+	for doc := range scores {
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		if scores[docs[i]] != scores[docs[j]] {
+			return scores[docs[i]] > scores[docs[j]]
+		}
+		return docs[i] < docs[j]
+	})
+	return docs
+}
+
+// matching returns the index terms a query term matches.
+func (idx *searchIndex) matching(qt string) []string {
+	if isHanTerm(qt) {
+		if _, ok := idx.postings[qt]; ok {
+			return []string{qt}
+		}
+		return nil
+	}
+	start := sort.SearchStrings(idx.terms, qt)
+	end := start
+	for end < len(idx.terms) && strings.HasPrefix(idx.terms[end], qt) {
+		end++
+	}
+	return idx.terms[start:end]
+}
+
+// tokenize lower-cases s and splits it into words of letters and digits.
+// Chinese characters become one term each, since Chinese text has no spaces
+// to split words on.
+func tokenize(s string) []string {
+	var terms []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			terms = append(terms, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flush()
+			terms = append(terms, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return terms
+}
+
+var pinyinArgs = pinyin.NewArgs()
+
+// pinyinTerms returns the pinyin of the Chinese characters in s: each
+// syllable, the whole reading ("xunlei") and its initials ("xl").
+func pinyinTerms(s string) []string {
+	syllables := pinyin.LazyPinyin(s, pinyinArgs)
+	if len(syllables) == 0 {
+		return nil
+	}
+	var whole, initials strings.Builder
+	terms := make([]string, 0, len(syllables)+2)
+	for _, syl := range syllables {
+		terms = append(terms, syl)
+		whole.WriteString(syl)
+		initials.WriteByte(syl[0])
+	}
+	return append(terms, whole.String(), initials.String())
+}
+
+// NameSortKey orders display names alphabetically across scripts: Chinese
+// characters sort by their pinyin, so 迅雷 files under X rather than after Z.
+func NameSortKey(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.Is(unicode.Han, r) {
+			if py := pinyin.LazyPinyin(string(r), pinyinArgs); len(py) > 0 {
+				b.WriteString(py[0])
+				continue
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func isHanTerm(t string) bool {
+	for _, r := range t {
+		return unicode.Is(unicode.Han, r)
+	}
+	return false
+}
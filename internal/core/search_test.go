@@ -0,0 +1,64 @@
+package core
+
+import (
+	"testing"
+
+	"fnos-store/internal/source"
+)
+
+func searchNames(r *Registry, query string) []string {
+	var names []string
+	for _, app := range r.Search(query) {
+		names = append(names, app.AppName)
+	}
+	return names
+}
+
+func TestRegistrySearch(t *testing.T) {
+	r := NewRegistry()
+	r.Merge(nil, []source.RemoteApp{
+		{AppName: "jellyfin", DisplayName: "Jellyfin", Description: "自由的媒体服务器", UpdatedAt: "2026-03-01"},
+		{AppName: "thunder", DisplayName: "迅雷", Description: "下载工具", UpdatedAt: "2026-02-01"},
+		{AppName: "emby", DisplayName: "Emby", Description: "Media server, like Jellyfin", UpdatedAt: "2026-01-01"},
+		{AppName: "qbittorrent", DisplayName: "qBittorrent", Description: "BitTorrent 下载工具", UpdatedAt: "2025-12-01"},
+	}, nil)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"jellyfin", "thunder", "emby", "qbittorrent"}},
+		// A name hit ranks above a description hit.
+		{"jelly", []string{"jellyfin", "emby"}},
+		{"JELLYFIN", []string{"jellyfin", "emby"}},
+		{"迅雷", []string{"thunder"}},
+		{"xunlei", []string{"thunder"}},
+		{"xunl", []string{"thunder"}},
+		{"xl", []string{"thunder"}},
+		{"xun lei", []string{"thunder"}},
+		{"下载", []string{"thunder", "qbittorrent"}},
+		{"media server", []string{"emby"}},
+		{"媒体", []string{"jellyfin"}},
+		{"nothing-matches", nil},
+		{"  !! ", []string{"jellyfin", "thunder", "emby", "qbittorrent"}},
+	}
+	for _, tt := range tests {
+		got := searchNames(r, tt.query)
+		if len(got) != len(tt.want) {
+			t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+				break
+			}
+		}
+	}
+
+	// The index follows the catalog.
+	r.Merge(nil, []source.RemoteApp{{AppName: "emby", DisplayName: "Emby"}}, nil)
+	if got := searchNames(r, "jelly"); len(got) != 0 {
+		t.Errorf("after Merge, Search(jelly) = %v, want none", got)
+	}
+}
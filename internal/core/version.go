@@ -1,6 +1,8 @@
 package core
 
 import (
+	"cmp"
+	"fmt"
 	"strconv"
 	"strings"
 	"unicode"
@@ -102,3 +104,59 @@ func extractLeadingDigits(s string) string {
 	}
 	return b.String()
 }
+
+// FnOSVersion is a parsed fnOS build string such as "1.2.0203": major, minor
+// and a zero-padded build number.
+type FnOSVersion struct {
+	Major, Minor, Build int
+	// parts is how many of the three the string gave.
+	parts int
+}
+
+// ParseFnOSVersion parses an fnOS build string. Missing trailing parts count
+// as zero, so "1.2" is 1.2.0; anything after a '-' or space (a channel tag
+// such as "-beta") is ignored.
+func ParseFnOSVersion(s string) (FnOSVersion, error) {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexAny(s, "- "); i >= 0 {
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if s == "" || len(parts) > 3 {
+		return FnOSVersion{}, fmt.Errorf("invalid fnOS version %q", s)
+	}
+	var nums [3]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 || extractLeadingDigits(p) != p {
+			return FnOSVersion{}, fmt.Errorf("invalid fnOS version %q", s)
+		}
+		nums[i] = n
+	}
+	return FnOSVersion{Major: nums[0], Minor: nums[1], Build: nums[2], parts: len(parts)}, nil
+}
+
+// Compare returns -1, 0 or 1 as v is older than, the same as or newer than o.
+func (v FnOSVersion) Compare(o FnOSVersion) int {
+	switch {
+	case v.Major != o.Major:
+		return cmp.Compare(v.Major, o.Major)
+	case v.Minor != o.Minor:
+		return cmp.Compare(v.Minor, o.Minor)
+	default:
+		return cmp.Compare(v.Build, o.Build)
+	}
+}
+
+// ComparePrefix is Compare over only the parts o was written with, so every
+// 1.2.x build is the same as "1.2". An upper bound is meant that way: "up to
+// 1.2" includes 1.2.0203.
+func (v FnOSVersion) ComparePrefix(o FnOSVersion) int {
+	if o.parts < 3 {
+		v.Build, o.Build = 0, 0
+	}
+	if o.parts < 2 {
+		v.Minor, o.Minor = 0, 0
+	}
+	return v.Compare(o)
+}
@@ -0,0 +1,203 @@
+package diagnostics
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces every secret value removed from diagnostic output.
+const Redacted = "***REDACTED***"
+
+// RedactedIP replaces IP addresses when IP redaction is on.
+const RedactedIP = "x.x.x.x"
+
+// secretKey matches setting names whose values must not leave the box.
+var secretKey = regexp.MustCompile(`(?i)(pass(word|wd)?|secret|token|api[_-]?key|private[_-]?key|credential|auth)`)
+
+// assignment matches one "KEY: value" (YAML mapping) or "KEY=value" (.env,
+// compose environment list) setting on a line, capturing the indentation and
+// list marker, the key, the separator and the value.
+var assignment = regexp.MustCompile(`^(\s*(?:-\s*)?)(["']?)([A-Za-z_][A-Za-z0-9_.\-]*)(["']?)(\s*[:=]\s*)(.+)$`)
+
+// RedactSecretValues blanks the value of every setting whose name looks like
+// a secret (PASSWORD, *_TOKEN, API_KEY, ...) in config-style text such as a
+// compose file or .env. It works line by line so the output keeps the
+// original layout and comments; a value spread over several lines keeps its
+// continuation lines.
+func RedactSecretValues(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		m := assignment.FindStringSubmatch(line)
+		if m == nil || !secretKey.MatchString(m[3]) {
+			continue
+		}
+		value := strings.TrimSpace(m[6])
+		if value == "" || value == "|" || value == ">" || strings.HasPrefix(value, "#") {
+			continue
+		}
+		lines[i] = m[1] + m[2] + m[3] + m[4] + m[5] + Redacted
+	}
+	return strings.Join(lines, "\n")
+}
+
+// redactRule replaces what pattern matches. With keep > 0 the first keep
+// capture groups survive (the "password=" in front of a password) and only
+// the rest of the match is replaced.
+type redactRule struct {
+	name    string
+	pattern *regexp.Regexp
+	keep    int
+	replace string
+}
+
+// builtinRules cover secrets that show up in app and CLI output regardless of
+// the app. Order matters: header and URL rules run before the generic
+// key=value rule, which would otherwise eat "Authorization: Bearer" as a key.
+var builtinRules = []redactRule{
+	{
+		name:    "authorization header",
+		pattern: regexp.MustCompile(`(?i)(\b(?:proxy-)?authorization["']?\s*[:=]\s*["']?(?:bearer|basic|token|digest)\s+)[^\s"',;]+`),
+		keep:    1,
+		replace: Redacted,
+	},
+	{
+		name:    "bearer token",
+		pattern: regexp.MustCompile(`(?i)(\bbearer\s+)[A-Za-z0-9\-._~+/]+=*`),
+		keep:    1,
+		replace: Redacted,
+	},
+	{
+		name:    "url credentials",
+		pattern: regexp.MustCompile(`(\b[a-zA-Z][a-zA-Z0-9+.\-]*://[^/\s:@]+:)[^/\s@]+@`),
+		keep:    1,
+		replace: Redacted + "@",
+	},
+	{
+		name:    "secret assignment",
+		pattern: regexp.MustCompile(`(?i)(\b[\w.\-]*(?:password|passwd|secret|token|api[_\-]?key|access[_\-]?key|private[_\-]?key|credential)[\w.\-]*["']?\s*[:=]\s*["']?)[^\s"'&,;}]+`),
+		keep:    1,
+		replace: Redacted,
+	},
+	{
+		name:    "github token",
+		pattern: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`),
+		replace: Redacted,
+	},
+	{
+		name:    "jwt",
+		pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]+\.eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+`),
+		replace: Redacted,
+	},
+	{
+		name:    "private key block",
+		pattern: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+		replace: Redacted,
+	},
+}
+
+var ipv4Rule = redactRule{
+	name:    "ipv4 address",
+	pattern: regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`),
+	replace: RedactedIP,
+}
+
+// RedactOptions configures a Redactor.
+type RedactOptions struct {
+	// RedactIPs also replaces IPv4 addresses. Off by default: addresses are
+	// usually what a network failure is about.
+	RedactIPs bool
+	// AppPatterns are the catalog's extra rules for one app. A pattern with
+	// capture groups redacts only its first group; otherwise the whole match.
+	AppPatterns []string
+}
+
+// Redactor removes secrets from text before it leaves the box: logs served
+// to the UI, diagnostic reports, issue URLs and support bundles.
+type Redactor struct {
+	rules []redactRule
+	app   []*regexp.Regexp
+}
+
+// NewRedactor builds a Redactor. An app pattern that does not compile is
+// reported, and the Redactor still applies every other rule.
+func NewRedactor(opts RedactOptions) (*Redactor, error) {
+	r := &Redactor{rules: builtinRules}
+	if opts.RedactIPs {
+		r.rules = append(append([]redactRule(nil), builtinRules...), ipv4Rule)
+	}
+
+	var bad []string
+	for _, p := range opts.AppPatterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			bad = append(bad, fmt.Sprintf("%q: %v", p, err))
+			continue
+		}
+		r.app = append(r.app, re)
+	}
+	if len(bad) > 0 {
+		return r, fmt.Errorf("invalid redaction pattern: %s", strings.Join(bad, "; "))
+	}
+	return r, nil
+}
+
+// Redact returns text with every secret the rules recognise replaced. A nil
+// Redactor applies the built-in rules.
+func (r *Redactor) Redact(text string) string {
+	if text == "" {
+		return text
+	}
+	rules := builtinRules
+	var app []*regexp.Regexp
+	if r != nil {
+		rules, app = r.rules, r.app
+	}
+
+	for _, rule := range rules {
+		text = rule.apply(text)
+	}
+	for _, re := range app {
+		text = redactAppPattern(re, text)
+	}
+	return text
+}
+
+func (rule redactRule) apply(text string) string {
+	if rule.keep == 0 {
+		return rule.pattern.ReplaceAllLiteralString(text, rule.replace)
+	}
+	return rule.pattern.ReplaceAllStringFunc(text, func(match string) string {
+		sub := rule.pattern.FindStringSubmatch(match)
+		if sub == nil {
+			return match
+		}
+		return strings.Join(sub[1:rule.keep+1], "") + rule.replace
+	})
+}
+
+func redactAppPattern(re *regexp.Regexp, text string) string {
+	if re.NumSubexp() == 0 {
+		return re.ReplaceAllLiteralString(text, Redacted)
+	}
+	var b strings.Builder
+	last := 0
+	for _, loc := range re.FindAllStringSubmatchIndex(text, -1) {
+		start, end := loc[2], loc[3]
+		if start < 0 {
+			continue
+		}
+		b.WriteString(text[last:start])
+		b.WriteString(Redacted)
+		last = end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// RedactReport redacts the free-text fields of a report.
+func (r *Redactor) RedactReport(report DiagnosticReport) DiagnosticReport {
+	report.ErrorMessage = r.Redact(report.ErrorMessage)
+	report.LogTail = r.Redact(report.LogTail)
+	return report
+}
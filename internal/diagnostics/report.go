@@ -71,7 +71,13 @@ func TruncateError(s string) string {
 	return validUTF8Prefix(s, MaxErrorMsgBytes)
 }
 
+// BuildIssueURL builds a pre-filled GitHub issue URL for r. The built-in
+// redaction rules are applied again here, since whatever goes into the URL is
+// published as soon as the user submits it.
 func BuildIssueURL(r DiagnosticReport) (string, error) {
+	var red *Redactor
+	r = red.RedactReport(r)
+
 	values := url.Values{}
 	values.Set("template", IssueTemplate)
 	values.Set("app", r.App)
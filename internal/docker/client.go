@@ -0,0 +1,470 @@
+// Package docker is a minimal Docker Engine API client that talks to the
+// daemon over its unix socket.
+//
+// The store used to shell out to the docker CLI and scrape its human-oriented
+// output: pull progress was faked by substring-matching "Pull complete" and
+// "Waiting", and containers were found by grepping compose files. The Engine
+// API reports the same information as structured JSON — real per-layer byte
+// counts, compose labels, multiplexed log frames — so reading it directly is
+// both more accurate and independent of the CLI being installed.
+package docker
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultSocket is where dockerd listens on fnOS (and most Linux systems).
+const DefaultSocket = "/var/run/docker.sock"
+
+// apiVersion pins the request path so the daemon answers with a stable
+// schema. 1.41 is Docker 20.10, older than any engine fnOS has shipped.
+const apiVersion = "v1.41"
+
+// Compose labels, set by docker compose on every container it creates.
+const (
+	LabelComposeProject    = "com.docker.compose.project"
+	LabelComposeService    = "com.docker.compose.service"
+	LabelComposeWorkingDir = "com.docker.compose.project.working_dir"
+	LabelComposeFiles      = "com.docker.compose.project.config_files"
+)
+
+// ErrNotFound is returned (wrapped in *APIError) when the daemon answers 404.
+var ErrNotFound = errors.New("not found")
+
+// APIError carries the daemon's status code and message.
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("docker: %s (HTTP %d)", e.Message, e.Status)
+}
+
+func (e *APIError) Is(target error) bool {
+	return target == ErrNotFound && e.Status == http.StatusNotFound
+}
+
+// Client talks to dockerd. The zero value is not usable; use NewClient.
+type Client struct {
+	socket string
+	http   *http.Client
+}
+
+// NewClient returns a client for the daemon listening on socket.
+// No timeout is set on the HTTP client: pulls and followed logs are
+// legitimately long-lived, so callers bound requests with their context.
+func NewClient(socket string) *Client {
+	if socket == "" {
+		socket = DefaultSocket
+	}
+	return &Client{
+		socket: socket,
+		http: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values) (*http.Response, error) {
+	u := "http://docker/" + apiVersion + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("docker daemon unreachable (%s): %w", c.socket, err)
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, decodeAPIError(resp)
+	}
+	return resp, nil
+}
+
+func decodeAPIError(resp *http.Response) error {
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var body struct {
+		Message string `json:"message"`
+	}
+	msg := strings.TrimSpace(string(raw))
+	if json.Unmarshal(raw, &body) == nil && body.Message != "" {
+		msg = body.Message
+	}
+	if msg == "" {
+		msg = resp.Status
+	}
+	return &APIError{Status: resp.StatusCode, Message: msg}
+}
+
+// Ping reports whether the daemon is reachable.
+func (c *Client) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	resp, err := c.do(ctx, http.MethodGet, "/_ping", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Info is the subset of the daemon's /info the store uses.
+type Info struct {
+	// HTTPProxy, HTTPSProxy and NoProxy are the proxy dockerd itself pulls
+	// images through, from daemon.json "proxies" or its service environment.
+	// Credentials in the URLs come back masked.
+	HTTPProxy  string `json:"HttpProxy"`
+	HTTPSProxy string `json:"HttpsProxy"`
+	NoProxy    string `json:"NoProxy"`
+}
+
+// Info returns the daemon's system information.
+func (c *Client) Info(ctx context.Context) (Info, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	resp, err := c.do(ctx, http.MethodGet, "/info", nil)
+	if err != nil {
+		return Info{}, err
+	}
+	defer resp.Body.Close()
+	var info Info
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return Info{}, fmt.Errorf("decode daemon info: %w", err)
+	}
+	return info, nil
+}
+
+// PullProgress is the aggregate state of an image pull across all layers.
+// Downloaded and Total only count layers whose size the daemon has reported.
+// Completion is the mean completion of every layer seen so far (0..1): a
+// layer that is done or already present counts 1, one that is downloading
+// counts its byte fraction, one still waiting counts 0 — so a pull whose
+// later layers have not started does not read as nearly finished.
+type PullProgress struct {
+	Layers     int
+	LayersDone int
+	Downloaded int64
+	Total      int64
+	Completion float64
+	Status     string
+}
+
+type pullMessage struct {
+	Status         string `json:"status"`
+	ID             string `json:"id"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+	Error       string `json:"error"`
+	ErrorDetail struct {
+		Message string `json:"message"`
+	} `json:"errorDetail"`
+}
+
+type layerState struct {
+	current, total int64
+	done           bool
+}
+
+// Pull pulls ref and reports progress as the daemon streams it. The daemon
+// answers 200 and reports failures INSIDE the stream, so an error message in
+// any frame fails the pull even though the HTTP exchange succeeded.
+//
+// An untagged ref pulls its latest tag, as docker pull does; the Engine API
+// itself would pull every tag of the repository.
+func (c *Client) Pull(ctx context.Context, ref string, onProgress func(PullProgress)) error {
+	query := url.Values{"fromImage": {ref}}
+	if _, tag := SplitRef(ref); tag == "" && !strings.Contains(ref, "@") {
+		query.Set("tag", "latest")
+	}
+	resp, err := c.do(ctx, http.MethodPost, "/images/create", query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	layers := make(map[string]*layerState)
+	var order []string
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg pullMessage
+		if err := dec.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("read pull stream: %w", err)
+		}
+		if msg.Error != "" || msg.ErrorDetail.Message != "" {
+			detail := msg.ErrorDetail.Message
+			if detail == "" {
+				detail = msg.Error
+			}
+			return errors.New(detail)
+		}
+
+		if msg.ID != "" && isLayerStatus(msg.Status) {
+			st, ok := layers[msg.ID]
+			if !ok {
+				st = &layerState{}
+				layers[msg.ID] = st
+				order = append(order, msg.ID)
+			}
+			switch msg.Status {
+			case "Downloading":
+				st.current = msg.ProgressDetail.Current
+				if msg.ProgressDetail.Total > 0 {
+					st.total = msg.ProgressDetail.Total
+				}
+			case "Download complete", "Extracting", "Verifying Checksum":
+				if st.total > 0 {
+					st.current = st.total
+				}
+			case "Pull complete", "Already exists":
+				st.done = true
+				if st.total > 0 {
+					st.current = st.total
+				}
+			}
+		}
+
+		if onProgress != nil {
+			p := PullProgress{Layers: len(order), Status: msg.Status}
+			var sum float64
+			for _, id := range order {
+				st := layers[id]
+				switch {
+				case st.done:
+					p.LayersDone++
+					sum++
+				case st.total > 0:
+					sum += float64(st.current) / float64(st.total)
+				}
+				if st.total > 0 {
+					p.Downloaded += st.current
+					p.Total += st.total
+				}
+			}
+			if len(order) > 0 {
+				p.Completion = sum / float64(len(order))
+			}
+			onProgress(p)
+		}
+	}
+}
+
+// isLayerStatus filters out the per-image frames ("Pulling from ...",
+// "Digest: ...", "Status: ...") which also carry an id but are not layers.
+func isLayerStatus(status string) bool {
+	switch status {
+	case "Pulling fs layer", "Waiting", "Downloading", "Verifying Checksum",
+		"Download complete", "Extracting", "Pull complete", "Already exists":
+		return true
+	}
+	return false
+}
+
+// Tag adds target as a reference to the image source names.
+func (c *Client) Tag(ctx context.Context, source, target string) error {
+	repo, tag := SplitRef(target)
+	q := url.Values{"repo": {repo}}
+	if tag != "" {
+		q.Set("tag", tag)
+	}
+	resp, err := c.do(ctx, http.MethodPost, "/images/"+source+"/tag", q)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// SplitRef splits an image reference into repository and tag. A colon only
+// separates a tag when it comes after the last slash; before that it is a
+// registry port (localhost:5000/app).
+func SplitRef(ref string) (repo, tag string) {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		return ref[:i], ""
+	}
+	slash := strings.LastIndex(ref, "/")
+	if colon := strings.LastIndex(ref, ":"); colon > slash {
+		return ref[:colon], ref[colon+1:]
+	}
+	return ref, ""
+}
+
+// Image is the subset of an image inspection the store uses.
+type Image struct {
+	ID       string   `json:"Id"`
+	RepoTags []string `json:"RepoTags"`
+	Size     int64    `json:"Size"`
+}
+
+// InspectImage resolves ref to the image it names. A missing image returns
+// an error matching ErrNotFound.
+func (c *Client) InspectImage(ctx context.Context, ref string) (Image, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/images/"+ref+"/json", nil)
+	if err != nil {
+		return Image{}, err
+	}
+	defer resp.Body.Close()
+	var img Image
+	if err := json.NewDecoder(resp.Body).Decode(&img); err != nil {
+		return Image{}, fmt.Errorf("decode image %s: %w", ref, err)
+	}
+	return img, nil
+}
+
+// RemoveImage removes a reference or image ID WITHOUT force, so the daemon
+// still refuses (409) an image a container uses.
+func (c *Client) RemoveImage(ctx context.Context, ref string) error {
+	resp, err := c.do(ctx, http.MethodDelete, "/images/"+ref, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Container is the subset of a container listing the store uses.
+type Container struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	Image  string            `json:"Image"`
+	State  string            `json:"State"`
+	Labels map[string]string `json:"Labels"`
+}
+
+// Name returns the container's primary name without the leading slash.
+func (c Container) Name() string {
+	if len(c.Names) == 0 {
+		return c.ID
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
+}
+
+// ListContainers lists all containers, running or not, matching the given
+// label filters ("key" or "key=value").
+func (c *Client) ListContainers(ctx context.Context, labels ...string) ([]Container, error) {
+	q := url.Values{"all": {"1"}}
+	if len(labels) > 0 {
+		raw, err := json.Marshal(map[string][]string{"label": labels})
+		if err != nil {
+			return nil, err
+		}
+		q.Set("filters", string(raw))
+	}
+	resp, err := c.do(ctx, http.MethodGet, "/containers/json", q)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var out []Container
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode container list: %w", err)
+	}
+	return out, nil
+}
+
+// LogsOptions selects which log lines to read.
+type LogsOptions struct {
+	// Tail limits the output to the last N lines; 0 means all.
+	Tail int
+	// Since skips lines written before it, when set.
+	Since  time.Time
+	Follow bool
+}
+
+// Logs returns a container's combined stdout/stderr as plain text. Containers
+// without a TTY stream multiplexed frames, which are demultiplexed here so
+// callers always read lines.
+func (c *Client) Logs(ctx context.Context, container string, opts LogsOptions) (io.ReadCloser, error) {
+	tty, err := c.containerTTY(ctx, container)
+	if err != nil {
+		return nil, err
+	}
+
+	q := url.Values{"stdout": {"1"}, "stderr": {"1"}}
+	if opts.Tail > 0 {
+		q.Set("tail", strconv.Itoa(opts.Tail))
+	}
+	if !opts.Since.IsZero() {
+		q.Set("since", strconv.FormatInt(opts.Since.Unix(), 10))
+	}
+	if opts.Follow {
+		q.Set("follow", "1")
+	}
+	resp, err := c.do(ctx, http.MethodGet, "/containers/"+container+"/logs", q)
+	if err != nil {
+		return nil, err
+	}
+	if tty {
+		return resp.Body, nil
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer resp.Body.Close()
+		pw.CloseWithError(demux(pw, resp.Body))
+	}()
+	return pr, nil
+}
+
+func (c *Client) containerTTY(ctx context.Context, container string) (bool, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/containers/"+container+"/json", nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	var info struct {
+		Config struct {
+			Tty bool `json:"Tty"`
+		} `json:"Config"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return false, fmt.Errorf("decode container %s: %w", container, err)
+	}
+	return info.Config.Tty, nil
+}
+
+// demux copies the payload of docker's multiplexed log stream: each frame is
+// an 8-byte header (stream type, three zero bytes, big-endian payload size)
+// followed by the payload. stdout and stderr are merged in arrival order.
+func demux(dst io.Writer, src io.Reader) error {
+	r := bufio.NewReader(src)
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(dst, r, size); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,395 @@
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ComposeService is the part of a compose service definition the store acts
+// on: what to pull, what the container is called, and what it claims on the
+// host.
+type ComposeService struct {
+	Name          string          `json:"name"`
+	Image         string          `json:"image,omitempty"`
+	ContainerName string          `json:"container_name,omitempty"`
+	Ports         []ComposePort   `json:"ports,omitempty"`
+	Volumes       []ComposeVolume `json:"volumes,omitempty"`
+}
+
+// ComposePort is one published port. Published is empty when compose picks a
+// random host port; both sides may be ranges ("8000-8010").
+type ComposePort struct {
+	HostIP    string `json:"host_ip,omitempty"`
+	Published string `json:"published,omitempty"`
+	Target    string `json:"target"`
+	Protocol  string `json:"protocol"`
+}
+
+// ComposeVolume is one mount. Type is "bind" when Source is a host path,
+// "volume" for a named volume, and whatever the long syntax says otherwise.
+type ComposeVolume struct {
+	Type     string `json:"type"`
+	Source   string `json:"source,omitempty"`
+	Target   string `json:"target"`
+	ReadOnly bool   `json:"read_only,omitempty"`
+}
+
+// ParseCompose reads the services of a compose file, in file order, after
+// substituting ${VAR}, ${VAR:-default} and friends from env. Variables missing
+// from env become empty, as they do for docker compose. In a multi-document
+// file a later document's service replaces an earlier one of the same name.
+func ParseCompose(data []byte, env map[string]string) ([]ComposeService, error) {
+	var services []ComposeService
+	index := make(map[string]int)
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse compose: %w", err)
+		}
+		if err := interpolateNode(&doc, env); err != nil {
+			return nil, err
+		}
+
+		root := &doc
+		if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+			root = root.Content[0]
+		}
+		svcNode := mappingValue(root, "services")
+		if svcNode == nil {
+			continue
+		}
+		if svcNode.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("parse compose: services must be a mapping")
+		}
+		for i := 0; i+1 < len(svcNode.Content); i += 2 {
+			svc, err := parseService(svcNode.Content[i].Value, svcNode.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			if at, ok := index[svc.Name]; ok {
+				services[at] = svc
+				continue
+			}
+			index[svc.Name] = len(services)
+			services = append(services, svc)
+		}
+	}
+	return services, nil
+}
+
+// ReadEnvFile reads a compose-style .env file: KEY=VALUE lines, with blank
+// lines and # comments ignored and surrounding quotes stripped.
+func ReadEnvFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		env[strings.TrimSpace(key)] = value
+	}
+	return env, scanner.Err()
+}
+
+type rawService struct {
+	Image         string      `yaml:"image"`
+	ContainerName string      `yaml:"container_name"`
+	Ports         []yaml.Node `yaml:"ports"`
+	Volumes       []yaml.Node `yaml:"volumes"`
+}
+
+type rawPort struct {
+	Target    string `yaml:"target"`
+	Published string `yaml:"published"`
+	HostIP    string `yaml:"host_ip"`
+	Protocol  string `yaml:"protocol"`
+}
+
+type rawVolume struct {
+	Type     string `yaml:"type"`
+	Source   string `yaml:"source"`
+	Target   string `yaml:"target"`
+	ReadOnly bool   `yaml:"read_only"`
+}
+
+func parseService(name string, node *yaml.Node) (ComposeService, error) {
+	svc := ComposeService{Name: name}
+	var raw rawService
+	if err := node.Decode(&raw); err != nil {
+		return svc, fmt.Errorf("parse compose service %q: %w", name, err)
+	}
+	svc.Image = raw.Image
+	svc.ContainerName = raw.ContainerName
+
+	for _, p := range raw.Ports {
+		if p.Kind == yaml.MappingNode {
+			var rp rawPort
+			if err := p.Decode(&rp); err != nil {
+				return svc, fmt.Errorf("parse compose service %q ports: %w", name, err)
+			}
+			if rp.Protocol == "" {
+				rp.Protocol = "tcp"
+			}
+			svc.Ports = append(svc.Ports, ComposePort{HostIP: rp.HostIP, Published: rp.Published, Target: rp.Target, Protocol: rp.Protocol})
+			continue
+		}
+		svc.Ports = append(svc.Ports, parsePortSpec(p.Value))
+	}
+
+	for _, v := range raw.Volumes {
+		if v.Kind == yaml.MappingNode {
+			var rv rawVolume
+			if err := v.Decode(&rv); err != nil {
+				return svc, fmt.Errorf("parse compose service %q volumes: %w", name, err)
+			}
+			svc.Volumes = append(svc.Volumes, ComposeVolume(rv))
+			continue
+		}
+		svc.Volumes = append(svc.Volumes, parseVolumeSpec(v.Value))
+	}
+	return svc, nil
+}
+
+// parsePortSpec reads the short syntax: [[host_ip:]published:]target[/protocol].
+// The host IP may be a bracketed IPv6 address.
+func parsePortSpec(spec string) ComposePort {
+	port := ComposePort{Protocol: "tcp"}
+	if base, proto, ok := strings.Cut(spec, "/"); ok {
+		spec, port.Protocol = base, proto
+	}
+	if strings.HasPrefix(spec, "[") {
+		if end := strings.Index(spec, "]:"); end > 0 {
+			port.HostIP = spec[1:end]
+			spec = spec[end+2:]
+		}
+	}
+	parts := strings.Split(spec, ":")
+	switch len(parts) {
+	case 1:
+		port.Target = parts[0]
+	case 2:
+		port.Published, port.Target = parts[0], parts[1]
+	default:
+		port.HostIP = strings.Join(parts[:len(parts)-2], ":")
+		port.Published, port.Target = parts[len(parts)-2], parts[len(parts)-1]
+	}
+	return port
+}
+
+// parseVolumeSpec reads the short syntax: [source:]target[:mode]. A source
+// that looks like a path is a bind mount; anything else names a volume.
+func parseVolumeSpec(spec string) ComposeVolume {
+	parts := strings.Split(spec, ":")
+	vol := ComposeVolume{}
+	switch len(parts) {
+	case 1:
+		vol.Target = parts[0]
+	default:
+		vol.Source, vol.Target = parts[0], parts[1]
+		for _, mode := range parts[2:] {
+			for opt := range strings.SplitSeq(mode, ",") {
+				if opt == "ro" {
+					vol.ReadOnly = true
+				}
+			}
+		}
+	}
+	switch {
+	case vol.Source == "":
+		vol.Type = "volume"
+	case strings.HasPrefix(vol.Source, "/"), strings.HasPrefix(vol.Source, "."), strings.HasPrefix(vol.Source, "~"):
+		vol.Type = "bind"
+	default:
+		vol.Type = "volume"
+	}
+	return vol
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// interpolateNode substitutes variables in every scalar value. Mapping keys
+// are left alone, as compose does.
+func interpolateNode(node *yaml.Node, env map[string]string) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if !strings.Contains(node.Value, "$") {
+			return nil
+		}
+		value, err := Interpolate(node.Value, env)
+		if err != nil {
+			return fmt.Errorf("compose line %d: %w", node.Line, err)
+		}
+		node.Value = value
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := interpolateNode(node.Content[i], env); err != nil {
+				return err
+			}
+		}
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := interpolateNode(child, env); err != nil {
+				return err
+			}
+		}
+	}
+	// Aliases share their anchor's node, which is interpolated where it is
+	// defined.
+	return nil
+}
+
+// Interpolate applies compose variable substitution to s: $VAR, ${VAR},
+// ${VAR:-default} (unset or empty), ${VAR-default} (unset), ${VAR:?message}
+// and ${VAR?message} (required), with $$ for a literal dollar. Defaults may
+// themselves contain variables.
+func Interpolate(s string, env map[string]string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '$' || i+1 == len(s) {
+			b.WriteByte(c)
+			continue
+		}
+		next := s[i+1]
+		switch {
+		case next == '$':
+			b.WriteByte('$')
+			i++
+		case next == '{':
+			end := matchingBrace(s, i+1)
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable in %q", s)
+			}
+			value, err := expandBraced(s[i+2:end], env)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+			i = end
+		case isVarStart(next):
+			j := i + 1
+			for j < len(s) && isVarChar(s[j]) {
+				j++
+			}
+			b.WriteString(env[s[i+1:j]])
+			i = j - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+// expandBraced expands the inside of ${...}.
+func expandBraced(expr string, env map[string]string) (string, error) {
+	name, op, arg := expr, "", ""
+	if idx := strings.IndexAny(expr, ":-?"); idx >= 0 {
+		name = expr[:idx]
+		rest := expr[idx:]
+		for _, candidate := range []string{":-", ":?", "-", "?"} {
+			if strings.HasPrefix(rest, candidate) {
+				op, arg = candidate, rest[len(candidate):]
+				break
+			}
+		}
+		if op == "" {
+			return "", fmt.Errorf("invalid variable ${%s}", expr)
+		}
+	}
+	if name == "" || !isVarStart(name[0]) || strings.IndexFunc(name, func(r rune) bool { return r > 127 || !isVarChar(byte(r)) }) >= 0 {
+		return "", fmt.Errorf("invalid variable ${%s}", expr)
+	}
+
+	value, set := env[name]
+	switch op {
+	case ":-":
+		if value == "" {
+			return Interpolate(arg, env)
+		}
+	case "-":
+		if !set {
+			return Interpolate(arg, env)
+		}
+	case ":?":
+		if value == "" {
+			return "", requiredVarError(name, arg)
+		}
+	case "?":
+		if !set {
+			return "", requiredVarError(name, arg)
+		}
+	}
+	return value, nil
+}
+
+func requiredVarError(name, message string) error {
+	if message == "" {
+		return fmt.Errorf("required variable %s is missing a value", name)
+	}
+	return fmt.Errorf("required variable %s is missing a value: %s", name, message)
+}
+
+// matchingBrace returns the index of the '}' closing the '{' at open,
+// skipping nested ${...} in defaults.
+func matchingBrace(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isVarStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isVarChar(c byte) bool {
+	return isVarStart(c) || (c >= '0' && c <= '9')
+}
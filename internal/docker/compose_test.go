@@ -0,0 +1,107 @@
+package docker
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The line scanner this replaces broke on each of these: quoted values,
+// trailing comments, anchors, image:/container_name: keys inside environment
+// blocks, and a second YAML document.
+func TestParseCompose(t *testing.T) {
+	const compose = `
+x-common: &common
+  image: "${DOCKER_MIRROR}library/redis:${VERSION}" # pinned by the fpk
+  restart: unless-stopped
+
+services:
+  web:
+    image: 'nginx:1.27'   # trailing comment
+    container_name: "${APP:-demo}-web"
+    environment:
+      image: not-an-image
+      container_name: not-a-container
+    ports:
+      - "8080:80"
+      - 127.0.0.1:8443:443/tcp
+      - target: 53
+        published: "5353"
+        protocol: udp
+    volumes:
+      - ./data:/data:ro
+      - cache:/cache
+      - type: bind
+        source: /vol1/media
+        target: /media
+  cache:
+    <<: *common
+    container_name: demo-cache
+---
+services:
+  worker:
+    image: busybox:$VERSION
+`
+	env := map[string]string{"DOCKER_MIRROR": "mirror.example/", "VERSION": "1.2"}
+	got, err := ParseCompose([]byte(compose), env)
+	if err != nil {
+		t.Fatalf("ParseCompose: %v", err)
+	}
+
+	want := []ComposeService{
+		{
+			Name:          "web",
+			Image:         "nginx:1.27",
+			ContainerName: "demo-web",
+			Ports: []ComposePort{
+				{Published: "8080", Target: "80", Protocol: "tcp"},
+				{HostIP: "127.0.0.1", Published: "8443", Target: "443", Protocol: "tcp"},
+				{Published: "5353", Target: "53", Protocol: "udp"},
+			},
+			Volumes: []ComposeVolume{
+				{Type: "bind", Source: "./data", Target: "/data", ReadOnly: true},
+				{Type: "volume", Source: "cache", Target: "/cache"},
+				{Type: "bind", Source: "/vol1/media", Target: "/media"},
+			},
+		},
+		{Name: "cache", Image: "mirror.example/library/redis:1.2", ContainerName: "demo-cache"},
+		{Name: "worker", Image: "busybox:1.2"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseCompose() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	env := map[string]string{"SET": "v", "EMPTY": ""}
+	cases := []struct{ in, want string }{
+		{"${SET}", "v"},
+		{"$SET/x", "v/x"},
+		{"${MISSING}", ""},
+		{"${EMPTY:-d}", "d"},
+		{"${EMPTY-d}", ""},
+		{"${MISSING-d}", "d"},
+		{"${MISSING:-${SET}-x}", "v-x"},
+		{"$$SET", "$SET"},
+		{"cost: 5$", "cost: 5$"},
+	}
+	for _, c := range cases {
+		got, err := Interpolate(c.in, env)
+		if err != nil {
+			t.Errorf("Interpolate(%q): %v", c.in, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("Interpolate(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+
+	for _, in := range []string{"${MISSING:?need it}", "${EMPTY:?}", "${UNCLOSED", "${1BAD}"} {
+		if _, err := Interpolate(in, env); err == nil {
+			t.Errorf("Interpolate(%q) should fail", in)
+		}
+	}
+	if _, err := Interpolate("${MISSING:?need it}", env); err == nil || !strings.Contains(err.Error(), "need it") {
+		t.Errorf("required-variable error should carry its message, got %v", err)
+	}
+}
@@ -21,56 +21,59 @@ type VolumeInfo struct {
 // AppCenter abstracts appcenter-cli operations.
 // The real implementation calls appcenter-cli on Linux;
 // the mock implementation simulates it for development on macOS.
+//
+// Every method stops when ctx ends. appcenter-cli commands also have their
+// own time limit, after which they fail with ErrCLITimeout.
 type AppCenter interface {
 	// List returns all installed applications.
-	List() ([]InstalledApp, error)
+	List(ctx context.Context) ([]InstalledApp, error)
 
 	// Check returns true if the given app is installed.
-	Check(appname string) (bool, error)
+	Check(ctx context.Context, appname string) (bool, error)
 
 	// Status returns the running status of an app ("running" or "stopped").
-	Status(appname string) (string, error)
+	Status(ctx context.Context, appname string) (string, error)
 
 	// InstallFpk installs or upgrades an app from an fpk file on the given volume.
 	// It extracts the fpk and uses install-local internally for upgrade support.
-	InstallFpk(fpkPath string, volume int) error
+	InstallFpk(ctx context.Context, fpkPath string, volume int) error
 
 	// InstallLocal installs or upgrades an app from an extracted fpk directory.
 	// When detach is true, the process is launched in a new session so it
 	// survives the caller being killed (used for self-update).
-	InstallLocal(dir string, volume int, detach bool) error
+	InstallLocal(ctx context.Context, dir string, volume int, detach bool) error
 
 	// Uninstall removes an installed app.
-	Uninstall(appname string) error
+	Uninstall(ctx context.Context, appname string) error
 
 	// Start starts an installed app.
-	Start(appname string) error
+	Start(ctx context.Context, appname string) error
 
 	// Stop stops a running app.
-	Stop(appname string) error
+	Stop(ctx context.Context, appname string) error
 
 	// DefaultVolume returns the default installation volume index.
-	DefaultVolume() (int, error)
+	DefaultVolume(ctx context.Context) (int, error)
 
 	// SetDefaultVolume sets fnOS's default installation volume. This is the
 	// documented lever for install placement; install-local honors it even on
 	// builds where the undocumented -v flag is ignored.
-	SetDefaultVolume(volume int) error
+	SetDefaultVolume(ctx context.Context, volume int) error
 
 	// ListVolumes returns all available installation volumes.
-	ListVolumes() ([]VolumeInfo, error)
+	ListVolumes(ctx context.Context) ([]VolumeInfo, error)
 
 	// AppInstallVolume resolves the volume index an app is CURRENTLY installed
 	// on, derived from its on-disk layout independently of appcenter-cli output.
 	// found is false when the app is not installed or its volume cannot be
 	// determined. Updates MUST pin to this volume so an app is never relocated
 	// off its existing data.
-	AppInstallVolume(appname string) (int, bool, error)
+	AppInstallVolume(ctx context.Context, appname string) (int, bool, error)
 
 	// UpgradeCapability reports whether this fnOS build can update an
 	// installed app without destroying it. Some builds cannot — see
 	// upgrade.go.
-	UpgradeCapability() UpgradeCapability
+	UpgradeCapability(ctx context.Context) UpgradeCapability
 
 	// UpgradeFpk upgrades an ALREADY-INSTALLED app in place, preserving its
 	// data. This is deliberately separate from InstallFpk: InstallFpk goes
@@ -3,6 +3,7 @@
 package platform
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
@@ -30,9 +31,10 @@ func NewLinuxAppCenter() *LinuxAppCenter {
 // run executes appcenter-cli and treats an error envelope in the output as a
 // failure even when the process exits 0 — which it always does. See clierr.go
 // for the measured evidence and why exit status cannot be trusted.
-func (a *LinuxAppCenter) run(args ...string) (string, error) {
-	cmd := exec.Command(a.CLIPath, args...)
-	out, err := cmd.CombinedOutput()
+//
+// The command is killed when ctx ends or its subcommand's time limit passes.
+func (a *LinuxAppCenter) run(ctx context.Context, args ...string) (string, error) {
+	out, err := runCLI(ctx, cliTimeout(args[0]), a.CLIPath, args...)
 	if err != nil {
 		return "", fmt.Errorf("appcenter-cli %s: %w: %s", strings.Join(args, " "), err, string(out))
 	}
@@ -44,37 +46,37 @@ func (a *LinuxAppCenter) run(args ...string) (string, error) {
 	return text, nil
 }
 
-func (a *LinuxAppCenter) List() ([]InstalledApp, error) {
-	out, err := a.run("list")
+func (a *LinuxAppCenter) List(ctx context.Context) ([]InstalledApp, error) {
+	out, err := a.run(ctx, "list")
 	if err != nil {
 		return nil, err
 	}
 	return parseListTable(out)
 }
 
-func (a *LinuxAppCenter) Check(appname string) (bool, error) {
-	out, err := a.run("check", appname)
+func (a *LinuxAppCenter) Check(ctx context.Context, appname string) (bool, error) {
+	out, err := a.run(ctx, "check", appname)
 	if err != nil {
 		return false, err
 	}
 	return parseCheckOutput(out)
 }
 
-func (a *LinuxAppCenter) Status(appname string) (string, error) {
-	out, err := a.run("status", appname)
+func (a *LinuxAppCenter) Status(ctx context.Context, appname string) (string, error) {
+	out, err := a.run(ctx, "status", appname)
 	if err != nil {
 		return "", err
 	}
 	return parseStatusOutput(out)
 }
 
-func (a *LinuxAppCenter) InstallFpk(fpkPath string, volume int) error {
-	dir, err := a.extractFpk(fpkPath)
+func (a *LinuxAppCenter) InstallFpk(ctx context.Context, fpkPath string, volume int) error {
+	dir, err := a.extractFpk(ctx, fpkPath)
 	if err != nil {
 		return err
 	}
 	defer os.RemoveAll(dir)
-	return a.InstallLocal(dir, volume, false)
+	return a.InstallLocal(ctx, dir, volume, false)
 }
 
 // SelfUpdateLogPath is where a detached install-local writes its output.
@@ -96,10 +98,11 @@ const SelfUpdateLogPath = "/var/log/apps/fnos-apps-store-selfupdate.log"
 // When detach is set the child runs in its own session so it survives this
 // process being killed during the uninstall phase, and its output is captured
 // to SelfUpdateLogPath for post-restart diagnosis.
-func (a *LinuxAppCenter) InstallLocal(dir string, volume int, detach bool) error {
+func (a *LinuxAppCenter) InstallLocal(ctx context.Context, dir string, volume int, detach bool) error {
 	args := []string{"install-local", "--dir", dir, "-v", strconv.Itoa(volume)}
 	if detach {
 		cmd := exec.Command(a.CLIPath, args...)
+		cmd.Env = commandEnv(ctx)
 		cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
 		// O_NOFOLLOW refuses a symlink planted at the log path; 0600 keeps the
 		// CLI diagnostics root-only. Best-effort: losing the log must not block
@@ -112,16 +115,16 @@ func (a *LinuxAppCenter) InstallLocal(dir string, volume int, detach bool) error
 		}
 		return cmd.Start()
 	}
-	_, err := a.run(args...)
+	_, err := a.run(ctx, args...)
 	return err
 }
 
-func (a *LinuxAppCenter) extractFpk(fpkPath string) (string, error) {
+func (a *LinuxAppCenter) extractFpk(ctx context.Context, fpkPath string) (string, error) {
 	dir, err := os.MkdirTemp("", "fpk-install-*")
 	if err != nil {
 		return "", fmt.Errorf("create temp dir: %w", err)
 	}
-	cmd := exec.Command("tar", "xzf", fpkPath, "-C", dir)
+	cmd := exec.CommandContext(ctx, "tar", "xzf", fpkPath, "-C", dir)
 	if out, err := cmd.CombinedOutput(); err != nil {
 		os.RemoveAll(dir)
 		return "", fmt.Errorf("extract fpk: %w: %s", err, string(out))
@@ -130,23 +133,23 @@ func (a *LinuxAppCenter) extractFpk(fpkPath string) (string, error) {
 	return dir, nil
 }
 
-func (a *LinuxAppCenter) Uninstall(appname string) error {
-	_, err := a.run("uninstall", appname)
+func (a *LinuxAppCenter) Uninstall(ctx context.Context, appname string) error {
+	_, err := a.run(ctx, "uninstall", appname)
 	return err
 }
 
-func (a *LinuxAppCenter) Start(appname string) error {
-	_, err := a.run("start", appname)
+func (a *LinuxAppCenter) Start(ctx context.Context, appname string) error {
+	_, err := a.run(ctx, "start", appname)
 	return err
 }
 
-func (a *LinuxAppCenter) Stop(appname string) error {
-	_, err := a.run("stop", appname)
+func (a *LinuxAppCenter) Stop(ctx context.Context, appname string) error {
+	_, err := a.run(ctx, "stop", appname)
 	return err
 }
 
-func (a *LinuxAppCenter) DefaultVolume() (int, error) {
-	out, err := a.run("default-volume")
+func (a *LinuxAppCenter) DefaultVolume(ctx context.Context) (int, error) {
+	out, err := a.run(ctx, "default-volume")
 	if err != nil {
 		return 0, err
 	}
@@ -157,8 +160,8 @@ func (a *LinuxAppCenter) DefaultVolume() (int, error) {
 // It reports only whether the command was accepted; on some fnOS builds the
 // set is a silent no-op, so callers MUST read the value back and compare
 // rather than treating a nil error as proof the volume was pinned.
-func (a *LinuxAppCenter) SetDefaultVolume(volume int) error {
-	_, err := a.run("default-volume", strconv.Itoa(volume))
+func (a *LinuxAppCenter) SetDefaultVolume(ctx context.Context, volume int) error {
+	_, err := a.run(ctx, "default-volume", strconv.Itoa(volume))
 	return err
 }
 
@@ -177,7 +180,7 @@ func isMountPoint(path string) bool {
 	return pathStat.Dev != parentStat.Dev
 }
 
-func (a *LinuxAppCenter) ListVolumes() ([]VolumeInfo, error) {
+func (a *LinuxAppCenter) ListVolumes(_ context.Context) ([]VolumeInfo, error) {
 	matches, err := filepath.Glob("/vol*")
 	if err != nil {
 		return nil, err
@@ -235,8 +238,8 @@ func (a *LinuxAppCenter) ListVolumes() ([]VolumeInfo, error) {
 // re-resolved global default, which would relocate the app and orphan its data.
 // found is false when the app is not installed or its layout cannot be mapped
 // to a known volume.
-func (a *LinuxAppCenter) AppInstallVolume(appname string) (int, bool, error) {
-	volumes, err := a.ListVolumes()
+func (a *LinuxAppCenter) AppInstallVolume(ctx context.Context, appname string) (int, bool, error) {
+	volumes, err := a.ListVolumes(ctx)
 	if err != nil {
 		return 0, false, err
 	}
@@ -5,7 +5,6 @@ package platform
 import (
 	"context"
 	"fmt"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -25,17 +24,16 @@ func NewMockAppCenter(projectRoot string) *MockAppCenter {
 	}
 }
 
-func (m *MockAppCenter) run(args ...string) (string, error) {
-	cmd := exec.Command("bash", append([]string{m.ScriptPath}, args...)...)
-	out, err := cmd.CombinedOutput()
+func (m *MockAppCenter) run(ctx context.Context, args ...string) (string, error) {
+	out, err := runCLI(ctx, cliTimeout(args[0]), "bash", append([]string{m.ScriptPath}, args...)...)
 	if err != nil {
 		return "", fmt.Errorf("mock-appcenter-cli %s: %w: %s", strings.Join(args, " "), err, string(out))
 	}
 	return strings.TrimSpace(string(out)), nil
 }
 
-func (m *MockAppCenter) List() ([]InstalledApp, error) {
-	out, err := m.run("list")
+func (m *MockAppCenter) List(ctx context.Context) ([]InstalledApp, error) {
+	out, err := m.run(ctx, "list")
 	if err != nil {
 		return nil, err
 	}
@@ -59,57 +57,57 @@ func (m *MockAppCenter) List() ([]InstalledApp, error) {
 	return apps, nil
 }
 
-func (m *MockAppCenter) Check(appname string) (bool, error) {
-	out, err := m.run("check", appname)
+func (m *MockAppCenter) Check(ctx context.Context, appname string) (bool, error) {
+	out, err := m.run(ctx, "check", appname)
 	if err != nil {
 		return false, err
 	}
 	return out == "Installed", nil
 }
 
-func (m *MockAppCenter) Status(appname string) (string, error) {
-	return m.run("status", appname)
+func (m *MockAppCenter) Status(ctx context.Context, appname string) (string, error) {
+	return m.run(ctx, "status", appname)
 }
 
-func (m *MockAppCenter) InstallFpk(fpkPath string, volume int) error {
-	_, err := m.run("install-fpk", fpkPath, "-v", strconv.Itoa(volume))
+func (m *MockAppCenter) InstallFpk(ctx context.Context, fpkPath string, volume int) error {
+	_, err := m.run(ctx, "install-fpk", fpkPath, "-v", strconv.Itoa(volume))
 	return err
 }
 
-func (m *MockAppCenter) InstallLocal(dir string, volume int, detach bool) error {
-	_, err := m.run("install-local", "--dir", dir, "-v", strconv.Itoa(volume))
+func (m *MockAppCenter) InstallLocal(ctx context.Context, dir string, volume int, detach bool) error {
+	_, err := m.run(ctx, "install-local", "--dir", dir, "-v", strconv.Itoa(volume))
 	return err
 }
 
-func (m *MockAppCenter) Uninstall(appname string) error {
-	_, err := m.run("uninstall", appname)
+func (m *MockAppCenter) Uninstall(ctx context.Context, appname string) error {
+	_, err := m.run(ctx, "uninstall", appname)
 	return err
 }
 
-func (m *MockAppCenter) Start(appname string) error {
-	_, err := m.run("start", appname)
+func (m *MockAppCenter) Start(ctx context.Context, appname string) error {
+	_, err := m.run(ctx, "start", appname)
 	return err
 }
 
-func (m *MockAppCenter) Stop(appname string) error {
-	_, err := m.run("stop", appname)
+func (m *MockAppCenter) Stop(ctx context.Context, appname string) error {
+	_, err := m.run(ctx, "stop", appname)
 	return err
 }
 
-func (m *MockAppCenter) DefaultVolume() (int, error) {
-	out, err := m.run("default-volume")
+func (m *MockAppCenter) DefaultVolume(ctx context.Context) (int, error) {
+	out, err := m.run(ctx, "default-volume")
 	if err != nil {
 		return 0, err
 	}
 	return strconv.Atoi(out)
 }
 
-func (m *MockAppCenter) SetDefaultVolume(volume int) error {
-	_, err := m.run("default-volume", strconv.Itoa(volume))
+func (m *MockAppCenter) SetDefaultVolume(ctx context.Context, volume int) error {
+	_, err := m.run(ctx, "default-volume", strconv.Itoa(volume))
 	return err
 }
 
-func (m *MockAppCenter) ListVolumes() ([]VolumeInfo, error) {
+func (m *MockAppCenter) ListVolumes(context.Context) ([]VolumeInfo, error) {
 	return []VolumeInfo{
 		{Index: 1, Path: "/vol1", TotalBytes: 1000204886016, FreeBytes: 536870912000},
 		{Index: 2, Path: "/vol2", TotalBytes: 2000398934016, FreeBytes: 1503238553600},
@@ -118,8 +116,8 @@ func (m *MockAppCenter) ListVolumes() ([]VolumeInfo, error) {
 
 // UpgradeFpk in the mock just reuses the install path; the destructive
 // behavior it guards against only exists on real fnOS.
-func (m *MockAppCenter) UpgradeFpk(_ context.Context, fpkPath string, _ []WizardParam) error {
-	return m.InstallFpk(fpkPath, 1)
+func (m *MockAppCenter) UpgradeFpk(ctx context.Context, fpkPath string, _ []WizardParam) error {
+	return m.InstallFpk(ctx, fpkPath, 1)
 }
 
 // FetchWizard reports no wizard in the dev mock: the form definitions live in
@@ -128,10 +126,10 @@ func (m *MockAppCenter) FetchWizard(_ context.Context, _ string) (*AppWizard, er
 	return &AppWizard{HasWizard: false}, nil
 }
 
-func (m *MockAppCenter) InstallFpkWithWizard(_ context.Context, fpkPath string, volume int, _ []WizardParam) error {
-	return m.InstallFpk(fpkPath, volume)
+func (m *MockAppCenter) InstallFpkWithWizard(ctx context.Context, fpkPath string, volume int, _ []WizardParam) error {
+	return m.InstallFpk(ctx, fpkPath, volume)
 }
 
-func (m *MockAppCenter) AppInstallVolume(string) (int, bool, error) {
+func (m *MockAppCenter) AppInstallVolume(context.Context, string) (int, bool, error) {
 	return 1, true, nil
 }
@@ -0,0 +1,49 @@
+package platform
+
+import (
+	"context"
+	"os"
+	"slices"
+	"sync"
+)
+
+// cliEnv holds extra environment variables for appcenter-cli, whose install
+// hooks run docker compose for compose-based apps.
+var cliEnv struct {
+	sync.RWMutex
+	env []string
+}
+
+// SetCLIEnv replaces the extra "KEY=value" variables appcenter-cli runs
+// with, such as the proxy settings.
+func SetCLIEnv(env []string) {
+	cliEnv.Lock()
+	defer cliEnv.Unlock()
+	cliEnv.env = slices.Clone(env)
+}
+
+type cliEnvKey struct{}
+
+// WithCLIEnv returns a context whose appcenter-cli commands run with env in
+// place of SetCLIEnv's variables. An operation uses it to hand the app's
+// install hooks the settings it started with, however the process-wide ones
+// change meanwhile.
+func WithCLIEnv(ctx context.Context, env []string) context.Context {
+	return context.WithValue(ctx, cliEnvKey{}, slices.Clone(env))
+}
+
+// commandEnv is the environment for an appcenter-cli process: this
+// process's, plus ctx's WithCLIEnv variables or else SetCLIEnv's. Nil means
+// inherit unchanged.
+func commandEnv(ctx context.Context) []string {
+	extra, ok := ctx.Value(cliEnvKey{}).([]string)
+	if !ok {
+		cliEnv.RLock()
+		extra = cliEnv.env
+		cliEnv.RUnlock()
+	}
+	if len(extra) == 0 {
+		return nil
+	}
+	return append(os.Environ(), extra...)
+}
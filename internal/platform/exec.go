@@ -0,0 +1,130 @@
+package platform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrCLITimeout reports an appcenter-cli command killed for running past its
+// time limit. A wedged command would otherwise hold the CLI lock forever and
+// block every other operation.
+var ErrCLITimeout = errors.New("appcenter-cli timed out")
+
+// cliTimeouts are the time limits of appcenter-cli subcommands. Queries
+// answer within a second when the daemon is healthy; installs run hooks that
+// may pull images, so they get far longer.
+var cliTimeouts = map[string]time.Duration{
+	"list":           30 * time.Second,
+	"check":          30 * time.Second,
+	"status":         30 * time.Second,
+	"default-volume": 30 * time.Second,
+	"start":          3 * time.Minute,
+	"stop":           3 * time.Minute,
+	"uninstall":      10 * time.Minute,
+	"install-local":  30 * time.Minute,
+	"install-fpk":    30 * time.Minute,
+}
+
+// defaultCLITimeout limits subcommands without their own entry.
+const defaultCLITimeout = 5 * time.Minute
+
+// cliWaitDelay bounds the wait for a killed command's output pipes, which a
+// grandchild that escaped the process group could keep open.
+const cliWaitDelay = 5 * time.Second
+
+func cliTimeout(subcommand string) time.Duration {
+	if d, ok := cliTimeouts[subcommand]; ok {
+		return d
+	}
+	return defaultCLITimeout
+}
+
+// HungCommand is an appcenter-cli command that has run for longer than it
+// should: over half its time limit.
+type HungCommand struct {
+	Command         string    `json:"command"`
+	StartedAt       time.Time `json:"started_at"`
+	DurationSeconds int64     `json:"duration_seconds"`
+	TimeoutSeconds  int64     `json:"timeout_seconds"`
+}
+
+type runningCommand struct {
+	line      string
+	startedAt time.Time
+	timeout   time.Duration
+}
+
+var running struct {
+	sync.Mutex
+	next int
+	cmds map[int]runningCommand
+}
+
+func trackCommand(cmd runningCommand) (untrack func()) {
+	running.Lock()
+	defer running.Unlock()
+	if running.cmds == nil {
+		running.cmds = make(map[int]runningCommand)
+	}
+	id := running.next
+	running.next++
+	running.cmds[id] = cmd
+	return func() {
+		running.Lock()
+		defer running.Unlock()
+		delete(running.cmds, id)
+	}
+}
+
+// HungCommands returns the commands running past half their time limit,
+// longest first.
+func HungCommands() []HungCommand {
+	running.Lock()
+	defer running.Unlock()
+	now := time.Now()
+	var hung []HungCommand
+	for _, c := range running.cmds {
+		if d := now.Sub(c.startedAt); d > c.timeout/2 {
+			hung = append(hung, HungCommand{
+				Command:         c.line,
+				StartedAt:       c.startedAt,
+				DurationSeconds: int64(d.Seconds()),
+				TimeoutSeconds:  int64(c.timeout.Seconds()),
+			})
+		}
+	}
+	slices.SortFunc(hung, func(a, b HungCommand) int { return a.StartedAt.Compare(b.StartedAt) })
+	return hung
+}
+
+// runCLI runs name with args and returns its combined output. The command
+// ends with ctx or after timeout, whichever comes first, and then its whole
+// process group is killed: appcenter-cli runs hooks that start children of
+// their own.
+func runCLI(ctx context.Context, timeout time.Duration, name string, args ...string) ([]byte, error) {
+	line := strings.Join(append([]string{name}, args...), " ")
+	ctx, cancel := context.WithTimeoutCause(ctx, timeout, ErrCLITimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = commandEnv(ctx)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = cliWaitDelay
+
+	defer trackCommand(runningCommand{line: line, startedAt: time.Now(), timeout: timeout})()
+	out, err := cmd.CombinedOutput()
+	if err != nil && errors.Is(context.Cause(ctx), ErrCLITimeout) {
+		return out, fmt.Errorf("%w after %s, killed", ErrCLITimeout, timeout)
+	}
+	return out, err
+}
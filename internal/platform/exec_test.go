@@ -0,0 +1,73 @@
+package platform
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRunCLIKillsProcessGroup runs a command whose child keeps the output
+// pipe open. Killing only the shell would leave the read waiting for the
+// full WaitDelay; killing the group ends it at the deadline.
+func TestRunCLIKillsProcessGroup(t *testing.T) {
+	start := time.Now()
+	_, err := runCLI(context.Background(), 200*time.Millisecond, "sh", "-c", "sleep 30 & sleep 30")
+	if !errors.Is(err, ErrCLITimeout) {
+		t.Fatalf("err = %v, want ErrCLITimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > cliWaitDelay {
+		t.Errorf("runCLI returned after %s, the process group was not killed", elapsed)
+	}
+}
+
+func TestRunCLICancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := runCLI(ctx, time.Minute, "sleep", "30")
+	if err == nil || errors.Is(err, ErrCLITimeout) {
+		t.Errorf("err = %v, want a cancellation that is not a timeout", err)
+	}
+}
+
+func TestHungCommands(t *testing.T) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = runCLI(context.Background(), 400*time.Millisecond, "sleep", "30")
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	if hung := HungCommands(); len(hung) != 0 {
+		t.Errorf("HungCommands = %+v before half the time limit", hung)
+	}
+	time.Sleep(250 * time.Millisecond)
+	hung := HungCommands()
+	if len(hung) != 1 || !strings.HasPrefix(hung[0].Command, "sleep 30") {
+		t.Errorf("HungCommands = %+v, want the sleep", hung)
+	}
+
+	<-done
+	if hung := HungCommands(); len(hung) != 0 {
+		t.Errorf("HungCommands = %+v after the command was killed", hung)
+	}
+}
+
+func TestCommandEnv(t *testing.T) {
+	SetCLIEnv([]string{"HTTP_PROXY=http://global:8080"})
+	defer SetCLIEnv(nil)
+
+	if env := commandEnv(context.Background()); !slices.Contains(env, "HTTP_PROXY=http://global:8080") {
+		t.Error("process-wide variables missing without an operation's own")
+	}
+	ctx := WithCLIEnv(context.Background(), []string{"DOCKER_MIRROR=m.daocloud.io/"})
+	env := commandEnv(ctx)
+	if !slices.Contains(env, "DOCKER_MIRROR=m.daocloud.io/") {
+		t.Error("operation's variables missing")
+	}
+	if slices.Contains(env, "HTTP_PROXY=http://global:8080") {
+		t.Error("process-wide variables used alongside the operation's own")
+	}
+}
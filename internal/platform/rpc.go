@@ -313,15 +313,20 @@ func (a *LinuxAppCenter) waitTask(ctx context.Context, taskID, what string) erro
 // DaemonUpgradeAvailable reports whether the daemon's upgrade channel is
 // usable, so the store can prefer it and fall back to refusing rather than
 // assuming either outcome from the fnOS version string alone.
-func (a *LinuxAppCenter) DaemonUpgradeAvailable() bool {
-	if _, err := net.DialTimeout("unix", daemonSocket, 2*time.Second); err != nil {
+func (a *LinuxAppCenter) DaemonUpgradeAvailable(ctx context.Context) bool {
+	dialCtx, cancelDial := context.WithTimeout(ctx, 2*time.Second)
+	defer cancelDial()
+	var d net.Dialer
+	conn, err := d.DialContext(dialCtx, "unix", daemonSocket)
+	if err != nil {
 		return false
 	}
+	conn.Close()
 	// An empty body must be REJECTED by a route that exists (validation error),
 	// and produce a transport/404-shaped failure when it does not.
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
-	err := daemonCall(ctx, routeUpdateInfo, map[string]any{}, nil)
+	err = daemonCall(ctx, routeUpdateInfo, map[string]any{}, nil)
 	var de *DaemonError
 	if errors.As(err, &de) {
 		return de.Code == daemonCodeValidation
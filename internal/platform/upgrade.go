@@ -1,6 +1,10 @@
 package platform
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"maps"
+	"sync"
+)
 
 // UpgradeCapability reports whether in-store updates can run safely on this
 // fnOS build.
@@ -42,6 +46,34 @@ var upgradeUnsafeVersions = map[string]string{
 		"系统会先卸载旧版再安装新版，而安装步骤必定失败，导致应用与其数据一并丢失。",
 }
 
+// remoteUnsafeVersions are fnOS builds the catalog's signed safety list
+// declares destructive (see source.SafetyList). Unlike the built-in list,
+// which only explains why the fallback path is refused, these refuse updates
+// outright: a build is only published there once updates on it were seen to
+// destroy apps through whatever path this store takes.
+var remoteUnsafeVersions struct {
+	sync.RWMutex
+	m map[string]string
+}
+
+// SetRemoteUnsafeVersions replaces the deny-list learned from the catalog.
+func SetRemoteUnsafeVersions(m map[string]string) {
+	remoteUnsafeVersions.Lock()
+	defer remoteUnsafeVersions.Unlock()
+	remoteUnsafeVersions.m = maps.Clone(m)
+}
+
+// remoteUnsafeReason returns why the catalog declares version unsafe.
+func remoteUnsafeReason(version string) (string, bool) {
+	if version == "" {
+		return "", false
+	}
+	remoteUnsafeVersions.RLock()
+	defer remoteUnsafeVersions.RUnlock()
+	reason, ok := remoteUnsafeVersions.m[version]
+	return reason, ok
+}
+
 // WizardParam is one answer to an app's install wizard.
 //
 // The daemon requires exactly {"key":..,"value":..}; paramKey/paramValue and
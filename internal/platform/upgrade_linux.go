@@ -3,6 +3,7 @@
 package platform
 
 import (
+	"context"
 	"os"
 	"strings"
 )
@@ -10,6 +11,15 @@ import (
 // fnosVersionPath is where fnOS records its build, e.g. "1.2.0203".
 const fnosVersionPath = "/usr/trim/etc/version"
 
+// FnOSVersion returns this system's fnOS build, or "" when it cannot be read.
+func FnOSVersion() string {
+	b, err := os.ReadFile(fnosVersionPath)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
 // UpgradeCapability reports whether this system can update an installed app
 // without destroying it.
 //
@@ -27,20 +37,20 @@ const fnosVersionPath = "/usr/trim/etc/version"
 // So the question is not "is this fnOS version safe" but "can we reach the
 // daemon's upgrade channel". If we can, updates are safe regardless of the
 // version string. If we cannot, refuse rather than fall back to the destroyer
-// (conversun/fnos-apps#189).
-func (a *LinuxAppCenter) UpgradeCapability() UpgradeCapability {
-	version := ""
-	if b, err := os.ReadFile(fnosVersionPath); err == nil {
-		version = strings.TrimSpace(string(b))
-	}
-
-	if a.DaemonUpgradeAvailable() {
-		return UpgradeCapability{Allowed: true, PlatformVersion: version}
-	}
+// (conversun/fnos-apps#189). The one exception is a build the catalog's signed
+// safety list names: that is refused whatever the channel.
+func (a *LinuxAppCenter) UpgradeCapability(ctx context.Context) UpgradeCapability {
+	version := FnOSVersion()
 
-	reason := "无法连接飞牛应用中心的升级服务，为避免退回到会删除应用数据的旧升级方式，已中止本次更新。"
-	if known, unsafe := upgradeUnsafeVersions[version]; unsafe {
-		reason = known
+	reason, remoteUnsafe := remoteUnsafeReason(version)
+	if !remoteUnsafe {
+		if a.DaemonUpgradeAvailable(ctx) {
+			return UpgradeCapability{Allowed: true, PlatformVersion: version}
+		}
+		reason = "无法连接飞牛应用中心的升级服务，为避免退回到会删除应用数据的旧升级方式，已中止本次更新。"
+		if known, unsafe := upgradeUnsafeVersions[version]; unsafe {
+			reason = known
+		}
 	}
 	return UpgradeCapability{
 		Allowed:         false,
@@ -2,7 +2,15 @@
 
 package platform
 
+import "context"
+
 // UpgradeCapability always permits updates in the macOS dev mock.
-func (m *MockAppCenter) UpgradeCapability() UpgradeCapability {
+func (m *MockAppCenter) UpgradeCapability(context.Context) UpgradeCapability {
 	return UpgradeCapability{Allowed: true, PlatformVersion: "mock"}
 }
+
+// FnOSVersion reports no fnOS build in the macOS dev mock, so catalog version
+// ranges are not enforced there.
+func FnOSVersion() string {
+	return ""
+}
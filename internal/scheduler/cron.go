@@ -1,119 +1,241 @@
 package scheduler
 
 import (
-	"context"
-	"log"
-	"sync"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
-type CheckFunc func(ctx context.Context) error
-
-type Scheduler struct {
-	interval    time.Duration
-	checkFn     CheckFunc
-	lastCheckFn func() time.Time
-	stopCh      chan struct{}
-
-	mu      sync.Mutex
-	running bool
-	ticker  *time.Ticker
+// Schedule yields the next time a job is due.
+type Schedule interface {
+	// Next returns the first activation strictly after t, or the zero time
+	// if there is none.
+	Next(t time.Time) time.Time
 }
 
-func New(interval time.Duration, checkFn CheckFunc, lastCheckFn func() time.Time) *Scheduler {
-	return &Scheduler{
-		interval:    interval,
-		checkFn:     checkFn,
-		lastCheckFn: lastCheckFn,
-		stopCh:      make(chan struct{}),
+// Parse reads a job schedule:
+//
+//   - a standard five-field cron expression, "minute hour day-of-month month
+//     day-of-week", with *, lists, ranges, /steps and month/weekday names;
+//   - a macro: @yearly, @monthly, @weekly, @daily (@midnight), @hourly;
+//   - @every <duration>, e.g. "@every 6h".
+//
+// A leading "CRON_TZ=<zone>" or "TZ=<zone>" overrides loc for that
+// expression. As in cron, a job whose day-of-month and day-of-week are both
+// restricted runs when EITHER matches.
+func Parse(spec string, loc *time.Location) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if loc == nil {
+		loc = time.Local
+	}
+	if strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=") {
+		tz, rest, _ := strings.Cut(spec, " ")
+		_, name, _ := strings.Cut(tz, "=")
+		zone, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", name, err)
+		}
+		loc, spec = zone, strings.TrimSpace(rest)
+	}
+	if spec == "" {
+		return nil, fmt.Errorf("empty schedule")
 	}
-}
 
-func (s *Scheduler) Start(ctx context.Context) {
-	s.mu.Lock()
-	if s.running {
-		s.mu.Unlock()
-		return
-	}
-	s.running = true
-	// Recreate stopCh so the scheduler can be restarted after Stop().
-	// Without this, a Stop()+Start() cycle would immediately exit because
-	// the closed channel always selects.
-	select {
-	case <-s.stopCh:
-		s.stopCh = make(chan struct{})
-	default:
-	}
-	s.mu.Unlock()
-
-	log.Printf("scheduler: checking every %s", s.interval)
-
-	if s.lastCheckFn != nil {
-		lastCheck := s.lastCheckFn()
-		if !lastCheck.IsZero() && time.Since(lastCheck) > s.interval {
-			log.Println("scheduler: stale check detected, triggering immediate refresh")
-			s.runCheck(ctx)
+	if strings.HasPrefix(spec, "@every") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid @every duration: %w", err)
+		}
+		if d < time.Minute {
+			return nil, fmt.Errorf("@every interval must be at least 1m, got %s", d)
 		}
+		return everySchedule{d}, nil
+	}
+
+	switch spec {
+	case "@yearly", "@annually":
+		spec = "0 0 1 1 *"
+	case "@monthly":
+		spec = "0 0 1 * *"
+	case "@weekly":
+		spec = "0 0 * * 0"
+	case "@daily", "@midnight":
+		spec = "0 0 * * *"
+	case "@hourly":
+		spec = "0 * * * *"
 	}
 
-	s.mu.Lock()
-	s.ticker = time.NewTicker(s.interval)
-	s.mu.Unlock()
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields, got %d", spec, len(fields))
+	}
+	s := &cronSchedule{loc: loc}
+	var err error
+	if s.minute, _, err = parseField(fields[0], minuteBounds); err != nil {
+		return nil, fmt.Errorf("minute: %w", err)
+	}
+	if s.hour, _, err = parseField(fields[1], hourBounds); err != nil {
+		return nil, fmt.Errorf("hour: %w", err)
+	}
+	if s.dom, s.domStar, err = parseField(fields[2], domBounds); err != nil {
+		return nil, fmt.Errorf("day of month: %w", err)
+	}
+	if s.month, _, err = parseField(fields[3], monthBounds); err != nil {
+		return nil, fmt.Errorf("month: %w", err)
+	}
+	if s.dow, s.dowStar, err = parseField(fields[4], dowBounds); err != nil {
+		return nil, fmt.Errorf("day of week: %w", err)
+	}
+	// 7 is Sunday too.
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+type everySchedule struct {
+	interval time.Duration
+}
+
+func (e everySchedule) Next(t time.Time) time.Time {
+	return t.Add(e.interval)
+}
+
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+	loc                           *time.Location
+}
 
-	defer func() {
-		s.mu.Lock()
-		if s.ticker != nil {
-			s.ticker.Stop()
+// maxSearchYears bounds Next for expressions that can never match
+// (e.g. "0 0 30 2 *").
+const maxSearchYears = 5
+
+func (c *cronSchedule) Next(t time.Time) time.Time {
+	t = t.In(c.loc)
+	// Start at the next whole minute.
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, c.loc).Add(time.Minute)
+	limit := t.AddDate(maxSearchYears, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, c.loc)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, c.loc)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, c.loc)
+			if !next.After(t) {
+				// A DST fall-back repeats this hour; skip past it.
+				next = t.Add(time.Hour).Truncate(time.Hour)
+			}
+			t = next
+			continue
 		}
-		s.running = false
-		s.mu.Unlock()
-	}()
-
-	for {
-		select {
-		case <-s.ticker.C:
-			s.runCheck(ctx)
-		case <-ctx.Done():
-			log.Println("scheduler: stopped")
-			return
-		case <-s.stopCh:
-			log.Println("scheduler: stopped")
-			return
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
 		}
+		return t
 	}
+	return time.Time{}
 }
 
-func (s *Scheduler) Stop() {
-	select {
-	case <-s.stopCh:
-	default:
-		close(s.stopCh)
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	domOK := c.dom&(1<<uint(t.Day())) != 0
+	dowOK := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return domOK && dowOK
 	}
+	return domOK || dowOK
 }
 
-func (s *Scheduler) SetInterval(d time.Duration) {
-	if d < 1*time.Hour {
-		d = 1 * time.Hour
-	}
+type bounds struct {
+	min, max int
+	names    map[string]int
+}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+var (
+	minuteBounds = bounds{min: 0, max: 59}
+	hourBounds   = bounds{min: 0, max: 23}
+	domBounds    = bounds{min: 1, max: 31}
+	monthBounds  = bounds{min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowBounds = bounds{min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// parseField returns the set of values a field allows as a bitmask, and
+// whether it was an unrestricted "*" (or "?").
+func parseField(field string, b bounds) (uint64, bool, error) {
+	var bits uint64
+	star := field == "*" || field == "?"
+	for part := range strings.SplitSeq(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, false, fmt.Errorf("invalid step %q", stepPart)
+			}
+			step = n
+		}
 
-	s.interval = d
-	if s.ticker != nil {
-		s.ticker.Reset(d)
+		lo, hi := b.min, b.max
+		switch {
+		case rangePart == "*" || rangePart == "?":
+			if b.max == 7 {
+				hi = 6 // "*" in day-of-week must not double-count Sunday
+			}
+		case strings.Contains(rangePart, "-"):
+			from, to, _ := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = b.value(from); err != nil {
+				return 0, false, err
+			}
+			if hi, err = b.value(to); err != nil {
+				return 0, false, err
+			}
+			if lo > hi {
+				return 0, false, fmt.Errorf("invalid range %q", rangePart)
+			}
+		default:
+			v, err := b.value(rangePart)
+			if err != nil {
+				return 0, false, err
+			}
+			lo = v
+			hi = v
+			if hasStep {
+				// "5/15" means "from 5 every 15".
+				hi = b.max
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
 	}
-	log.Printf("scheduler: interval updated to %s", d)
+	return bits, star, nil
 }
 
-func (s *Scheduler) runCheck(ctx context.Context) {
-	if s.checkFn == nil {
-		return
+func (b bounds) value(s string) (int, error) {
+	if v, ok := b.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
 	}
-	log.Println("scheduler: version check triggered")
-	if err := s.checkFn(ctx); err != nil {
-		log.Printf("scheduler: check failed: %v", err)
-	} else {
-		log.Println("scheduler: check completed")
+	if v < b.min || v > b.max {
+		return 0, fmt.Errorf("value %d out of range %d-%d", v, b.min, b.max)
 	}
+	return v, nil
 }
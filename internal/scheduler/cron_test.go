@@ -0,0 +1,93 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("timezone %s not available: %v", name, err)
+	}
+	return loc
+}
+
+func TestParseNext(t *testing.T) {
+	shanghai := mustLoad(t, "Asia/Shanghai")
+	from := time.Date(2026, 3, 14, 10, 7, 30, 0, shanghai) // a Saturday
+
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2026, 3, 14, 10, 15, 0, 0, shanghai)},
+		{"30 3 * * *", time.Date(2026, 3, 15, 3, 30, 0, 0, shanghai)},
+		{"0 9-17/4 * * mon-fri", time.Date(2026, 3, 16, 9, 0, 0, 0, shanghai)},
+		{"0 0 1 * *", time.Date(2026, 4, 1, 0, 0, 0, 0, shanghai)},
+		{"@daily", time.Date(2026, 3, 15, 0, 0, 0, 0, shanghai)},
+		{"@hourly", time.Date(2026, 3, 14, 11, 0, 0, 0, shanghai)},
+		{"0 12 * * 7", time.Date(2026, 3, 15, 12, 0, 0, 0, shanghai)},
+		{"0 12 * jun *", time.Date(2026, 6, 1, 12, 0, 0, 0, shanghai)},
+		// Day-of-month OR day-of-week when both are restricted.
+		{"0 0 20 * sun", time.Date(2026, 3, 15, 0, 0, 0, 0, shanghai)},
+		{"@every 6h", from.Add(6 * time.Hour)},
+		{"CRON_TZ=UTC 0 0 * * *", time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		sched, err := Parse(tt.spec, shanghai)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.spec, err)
+			continue
+		}
+		if got := sched.Next(from); !got.Equal(tt.want) {
+			t.Errorf("Parse(%q).Next = %s, want %s", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"@every 10s",
+		"@every soon",
+		"TZ=Mars/Olympus 0 0 * * *",
+	} {
+		if _, err := Parse(spec, time.UTC); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestNextNeverMatches(t *testing.T) {
+	sched, err := Parse("0 0 30 2 *", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sched.Next(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)); !got.IsZero() {
+		t.Errorf("Next = %s, want zero time", got)
+	}
+}
+
+func TestNextAcrossDSTFallBack(t *testing.T) {
+	ny := mustLoad(t, "America/New_York")
+	sched, err := Parse("30 * * * *", ny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2026-11-01 01:30 EDT is followed by 01:30 EST an hour later.
+	first := time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC)
+	next := sched.Next(first)
+	if next.Sub(first) != time.Hour {
+		t.Errorf("Next after %s = %s, want one hour later", first.In(ny), next.In(ny))
+	}
+}
@@ -0,0 +1,402 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// JobFunc is the work a job does on each run.
+type JobFunc func(ctx context.Context) error
+
+// Job is one named, scheduled task.
+type Job struct {
+	Name string
+	// Spec is the schedule (see Parse). Empty disables the job.
+	Spec string
+	// Jitter delays each run by a random amount up to this much, so a fleet
+	// of boxes on the same schedule does not hit GitHub in the same second.
+	Jitter time.Duration
+	Run    JobFunc
+	// LastRun, when set, reports when the job last ran before this process
+	// started. A job whose previous activation was missed while the store was
+	// down runs right away at Start.
+	LastRun func() time.Time
+}
+
+// Run results.
+const (
+	ResultOK    = "ok"
+	ResultError = "error"
+	// ResultSkipped: the run was due while the previous one was still going,
+	// or the job returned ErrSkipped.
+	ResultSkipped = "skipped"
+)
+
+// ErrSkipped is returned (possibly wrapped) by a job that decided not to do
+// its work this time, e.g. because an install is in progress.
+var ErrSkipped = errors.New("skipped")
+
+// JobStatus is a job's schedule and last outcome, for GET /api/scheduler.
+type JobStatus struct {
+	Name         string    `json:"name"`
+	Spec         string    `json:"schedule"`
+	Enabled      bool      `json:"enabled"`
+	Running      bool      `json:"running"`
+	NextRun      time.Time `json:"next_run,omitzero"`
+	LastRun      time.Time `json:"last_run,omitzero"`
+	LastDuration string    `json:"last_duration,omitempty"`
+	LastResult   string    `json:"last_result,omitempty"`
+	LastError    string    `json:"last_error,omitempty"`
+}
+
+type jobState struct {
+	job      Job
+	schedule Schedule // nil when disabled
+	next     time.Time
+
+	running      bool
+	lastRun      time.Time
+	lastDuration time.Duration
+	lastResult   string
+	lastError    string
+}
+
+// Scheduler runs named jobs on cron schedules.
+type Scheduler struct {
+	mu      sync.Mutex
+	loc     *time.Location
+	jobs    map[string]*jobState
+	order   []string
+	running bool
+	stopCh  chan struct{}
+	wake    chan struct{}
+	wg      sync.WaitGroup
+
+	now    func() time.Time
+	jitter func(max time.Duration) time.Duration
+}
+
+// New returns a scheduler that evaluates cron expressions in loc (time.Local
+// when nil).
+func New(loc *time.Location) *Scheduler {
+	if loc == nil {
+		loc = time.Local
+	}
+	return &Scheduler{
+		loc:    loc,
+		jobs:   make(map[string]*jobState),
+		stopCh: make(chan struct{}),
+		wake:   make(chan struct{}, 1),
+		now:    time.Now,
+		jitter: func(max time.Duration) time.Duration {
+			if max <= 0 {
+				return 0
+			}
+			return rand.N(max)
+		},
+	}
+}
+
+// Add registers a job. Adding a name twice replaces the earlier job.
+func (s *Scheduler) Add(job Job) error {
+	var sched Schedule
+	if job.Spec != "" {
+		var err error
+		if sched, err = Parse(job.Spec, s.location()); err != nil {
+			return fmt.Errorf("job %s: %w", job.Name, err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, exists := s.jobs[job.Name]
+	if !exists {
+		st = &jobState{}
+		s.jobs[job.Name] = st
+		s.order = append(s.order, job.Name)
+	}
+	st.job = job
+	st.schedule = sched
+	st.next = s.nextLocked(st, s.now())
+	s.notify()
+	return nil
+}
+
+// SetSpec changes a job's schedule; an empty spec disables it.
+func (s *Scheduler) SetSpec(name, spec string) error {
+	var sched Schedule
+	if spec != "" {
+		var err error
+		if sched, err = Parse(spec, s.location()); err != nil {
+			return fmt.Errorf("job %s: %w", name, err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.jobs[name]
+	if !ok {
+		return fmt.Errorf("unknown job %q", name)
+	}
+	if st.job.Spec == spec {
+		return nil
+	}
+	st.job.Spec = spec
+	st.schedule = sched
+	st.next = s.nextLocked(st, s.now())
+	log.Printf("scheduler: %s schedule set to %q", name, spec)
+	s.notify()
+	return nil
+}
+
+// SetLocation changes the timezone cron expressions are evaluated in.
+func (s *Scheduler) SetLocation(loc *time.Location) error {
+	if loc == nil {
+		loc = time.Local
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.loc.String() == loc.String() {
+		return nil
+	}
+
+	scheds := make(map[string]Schedule, len(s.jobs))
+	for name, st := range s.jobs {
+		if st.job.Spec == "" {
+			continue
+		}
+		sched, err := Parse(st.job.Spec, loc)
+		if err != nil {
+			return fmt.Errorf("job %s: %w", name, err)
+		}
+		scheds[name] = sched
+	}
+	s.loc = loc
+	now := s.now()
+	for name, sched := range scheds {
+		st := s.jobs[name]
+		st.schedule = sched
+		st.next = s.nextLocked(st, now)
+	}
+	s.notify()
+	return nil
+}
+
+// Location returns the timezone cron expressions are evaluated in.
+func (s *Scheduler) Location() *time.Location {
+	return s.location()
+}
+
+func (s *Scheduler) location() *time.Location {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loc
+}
+
+// Status reports every job in registration order.
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]JobStatus, 0, len(s.order))
+	for _, name := range s.order {
+		st := s.jobs[name]
+		js := JobStatus{
+			Name:       name,
+			Spec:       st.job.Spec,
+			Enabled:    st.schedule != nil,
+			Running:    st.running,
+			NextRun:    st.next,
+			LastRun:    st.lastRun,
+			LastResult: st.lastResult,
+			LastError:  st.lastError,
+		}
+		if st.lastDuration > 0 {
+			js.LastDuration = st.lastDuration.Round(time.Millisecond).String()
+		}
+		out = append(out, js)
+	}
+	return out
+}
+
+// Start runs jobs until ctx ends or Stop is called. Jobs missed while the
+// store was down (see Job.LastRun) run immediately.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	// Recreate stopCh so the scheduler can be restarted after Stop().
+	// Without this, a Stop()+Start() cycle would immediately exit because
+	// the closed channel always selects.
+	select {
+	case <-s.stopCh:
+		s.stopCh = make(chan struct{})
+	default:
+	}
+	stopCh := s.stopCh
+
+	now := s.now()
+	var missed []*jobState
+	for _, name := range s.order {
+		st := s.jobs[name]
+		if st.schedule == nil || st.job.LastRun == nil {
+			continue
+		}
+		last := st.job.LastRun()
+		if last.IsZero() {
+			continue
+		}
+		if due := st.schedule.Next(last); !due.IsZero() && due.Before(now) {
+			log.Printf("scheduler: %s missed its run at %s, running now", name, due.Format(time.RFC3339))
+			missed = append(missed, st)
+		}
+	}
+	for _, st := range missed {
+		s.launchLocked(ctx, st)
+	}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.running = false
+		s.mu.Unlock()
+		s.wg.Wait()
+	}()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		s.mu.Lock()
+		now := s.now()
+		var earliest time.Time
+		for _, name := range s.order {
+			st := s.jobs[name]
+			if st.schedule == nil || st.next.IsZero() {
+				continue
+			}
+			if !st.next.After(now) {
+				s.launchLocked(ctx, st)
+				st.next = s.nextLocked(st, now)
+			}
+			if !st.next.IsZero() && (earliest.IsZero() || st.next.Before(earliest)) {
+				earliest = st.next
+			}
+		}
+		s.mu.Unlock()
+
+		wait := time.Hour
+		if !earliest.IsZero() {
+			wait = earliest.Sub(now)
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-timer.C:
+		case <-s.wake:
+		case <-ctx.Done():
+			log.Println("scheduler: stopped")
+			return
+		case <-stopCh:
+			log.Println("scheduler: stopped")
+			return
+		}
+	}
+}
+
+// Stop ends Start, which returns once the jobs it started have returned.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.stopCh:
+	default:
+		close(s.stopCh)
+	}
+}
+
+// RunNow starts a job immediately, outside its schedule.
+func (s *Scheduler) RunNow(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.jobs[name]
+	if !ok {
+		return fmt.Errorf("unknown job %q", name)
+	}
+	s.launchLocked(ctx, st)
+	return nil
+}
+
+func (s *Scheduler) nextLocked(st *jobState, now time.Time) time.Time {
+	if st.schedule == nil {
+		return time.Time{}
+	}
+	next := st.schedule.Next(now)
+	if next.IsZero() {
+		return next
+	}
+	return next.Add(s.jitter(st.job.Jitter))
+}
+
+// launchLocked runs st in its own goroutine unless it is still running from
+// last time, in which case the run is recorded as skipped.
+func (s *Scheduler) launchLocked(ctx context.Context, st *jobState) {
+	if st.job.Run == nil {
+		return
+	}
+	if st.running {
+		st.lastResult = ResultSkipped
+		log.Printf("scheduler: %s still running, skipping this run", st.job.Name)
+		return
+	}
+	st.running = true
+	started := s.now()
+	st.lastRun = started
+
+	run, name := st.job.Run, st.job.Name
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		err := run(ctx)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		st.running = false
+		st.lastDuration = s.now().Sub(started)
+		switch {
+		case errors.Is(err, ErrSkipped):
+			st.lastResult = ResultSkipped
+			st.lastError = err.Error()
+			log.Printf("scheduler: %s: %v", name, err)
+			return
+		case err != nil:
+			st.lastResult = ResultError
+			st.lastError = err.Error()
+			log.Printf("scheduler: %s failed: %v", name, err)
+			return
+		}
+		st.lastResult = ResultOK
+		st.lastError = ""
+	}()
+}
+
+// notify wakes Start to recompute its timer.
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
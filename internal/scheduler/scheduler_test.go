@@ -0,0 +1,132 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func statusOf(s *Scheduler, name string) JobStatus {
+	for _, st := range s.Status() {
+		if st.Name == name {
+			return st
+		}
+	}
+	return JobStatus{}
+}
+
+func TestStartRunsMissedJob(t *testing.T) {
+	s := New(time.UTC)
+	ran := make(chan struct{}, 1)
+	err := s.Add(Job{
+		Name:    "refresh",
+		Spec:    "@every 1h",
+		Run:     func(context.Context) error { ran <- struct{}{}; return nil },
+		LastRun: func() time.Time { return time.Now().Add(-2 * time.Hour) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { s.Start(ctx); close(done) }()
+
+	select {
+	case <-ran:
+	case <-time.After(2 * time.Second):
+		t.Fatal("missed job did not run at start")
+	}
+	waitFor(t, func() bool { return statusOf(s, "refresh").LastResult == ResultOK })
+
+	st := statusOf(s, "refresh")
+	if !st.Enabled || st.NextRun.IsZero() || st.LastRun.IsZero() {
+		t.Errorf("status = %+v", st)
+	}
+	cancel()
+	<-done
+}
+
+func TestRunNowRecordsResult(t *testing.T) {
+	s := New(time.UTC)
+	results := map[string]error{
+		"fails":   errors.New("boom"),
+		"skips":   fmt.Errorf("%w: busy", ErrSkipped),
+		"succeed": nil,
+	}
+	for name, err := range results {
+		if err := s.Add(Job{Name: name, Spec: "@daily", Run: func(context.Context) error { return err }}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name := range results {
+		if err := s.RunNow(context.Background(), name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, func() bool {
+		for name := range results {
+			if statusOf(s, name).LastResult == "" || statusOf(s, name).Running {
+				return false
+			}
+		}
+		return true
+	})
+
+	if st := statusOf(s, "fails"); st.LastResult != ResultError || st.LastError != "boom" {
+		t.Errorf("fails: %+v", st)
+	}
+	if st := statusOf(s, "skips"); st.LastResult != ResultSkipped {
+		t.Errorf("skips: %+v", st)
+	}
+	if st := statusOf(s, "succeed"); st.LastResult != ResultOK || st.LastError != "" {
+		t.Errorf("succeed: %+v", st)
+	}
+	if err := s.RunNow(context.Background(), "missing"); err == nil {
+		t.Error("RunNow of unknown job succeeded")
+	}
+}
+
+func TestSetSpecDisablesAndValidates(t *testing.T) {
+	s := New(time.UTC)
+	if err := s.Add(Job{Name: "cleanup", Spec: "30 3 * * *"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetSpec("cleanup", "not a schedule"); err == nil {
+		t.Error("SetSpec accepted an invalid schedule")
+	}
+	if err := s.SetSpec("cleanup", ""); err != nil {
+		t.Fatal(err)
+	}
+	if st := statusOf(s, "cleanup"); st.Enabled || !st.NextRun.IsZero() {
+		t.Errorf("disabled job status = %+v", st)
+	}
+}
+
+func TestJitterDelaysNextRun(t *testing.T) {
+	s := New(time.UTC)
+	now := time.Date(2026, 3, 14, 10, 7, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	s.jitter = func(max time.Duration) time.Duration { return max / 2 }
+
+	if err := s.Add(Job{Name: "refresh", Spec: "0 * * * *", Jitter: 10 * time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2026, 3, 14, 11, 5, 0, 0, time.UTC)
+	if got := statusOf(s, "refresh").NextRun; !got.Equal(want) {
+		t.Errorf("NextRun = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Window is a daily time-of-day range such as "01:00-06:00", in the
+// location of the times it is asked about. It may wrap past midnight:
+// "23:00-06:00".
+type Window struct {
+	start, end int // minutes after midnight; end is exclusive
+}
+
+// ParseWindow reads a "HH:MM-HH:MM" window.
+func ParseWindow(spec string) (Window, error) {
+	from, to, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return Window{}, fmt.Errorf("invalid window %q: want HH:MM-HH:MM", spec)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %w", spec, err)
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %w", spec, err)
+	}
+	if start == end {
+		return Window{}, fmt.Errorf("invalid window %q: start and end are the same", spec)
+	}
+	return Window{start: start, end: end}, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Contains reports whether t falls inside the window.
+func (w Window) Contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return m >= w.start && m < w.end
+	}
+	return m >= w.start || m < w.end
+}
+
+// Until returns how long after t the window next opens, or 0 when t is
+// inside it.
+func (w Window) Until(t time.Time) time.Duration {
+	if w.Contains(t) {
+		return 0
+	}
+	open := time.Date(t.Year(), t.Month(), t.Day(), w.start/60, w.start%60, 0, 0, t.Location())
+	if !open.After(t) {
+		open = open.AddDate(0, 0, 1)
+	}
+	return open.Sub(t)
+}
@@ -0,0 +1,39 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindow(t *testing.T) {
+	shanghai := mustLoad(t, "Asia/Shanghai")
+	at := func(h, m int) time.Time { return time.Date(2026, 3, 14, h, m, 30, 0, shanghai) }
+
+	tests := []struct {
+		spec string
+		t    time.Time
+		want time.Duration
+	}{
+		{"01:00-06:00", at(2, 0), 0},
+		{"01:00-06:00", at(6, 0), 19*time.Hour - 30*time.Second},
+		{"01:00-06:00", at(0, 30), 30*time.Minute - 30*time.Second},
+		{"23:00-06:00", at(23, 15), 0},
+		{"23:00-06:00", at(5, 59), 0},
+		{"23:00-06:00", at(12, 0), 11*time.Hour - 30*time.Second},
+	}
+	for _, tt := range tests {
+		w, err := ParseWindow(tt.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.spec, err)
+		}
+		if got := w.Until(tt.t); got != tt.want {
+			t.Errorf("%s at %s: Until = %s, want %s", tt.spec, tt.t.Format("15:04:05"), got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "01:00", "1-6", "25:00-06:00", "03:00-03:00"} {
+		if _, err := ParseWindow(bad); err == nil {
+			t.Errorf("ParseWindow(%q) succeeded", bad)
+		}
+	}
+}
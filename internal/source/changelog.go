@@ -0,0 +1,208 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fnos-store/internal/cache"
+	"fnos-store/internal/config"
+)
+
+// githubReleasesAPI lists the catalog repository's releases, every app's
+// mixed together, newest first, a page at a time.
+const githubReleasesAPI = "https://api.github.com/repos/conversun/fnos-apps/releases"
+
+const (
+	// releasesPerPage is the most GitHub returns in one page. With ~145 apps
+	// sharing the list, one page reaches back only a few releases per app.
+	releasesPerPage = 100
+	// maxReleasePages bounds how far back a lookup pages, and so how much of
+	// the unauthenticated API's 60 requests an hour one lookup can use.
+	maxReleasePages = 10
+	// releaseListTTL is how long fetched pages serve every app's lookups
+	// before they are fetched again.
+	releaseListTTL = 15 * time.Minute
+)
+
+// ChangelogEntry is what changed in one version of an app.
+type ChangelogEntry struct {
+	Version string `json:"version"`
+	Date    string `json:"date,omitempty"`
+	Notes   string `json:"notes"`
+}
+
+// ReleaseNotesSource is implemented by sources that can look up an app's
+// release notes when the catalog carries no changelog for it.
+type ReleaseNotesSource interface {
+	// ReleaseNotes returns the notes of the app's releases, newest first.
+	// releaseTag is the app's latest release; the notes reach back to the
+	// release of version since, or only to the latest when since is empty.
+	ReleaseNotes(ctx context.Context, appname, releaseTag, since string) ([]ChangelogEntry, error)
+}
+
+type githubRelease struct {
+	TagName     string `json:"tag_name"`
+	Body        string `json:"body"`
+	PublishedAt string `json:"published_at"`
+	Draft       bool   `json:"draft"`
+}
+
+// releaseList holds the release pages fetched so far, newest first, shared by
+// every app's lookups until it expires.
+type releaseList struct {
+	mu        sync.Mutex
+	releases  []githubRelease
+	pages     int
+	complete  bool // the last page has been fetched
+	fetchedAt time.Time
+}
+
+// ReleaseNotes returns the bodies of appname's GitHub releases, whose tags
+// look like "gopeed/v1.6.0", back to since. They are cached in meta until
+// releaseTag stops being the latest release; notes that do not reach since,
+// or no notes at all, are looked up again next time.
+func (s *FNOSAppsSource) ReleaseNotes(ctx context.Context, appname, releaseTag, since string) ([]ChangelogEntry, error) {
+	if s.meta != nil {
+		if n, ok := s.meta.ReleaseNotes(appname); ok && n.ReleaseTag == releaseTag && notesReach(n, since) {
+			entries := make([]ChangelogEntry, 0, len(n.Notes))
+			for _, note := range n.Notes {
+				entries = append(entries, ChangelogEntry{Version: note.Version, Date: note.Date, Notes: note.Body})
+			}
+			return entries, nil
+		}
+	}
+
+	var entries []ChangelogEntry
+	reached := func(r githubRelease) bool {
+		if r.Draft {
+			return false
+		}
+		version, ok := releaseVersion(r.TagName, releaseTag)
+		if !ok {
+			return false
+		}
+		date, _, _ := strings.Cut(r.PublishedAt, "T")
+		entries = append(entries, ChangelogEntry{Version: version, Date: date, Notes: strings.TrimSpace(r.Body)})
+		return since == "" || version == since
+	}
+	complete, err := s.scanReleases(ctx, reached)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.meta != nil && len(entries) > 0 {
+		notes := make([]cache.ReleaseNote, 0, len(entries))
+		for _, e := range entries {
+			notes = append(notes, cache.ReleaseNote{Version: e.Version, Date: e.Date, Body: e.Notes})
+		}
+		s.meta.SetReleaseNotes(appname, cache.ReleaseNotes{ReleaseTag: releaseTag, FetchedAt: time.Now(), Notes: notes, Complete: complete})
+	}
+	return entries, nil
+}
+
+// notesReach reports whether cached notes cover the releases back to since.
+func notesReach(n cache.ReleaseNotes, since string) bool {
+	if len(n.Notes) == 0 {
+		return false
+	}
+	if since == "" || n.Complete {
+		return true
+	}
+	return slices.ContainsFunc(n.Notes, func(note cache.ReleaseNote) bool { return note.Version == since })
+}
+
+// scanReleases calls visit with the repository's releases, newest first,
+// until visit returns true, fetching further pages as needed. It reports
+// complete when every release was visited.
+func (s *FNOSAppsSource) scanReleases(ctx context.Context, visit func(githubRelease) bool) (complete bool, err error) {
+	l := &s.releaseList
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if time.Since(l.fetchedAt) > releaseListTTL {
+		l.releases, l.pages, l.complete = nil, 0, false
+	}
+
+	for i := 0; ; i++ {
+		if i == len(l.releases) {
+			if l.complete || l.pages >= maxReleasePages {
+				return l.complete, nil
+			}
+			page, err := s.fetchReleases(ctx, l.pages+1)
+			if err != nil {
+				return false, err
+			}
+			if l.pages == 0 {
+				l.fetchedAt = time.Now()
+			}
+			l.pages++
+			l.releases = append(l.releases, page...)
+			l.complete = len(page) < releasesPerPage
+			if i == len(l.releases) {
+				return true, nil
+			}
+		}
+		if visit(l.releases[i]) {
+			return false, nil
+		}
+	}
+}
+
+// fetchReleases fetches one page of the repository's releases through the
+// configured mirror, falling back like the catalog does.
+func (s *FNOSAppsSource) fetchReleases(ctx context.Context, page int) ([]githubRelease, error) {
+	var cfg config.Config
+	if s.configMgr != nil {
+		cfg = s.configMgr.Get()
+	} else {
+		cfg = config.Config{Mirror: config.DefaultMirror}
+	}
+	u, err := url.Parse(s.releasesURL)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("per_page", strconv.Itoa(releasesPerPage))
+	q.Set("page", strconv.Itoa(page))
+	u.RawQuery = q.Encode()
+
+	var lastErr error
+	for _, prefix := range config.GitHubFallbackPrefixes(cfg.Mirror, cfg) {
+		res, err := conditionalGet(ctx, s.httpClient, nil, config.MirrorURL(prefix, u.String()), "", "releases")
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		var releases []githubRelease
+		if err := json.Unmarshal(res.raw, &releases); err != nil {
+			lastErr = fmt.Errorf("decode releases: %w", err)
+			continue
+		}
+		return releases, nil
+	}
+	return nil, lastErr
+}
+
+// releaseVersion returns the version in tag when tag is a release of the same
+// app as latestTag: both "<app>/v<version>". A tag outside that scheme only
+// matches itself.
+func releaseVersion(tag, latestTag string) (string, bool) {
+	idx := strings.Index(latestTag, "/v")
+	if idx <= 0 {
+		if tag != latestTag {
+			return "", false
+		}
+		return strings.TrimPrefix(tag, "v"), true
+	}
+	version, ok := strings.CutPrefix(tag, latestTag[:idx+2])
+	if !ok {
+		return "", false
+	}
+	return version, true
+}
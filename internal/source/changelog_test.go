@@ -0,0 +1,96 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReleaseVersion(t *testing.T) {
+	tests := []struct {
+		tag, latest, want string
+		ok                bool
+	}{
+		{"gopeed/v1.6.0", "gopeed/v1.7.0", "1.6.0", true},
+		{"gopeed/v1.7.0-r2", "gopeed/v1.7.0", "1.7.0-r2", true},
+		{"emby/v4.9.0", "gopeed/v1.7.0", "", false},
+		{"gopeed-extra/v1.0", "gopeed/v1.7.0", "", false},
+		{"v2.0", "v2.0", "2.0", true},
+		{"v1.0", "v2.0", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := releaseVersion(tt.tag, tt.latest)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("releaseVersion(%q, %q) = %q, %v; want %q, %v", tt.tag, tt.latest, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// TestReleaseNotesPaginates serves the releases of many apps over two pages,
+// as the catalog repository does: an app's older releases are only found by
+// paging back, and the notes are cached once they reach the installed
+// version.
+func TestReleaseNotesPaginates(t *testing.T) {
+	var pages []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pages = append(pages, r.URL.Query().Get("page"))
+		var releases []string
+		switch r.URL.Query().Get("page") {
+		case "1":
+			releases = append(releases,
+				`{"tag_name":"gopeed/v1.8.0","body":"unreleased","draft":true}`,
+				`{"tag_name":"gopeed/v1.7.0","body":" new UI \n","published_at":"2026-03-01T10:00:00Z"}`)
+			for i := len(releases); i < releasesPerPage; i++ {
+				releases = append(releases, fmt.Sprintf(`{"tag_name":"app%d/v1.0","body":"other app"}`, i))
+			}
+		case "2":
+			releases = append(releases,
+				`{"tag_name":"gopeed/v1.6.1","body":"fix","published_at":"2026-02-01T10:00:00Z"}`,
+				`{"tag_name":"gopeed/v1.6.0","body":"old","published_at":"2026-01-01T10:00:00Z"}`,
+				`{"tag_name":"gopeed/v1.5.0","body":"older","published_at":"2025-12-01T10:00:00Z"}`)
+		}
+		_, _ = w.Write([]byte("[" + strings.Join(releases, ",") + "]"))
+	}))
+	defer srv.Close()
+
+	src, _ := newTestAppsSource(t, "")
+	src.releasesURL = srv.URL + "/releases"
+
+	// Not installed: the latest release's notes are on the first page.
+	notes, err := src.ReleaseNotes(context.Background(), "gopeed", "gopeed/v1.7.0", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 1 || notes[0].Version != "1.7.0" || notes[0].Notes != "new UI" || notes[0].Date != "2026-03-01" {
+		t.Fatalf("notes = %+v", notes)
+	}
+	if !slices.Equal(pages, []string{"1"}) {
+		t.Errorf("pages fetched = %v, want only the first", pages)
+	}
+
+	// Installed at 1.6.0: the notes reach back to it, on the second page.
+	for range 2 {
+		notes, err = src.ReleaseNotes(context.Background(), "gopeed", "gopeed/v1.7.0", "1.6.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(notes) != 3 || notes[2].Version != "1.6.0" {
+			t.Fatalf("notes since 1.6.0 = %+v", notes)
+		}
+	}
+	if !slices.Equal(pages, []string{"1", "2"}) {
+		t.Errorf("pages fetched = %v, want each page once", pages)
+	}
+
+	// An app without releases gets none, and nothing empty is cached.
+	if notes, err := src.ReleaseNotes(context.Background(), "emby", "emby/v4.9.0", ""); err != nil || len(notes) != 0 {
+		t.Fatalf("emby notes = %+v, %v", notes, err)
+	}
+	if _, ok := src.meta.ReleaseNotes("emby"); ok {
+		t.Error("empty notes were cached")
+	}
+}
@@ -2,16 +2,17 @@ package source
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"slices"
+	"sync"
 	"time"
 
+	"fnos-store/internal/cache"
 	"fnos-store/internal/config"
 	"fnos-store/internal/platform"
 )
@@ -22,17 +23,30 @@ const (
 )
 
 type FNOSAppsSource struct {
-	httpClient *http.Client
-	appsURL    string
-	cachePath  string
-	localPath  string
-	platform   string
-	name       string
-	configMgr  *config.Manager
+	httpClient  *http.Client
+	appsURL     string
+	releasesURL string
+	cachePath   string
+	localPath   string
+	platform    string
+	name        string
+	configMgr   *config.Manager
+	meta        *cache.Store
+
+	mu   sync.Mutex
+	memo appsMemo
+
+	releaseList releaseList
 }
 
-type appsJSONPayload struct {
-	Apps []appsJSONEntry `json:"apps"`
+// appsMemo is the last decoded catalog, reused when a 304 confirms the cached
+// copy it was decoded from. Icon URLs depend on the mirror, so a mirror
+// change invalidates it too.
+type appsMemo struct {
+	sum     string
+	prefix  string
+	apps    []RemoteApp
+	invalid []EntryError
 }
 
 type appsJSONEntry struct {
@@ -52,17 +66,27 @@ type appsJSONEntry struct {
 	Category        string   `json:"category"`
 	Platforms       []string `json:"platforms"`
 	PostInstallNote string   `json:"post_install_note,omitempty"`
+	MinFnOSVersion  string   `json:"min_fnos_version,omitempty"`
+	MaxFnOSVersion  string   `json:"max_fnos_version,omitempty"`
+	// Changelog lists what changed per version, newest first.
+	Changelog []ChangelogEntry `json:"changelog,omitempty"`
+	// Redact lists regular expressions for app-specific secrets in logs.
+	Redact []string `json:"redact,omitempty"`
 }
 
-func NewFNOSAppsSource(cachePath, localPath string, cfgMgr *config.Manager) *FNOSAppsSource {
+// NewFNOSAppsSource returns the fnos-apps catalog source. meta, when set,
+// keeps the HTTP validators and provenance of the cached copy.
+func NewFNOSAppsSource(cachePath, localPath string, cfgMgr *config.Manager, meta *cache.Store) *FNOSAppsSource {
 	return &FNOSAppsSource{
-		httpClient: &http.Client{Timeout: 20 * time.Second},
-		appsURL:    defaultAppsJSONURL,
-		cachePath:  cachePath,
-		localPath:  localPath,
-		platform:   platform.DetectPlatform(),
-		name:       "fnos-apps",
-		configMgr:  cfgMgr,
+		httpClient:  &http.Client{Timeout: 20 * time.Second, Transport: cfgMgr.HTTPTransport()},
+		appsURL:     defaultAppsJSONURL,
+		releasesURL: githubReleasesAPI,
+		cachePath:   cachePath,
+		localPath:   localPath,
+		platform:    platform.DetectPlatform(),
+		name:        "fnos-apps",
+		configMgr:   cfgMgr,
+		meta:        meta,
 	}
 }
 
@@ -86,34 +110,48 @@ type FetchProgress struct {
 	URL    string
 	Status string
 	Error  string
+	// NotModified marks a "success" where the mirror confirmed the cached
+	// catalog is current.
+	NotModified bool
 }
 
 type ProgressFunc func(FetchProgress)
 
-func (s *FNOSAppsSource) FetchApps(ctx context.Context) ([]RemoteApp, error) {
+func (s *FNOSAppsSource) FetchApps(ctx context.Context) ([]RemoteApp, Provenance, error) {
 	return s.FetchAppsWithProgress(ctx, nil)
 }
 
-func (s *FNOSAppsSource) FetchAppsWithProgress(ctx context.Context, onProgress ProgressFunc) ([]RemoteApp, error) {
-	apps, raw, err := s.fetchRemoteWithProgress(ctx, onProgress)
+// FetchAppsWithProgress fetches the catalog from the mirrors, falling back to
+// the cached copy and then the bundled one. The provenance says which was
+// used; the fallbacks are not errors.
+func (s *FNOSAppsSource) FetchAppsWithProgress(ctx context.Context, onProgress ProgressFunc) ([]RemoteApp, Provenance, error) {
+	apps, res, err := s.fetchRemoteWithProgress(ctx, onProgress)
 	if err == nil {
-		_ = s.writeCache(raw)
-		return apps, nil
+		if res.notModified || s.writeCache(res.raw) == nil {
+			recordFetch(s.meta, s.cachePath, res)
+		}
+		prov := Provenance{Origin: OriginRemote, Mirror: res.mirror, AsOf: time.Now(), InvalidEntries: s.invalidEntries()}
+		return apps, prov, nil
 	}
 
 	cached, cacheErr := s.readCache()
 	if cacheErr == nil {
-		return cached, nil
+		prov := cachedProvenance(s.meta, s.cachePath)
+		prov.FetchError = err.Error()
+		prov.InvalidEntries = s.invalidEntries()
+		return cached, prov, nil
 	}
 
 	if local, localErr := s.readLocal(); localErr == nil {
-		return local, nil
+		prov := Provenance{Origin: OriginLocal, AsOf: modTime(s.localPath), FetchError: err.Error(), InvalidEntries: s.invalidEntries()}
+		recordLocalCopy(s.meta, s.cachePath, prov.AsOf)
+		return local, prov, nil
 	}
 
-	return nil, fmt.Errorf("fetch apps from remote failed: %w", err)
+	return nil, Provenance{}, fmt.Errorf("fetch apps from remote failed: %w", err)
 }
 
-func (s *FNOSAppsSource) fetchRemoteWithProgress(ctx context.Context, onProgress ProgressFunc) ([]RemoteApp, []byte, error) {
+func (s *FNOSAppsSource) fetchRemoteWithProgress(ctx context.Context, onProgress ProgressFunc) ([]RemoteApp, fetchResult, error) {
 	var cfg config.Config
 	if s.configMgr != nil {
 		cfg = s.configMgr.Get()
@@ -121,25 +159,24 @@ func (s *FNOSAppsSource) fetchRemoteWithProgress(ctx context.Context, onProgress
 		cfg = config.Config{Mirror: config.DefaultMirror}
 	}
 	prefixes := config.GitHubFallbackPrefixes(cfg.Mirror, cfg)
+	cachedSum := fileSHA256(s.cachePath)
 
 	var lastErr error
 	for _, prefix := range prefixes {
-		label := mirrorLabelForPrefix(prefix)
-		u := s.appsURL
-		if prefix != "" {
-			u = prefix + s.appsURL
-		}
+		label := mirrorLabelForPrefix(prefix, cfg)
+		u := config.MirrorURL(prefix, s.appsURL)
 
 		if onProgress != nil {
 			onProgress(FetchProgress{Mirror: label, URL: prefix, Status: "trying"})
 		}
 
-		apps, raw, err := s.fetchURL(ctx, u)
+		apps, res, err := s.fetchURL(ctx, u, cachedSum)
 		if err == nil {
+			res.mirror = label
 			if onProgress != nil {
-				onProgress(FetchProgress{Mirror: label, URL: prefix, Status: "success"})
+				onProgress(FetchProgress{Mirror: label, URL: prefix, Status: "success", NotModified: res.notModified})
 			}
-			return apps, raw, nil
+			return apps, res, nil
 		}
 
 		if onProgress != nil {
@@ -147,14 +184,14 @@ func (s *FNOSAppsSource) fetchRemoteWithProgress(ctx context.Context, onProgress
 		}
 		lastErr = err
 	}
-	return nil, nil, lastErr
+	return nil, fetchResult{}, lastErr
 }
 
-func mirrorLabelForPrefix(prefix string) string {
+func mirrorLabelForPrefix(prefix string, cfg config.Config) string {
 	if prefix == "" {
 		return "直连 GitHub"
 	}
-	for _, m := range config.GitHubMirrorOptions() {
+	for _, m := range slices.Concat(config.GitHubMirrorOptions(), cfg.CustomGitHubMirrorList()) {
 		if m.URL == prefix {
 			return m.Label
 		}
@@ -162,48 +199,67 @@ func mirrorLabelForPrefix(prefix string) string {
 	return prefix
 }
 
-func (s *FNOSAppsSource) fetchURL(ctx context.Context, url string) ([]RemoteApp, []byte, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return nil, nil, fmt.Errorf("build apps.json request: %w", err)
-	}
-
-	resp, err := s.httpClient.Do(req)
+// fetchURL fetches apps.json from url, revalidating the cached copy whose
+// hash is cachedSum. On a 304 the cached copy is decoded, or reused if it
+// already was.
+func (s *FNOSAppsSource) fetchURL(ctx context.Context, url, cachedSum string) ([]RemoteApp, fetchResult, error) {
+	res, err := conditionalGet(ctx, s.httpClient, s.meta, url, cachedSum, "apps.json")
 	if err != nil {
-		return nil, nil, err
+		return nil, fetchResult{}, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, nil, fmt.Errorf("apps.json http status: %s", resp.Status)
+	if res.notModified {
+		apps, err := s.cachedApps(cachedSum)
+		if err != nil {
+			return nil, fetchResult{}, err
+		}
+		return apps, res, nil
 	}
 
-	raw, err := io.ReadAll(resp.Body)
+	apps, err := s.decodeApps(res.raw)
 	if err != nil {
-		return nil, nil, fmt.Errorf("read apps.json response: %w", err)
+		return nil, fetchResult{}, err
 	}
 
-	apps, err := s.decodeApps(raw)
-	if err != nil {
-		return nil, nil, err
+	return apps, res, nil
+}
+
+// cachedApps returns the decoded cache file whose hash is sum.
+func (s *FNOSAppsSource) cachedApps(sum string) ([]RemoteApp, error) {
+	prefix := s.mirrorPrefix()
+	s.mu.Lock()
+	if s.memo.sum == sum && s.memo.prefix == prefix {
+		apps := slices.Clone(s.memo.apps)
+		s.mu.Unlock()
+		return apps, nil
 	}
+	s.mu.Unlock()
+	return s.readCache()
+}
 
-	return apps, raw, nil
+// invalidEntries returns the entries the last decoded catalog left out.
+func (s *FNOSAppsSource) invalidEntries() []EntryError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.memo.invalid)
 }
 
+// decodeApps decodes apps.json, leaving out (and logging) entries that fail
+// schema validation. A catalog with an unsupported schema version is an
+// error. Apps built only for other platforms are kept: the registry marks
+// them incompatible.
 func (s *FNOSAppsSource) decodeApps(raw []byte) ([]RemoteApp, error) {
-	var payload appsJSONPayload
-	if err := json.Unmarshal(raw, &payload); err != nil {
-		return nil, fmt.Errorf("decode apps.json: %w", err)
+	entries, invalid, err := decodeCatalog[appsJSONEntry](raw, "apps.json", appsSchema, "appname")
+	if err != nil {
+		return nil, err
+	}
+	for _, ee := range invalid {
+		log.Printf("source: %s: skipping invalid entry %s", s.Name(), ee)
 	}
 
 	prefix := s.mirrorPrefix()
-	apps := make([]RemoteApp, 0, len(payload.Apps))
-	for _, item := range payload.Apps {
-		if !s.supportsPlatform(item.Platforms) {
-			continue
-		}
-
+	apps := make([]RemoteApp, 0, len(entries))
+	for _, item := range entries {
 		directURL := fmt.Sprintf(
 			"%s/%s/%s_%s_%s.fpk",
 			githubReleaseBase,
@@ -232,25 +288,26 @@ func (s *FNOSAppsSource) decodeApps(raw []byte) ([]RemoteApp, error) {
 			Category:        item.Category,
 			Source:          s.Name(),
 			PostInstallNote: item.PostInstallNote,
+			MinFnOSVersion:  item.MinFnOSVersion,
+			MaxFnOSVersion:  item.MaxFnOSVersion,
+			Changelog:       item.Changelog,
+			RedactPatterns:  item.Redact,
 		}
 
 		if prefix != "" {
 			if item.IconURL != "" {
-				app.IconURL = prefix + item.IconURL
+				app.IconURL = config.MirrorURL(prefix, item.IconURL)
 			}
 		}
 
 		apps = append(apps, app)
 	}
 
-	return apps, nil
-}
+	s.mu.Lock()
+	s.memo = appsMemo{sum: sha256Hex(raw), prefix: prefix, apps: slices.Clone(apps), invalid: invalid}
+	s.mu.Unlock()
 
-func (s *FNOSAppsSource) supportsPlatform(platforms []string) bool {
-	if len(platforms) == 0 {
-		return true
-	}
-	return slices.Contains(platforms, s.platform)
+	return apps, nil
 }
 
 func (s *FNOSAppsSource) writeCache(raw []byte) error {
@@ -0,0 +1,152 @@
+package source
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"fnos-store/internal/cache"
+)
+
+// fetchResult is one catalog response. With notModified set the server
+// confirmed the cached copy is current and raw is empty.
+type fetchResult struct {
+	url          string
+	mirror       string
+	raw          []byte
+	notModified  bool
+	etag         string
+	lastModified string
+}
+
+// conditionalGet fetches url. When meta holds a validator for url that
+// belongs to the cached copy (cachedSum), the request is conditional and a
+// 304 comes back as notModified.
+func conditionalGet(ctx context.Context, client *http.Client, meta *cache.Store, url, cachedSum, what string) (fetchResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fetchResult{}, fmt.Errorf("build %s request: %w", what, err)
+	}
+	if meta != nil && cachedSum != "" {
+		if v, ok := meta.HTTPValidator(url); ok && v.SHA256 == cachedSum {
+			if v.ETag != "" {
+				req.Header.Set("If-None-Match", v.ETag)
+			}
+			if v.LastModified != "" {
+				req.Header.Set("If-Modified-Since", v.LastModified)
+			}
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fetchResult{}, err
+	}
+	defer resp.Body.Close()
+
+	res := fetchResult{url: url}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		if req.Header.Get("If-None-Match") == "" && req.Header.Get("If-Modified-Since") == "" {
+			return fetchResult{}, fmt.Errorf("%s http status: %s to an unconditional request", what, resp.Status)
+		}
+		res.notModified = true
+		return res, nil
+	default:
+		return fetchResult{}, fmt.Errorf("%s http status: %s", what, resp.Status)
+	}
+
+	res.raw, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return fetchResult{}, fmt.Errorf("read %s response: %w", what, err)
+	}
+	res.etag = resp.Header.Get("ETag")
+	res.lastModified = resp.Header.Get("Last-Modified")
+	return res, nil
+}
+
+// recordFetch stores what res tells about the cached copy of a catalog: its
+// validators and, for a fresh copy, the mirror that served it.
+func recordFetch(meta *cache.Store, cachePath string, res fetchResult) {
+	if meta == nil || cachePath == "" {
+		return
+	}
+	name := filepath.Base(cachePath)
+	now := time.Now()
+
+	if res.notModified {
+		f, _ := meta.CatalogFetch(name)
+		f.CheckedAt = now
+		meta.SetCatalogFetch(name, f)
+		return
+	}
+
+	meta.SetHTTPValidator(res.url, cache.HTTPValidator{
+		ETag:         res.etag,
+		LastModified: res.lastModified,
+		SHA256:       sha256Hex(res.raw),
+	})
+	meta.SetCatalogFetch(name, cache.CatalogFetch{
+		Mirror:    res.mirror,
+		URL:       res.url,
+		FetchedAt: now,
+		CheckedAt: now,
+	})
+}
+
+// cachedProvenance describes the cached copy at cachePath from the fetch
+// recorded for it, or from the file itself when nothing was recorded.
+func cachedProvenance(meta *cache.Store, cachePath string) Provenance {
+	prov := Provenance{Origin: OriginCache}
+	if meta != nil {
+		if f, ok := meta.CatalogFetch(filepath.Base(cachePath)); ok {
+			prov.Mirror = f.Mirror
+			prov.AsOf = f.CheckedAt
+		}
+	}
+	if prov.AsOf.IsZero() {
+		prov.AsOf = modTime(cachePath)
+	}
+	return prov
+}
+
+// recordLocalCopy notes that the cache at cachePath now holds the bundled
+// catalog, so a later fallback to the cache does not report it as a mirror's.
+func recordLocalCopy(meta *cache.Store, cachePath string, asOf time.Time) {
+	if meta == nil || cachePath == "" {
+		return
+	}
+	meta.SetCatalogFetch(filepath.Base(cachePath), cache.CatalogFetch{FetchedAt: asOf, CheckedAt: asOf})
+}
+
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// fileSHA256 hashes the file at path, or returns "" when it cannot be read.
+func fileSHA256(path string) string {
+	if path == "" {
+		return ""
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return sha256Hex(raw)
+}
+
+func sha256Hex(raw []byte) string {
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,153 @@
+package source
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"fnos-store/internal/cache"
+	"fnos-store/internal/config"
+)
+
+const testAppsJSON = `{"apps":[{"appname":"gopeed","display_name":"Gopeed","version":"1.6.0","release_tag":"gopeed/v1.6.0","file_prefix":"gopeed","fpk_version":"1.6.0"}]}`
+
+func newTestAppsSource(t *testing.T, url string) (*FNOSAppsSource, *cache.Store) {
+	t.Helper()
+	dir := t.TempDir()
+	cfgMgr := config.NewManager(dir)
+	if err := cfgMgr.SaveConfig(config.Config{Mirror: "direct"}); err != nil {
+		t.Fatal(err)
+	}
+	meta := cache.NewStore(dir)
+	if err := meta.Init(); err != nil {
+		t.Fatal(err)
+	}
+	src := NewFNOSAppsSource(filepath.Join(dir, "cache", "apps.json"), "", cfgMgr, meta)
+	src.appsURL = url
+	return src, meta
+}
+
+func TestFetchAppsRevalidatesWithETag(t *testing.T) {
+	var full, notModified atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Sat, 14 Mar 2026 10:00:00 GMT")
+		_, _ = w.Write([]byte(testAppsJSON))
+	}))
+	defer srv.Close()
+
+	src, meta := newTestAppsSource(t, srv.URL+"/apps.json")
+	for i := range 2 {
+		apps, prov, err := src.FetchApps(context.Background())
+		if err != nil {
+			t.Fatalf("fetch %d: %v", i, err)
+		}
+		if len(apps) != 1 || apps[0].AppName != "gopeed" {
+			t.Fatalf("fetch %d: apps = %+v", i, apps)
+		}
+		if prov.Origin != OriginRemote {
+			t.Errorf("fetch %d: origin = %q, want remote", i, prov.Origin)
+		}
+	}
+	if full.Load() != 1 || notModified.Load() != 1 {
+		t.Errorf("full = %d, not modified = %d; want 1 and 1", full.Load(), notModified.Load())
+	}
+
+	v, ok := meta.HTTPValidator(srv.URL + "/apps.json")
+	if !ok || v.ETag != `"v1"` || v.LastModified == "" {
+		t.Errorf("validator = %+v, %v", v, ok)
+	}
+	f, ok := meta.CatalogFetch("apps.json")
+	if !ok || f.URL != srv.URL+"/apps.json" || f.Mirror != "直连 GitHub" || f.CheckedAt.Before(f.FetchedAt) {
+		t.Errorf("catalog fetch = %+v, %v", f, ok)
+	}
+
+	// A validator recorded for a different body than the cached one must not
+	// be sent.
+	meta.SetHTTPValidator(srv.URL+"/apps.json", cache.HTTPValidator{ETag: `"v1"`, SHA256: "stale"})
+	if _, _, err := src.FetchApps(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if full.Load() != 2 {
+		t.Errorf("full = %d after validator mismatch, want 2", full.Load())
+	}
+}
+
+func TestConditionalGetRejectsUnsolicited304(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer srv.Close()
+
+	if _, err := conditionalGet(context.Background(), srv.Client(), nil, srv.URL, "", "apps.json"); err == nil {
+		t.Error("304 without a conditional request was accepted")
+	}
+}
+
+func TestFetchAppsProvenanceFallbacks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(testAppsJSON))
+	}))
+	defer srv.Close()
+
+	src, _ := newTestAppsSource(t, srv.URL+"/apps.json")
+	if _, _, err := src.FetchApps(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// With every mirror unreachable the cached copy is served, attributed to
+	// the mirror that fetched it.
+	offline, cancel := context.WithCancel(context.Background())
+	cancel()
+	apps, prov, err := src.FetchApps(offline)
+	if err != nil || len(apps) != 1 {
+		t.Fatalf("cache fallback: apps = %v, err = %v", apps, err)
+	}
+	if prov.Origin != OriginCache || prov.Mirror != "直连 GitHub" || prov.FetchError == "" {
+		t.Errorf("cache provenance = %+v", prov)
+	}
+	if age := prov.Age(time.Now()); age < 0 || age > time.Minute {
+		t.Errorf("cache age = %s", age)
+	}
+
+	// Without a cache the bundled copy is served.
+	local := filepath.Join(t.TempDir(), "apps.json")
+	if err := os.WriteFile(local, []byte(testAppsJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bundled := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(local, bundled, bundled); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(src.cachePath); err != nil {
+		t.Fatal(err)
+	}
+	src.localPath = local
+	_, prov, err = src.FetchApps(offline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prov.Origin != OriginLocal || prov.Mirror != "" || !prov.AsOf.Equal(bundled) {
+		t.Errorf("local provenance = %+v", prov)
+	}
+
+	// The cache now holds the bundled copy and must say so.
+	_, prov, err = src.FetchApps(offline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prov.Origin != OriginCache || prov.Mirror != "" || !prov.AsOf.Equal(bundled) {
+		t.Errorf("cached bundled provenance = %+v", prov)
+	}
+}
@@ -0,0 +1,89 @@
+package source
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+
+	"fnos-store/internal/cache"
+	"fnos-store/internal/config"
+)
+
+const defaultMirrorsJSONURL = "https://raw.githubusercontent.com/conversun/fnos-apps/main/mirrors.json"
+
+// ErrNoMirrorsKey means the build carries no trusted signing key; it matches
+// ErrNoSigningKey.
+var ErrNoMirrorsKey = fmt.Errorf("mirrors.json: %w", ErrNoSigningKey)
+
+// MirrorList is the catalog's mirror registry. It is merged over the mirrors
+// compiled into config (see config.SetRemoteMirrors).
+type MirrorList struct {
+	// Serial increases with every published list; an older list than the
+	// cached one is refused.
+	Serial        int                   `json:"serial"`
+	GitHubMirrors []config.GitHubMirror `json:"github_mirrors,omitempty"`
+	DockerMirrors []config.DockerMirror `json:"docker_mirrors,omitempty"`
+}
+
+// MirrorsSource fetches mirrors.json from the catalog repository, through the
+// mirrors it describes, and keeps the last verified copy for offline use.
+type MirrorsSource struct {
+	signedFile
+}
+
+// NewMirrorsSource returns the mirror registry source. meta, when set, keeps
+// the HTTP validators of the cached copy.
+func NewMirrorsSource(cachePath string, cfgMgr *config.Manager, meta *cache.Store) *MirrorsSource {
+	return &MirrorsSource{newSignedFile("mirrors.json", defaultMirrorsJSONURL, cachePath, cfgMgr, meta)}
+}
+
+// FetchMirrorList returns the newest verified registry: the remote one, or
+// the cached one when no mirror serves a valid copy. It fails only when
+// neither is available.
+func (s *MirrorsSource) FetchMirrorList(ctx context.Context) (MirrorList, error) {
+	if len(s.keys) == 0 {
+		return MirrorList{}, ErrNoMirrorsKey
+	}
+	payload, err := s.latest(ctx)
+	if err != nil {
+		return MirrorList{}, err
+	}
+	return decodeMirrorList(payload)
+}
+
+// decodeMirrorList decodes a registry and drops entries the store could not
+// use. Retired entries only need their key.
+func decodeMirrorList(payload []byte) (MirrorList, error) {
+	var list MirrorList
+	if err := json.Unmarshal(payload, &list); err != nil {
+		return MirrorList{}, fmt.Errorf("decode mirrors.json payload: %w", err)
+	}
+	list.GitHubMirrors = slices.DeleteFunc(list.GitHubMirrors, func(m config.GitHubMirror) bool {
+		if m.Key == "" || (!m.Retired && (m.Label == "" || config.ValidateCustomGitHubMirrors([]config.CustomMirror{{URL: m.URL}}) != nil)) {
+			log.Printf("source: mirrors.json: skipping invalid GitHub mirror %q", m.Key)
+			return true
+		}
+		return false
+	})
+	list.DockerMirrors = slices.DeleteFunc(list.DockerMirrors, func(m config.DockerMirror) bool {
+		if m.Key == "" || (!m.Retired && (m.Label == "" || config.ValidateCustomDockerMirrors([]config.CustomMirror{{URL: m.URL}}) != nil)) {
+			log.Printf("source: mirrors.json: skipping invalid Docker mirror %q", m.Key)
+			return true
+		}
+		return false
+	})
+	return list, nil
+}
+
+// SignMirrorList wraps the JSON registry payload in a signed envelope, the
+// file format FetchMirrorList reads.
+func SignMirrorList(priv ed25519.PrivateKey, payload []byte) ([]byte, error) {
+	var list MirrorList
+	if err := json.Unmarshal(payload, &list); err != nil {
+		return nil, fmt.Errorf("decode list: %w", err)
+	}
+	return signPayload(priv, payload)
+}
@@ -0,0 +1,68 @@
+package source
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"fnos-store/internal/cache"
+	"fnos-store/internal/config"
+)
+
+func TestFetchMirrorList(t *testing.T) {
+	priv := buildWithSigningKey(t)
+	signed, err := SignMirrorList(priv, []byte(`{
+		"serial": 1,
+		"github_mirrors": [
+			{"key": "ghfast", "retired": true},
+			{"key": "fresh", "label": "Fresh", "url": "https://fresh.example/"},
+			{"key": "broken", "label": "Broken", "url": "fresh.example"}
+		],
+		"docker_mirrors": [
+			{"key": "hub2", "label": "Hub 2", "url": "hub2.example/", "multi_registry": true},
+			{"key": "bad", "label": "Bad", "url": "https://bad.example/"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(signed)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	cfgMgr := config.NewManager(dir)
+	if err := cfgMgr.SaveConfig(config.Config{Mirror: "direct"}); err != nil {
+		t.Fatal(err)
+	}
+	meta := cache.NewStore(dir)
+	if err := meta.Init(); err != nil {
+		t.Fatal(err)
+	}
+	src := NewMirrorsSource(filepath.Join(dir, "cache", "mirrors.json"), cfgMgr, meta)
+	src.url = srv.URL + "/mirrors.json"
+
+	list, err := src.FetchMirrorList(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.GitHubMirrors) != 2 || list.GitHubMirrors[1].Key != "fresh" || len(list.DockerMirrors) != 1 || !list.DockerMirrors[0].MultiRegistry {
+		t.Errorf("list = %+v", list)
+	}
+
+	// Offline, the cached copy is used.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if list, err := src.FetchMirrorList(ctx); err != nil || list.Serial != 1 {
+		t.Errorf("offline: list = %+v, err = %v", list, err)
+	}
+}
+
+func TestFetchMirrorListWithoutKey(t *testing.T) {
+	if _, err := NewMirrorsSource("", nil, nil).FetchMirrorList(context.Background()); err != ErrNoMirrorsKey {
+		t.Errorf("err = %v, want ErrNoMirrorsKey", err)
+	}
+}
@@ -2,15 +2,17 @@ package source
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync"
 	"time"
 
+	"fnos-store/internal/cache"
 	"fnos-store/internal/config"
 )
 
@@ -26,25 +28,29 @@ type RecommendedApp struct {
 	UpdatedAt     string `json:"updated_at"`
 }
 
-type recommendedPayload struct {
-	Apps []RecommendedApp `json:"apps"`
-}
-
 type RecommendedSource struct {
 	httpClient     *http.Client
 	recommendedURL string
 	cachePath      string
 	localPath      string
 	configMgr      *config.Manager
+	meta           *cache.Store
+
+	mu       sync.Mutex
+	memoSum  string // hash of the recommended.json memoApps was decoded from
+	memoApps []RecommendedApp
 }
 
-func NewRecommendedSource(cachePath, localPath string, cfgMgr *config.Manager) *RecommendedSource {
+// NewRecommendedSource returns the recommended-apps source. meta, when set,
+// keeps the HTTP validators and provenance of the cached copy.
+func NewRecommendedSource(cachePath, localPath string, cfgMgr *config.Manager, meta *cache.Store) *RecommendedSource {
 	return &RecommendedSource{
-		httpClient:     &http.Client{Timeout: 20 * time.Second},
+		httpClient:     &http.Client{Timeout: 20 * time.Second, Transport: cfgMgr.HTTPTransport()},
 		recommendedURL: defaultRecommendedJSONURL,
 		cachePath:      cachePath,
 		localPath:      localPath,
 		configMgr:      cfgMgr,
+		meta:           meta,
 	}
 }
 
@@ -56,38 +62,33 @@ func (s *RecommendedSource) FetchRecommendedApps(ctx context.Context) ([]Recomme
 		cfg = config.Config{Mirror: config.DefaultMirror}
 	}
 
+	cachedSum := fileSHA256(s.cachePath)
 	for _, prefix := range config.GitHubFallbackPrefixes(cfg.Mirror, cfg) {
-		url := s.recommendedURL
-		if prefix != "" {
-			url = prefix + s.recommendedURL
-		}
+		url := config.MirrorURL(prefix, s.recommendedURL)
 
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		res, err := conditionalGet(ctx, s.httpClient, s.meta, url, cachedSum, "recommended.json")
 		if err != nil {
 			continue
 		}
-
-		resp, err := s.httpClient.Do(req)
-		if err != nil {
-			continue
+		res.mirror = mirrorLabelForPrefix(prefix, cfg)
+
+		if res.notModified {
+			apps, err := s.cachedRecommended(cachedSum)
+			if err != nil {
+				continue
+			}
+			recordFetch(s.meta, s.cachePath, res)
+			return apps, nil
 		}
 
-		raw, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
+		apps, err := s.decodeRecommended(res.raw)
 		if err != nil {
 			continue
 		}
 
-		if resp.StatusCode != http.StatusOK {
-			continue
+		if s.writeCache(res.raw) == nil {
+			recordFetch(s.meta, s.cachePath, res)
 		}
-
-		apps, err := s.decodeRecommended(raw)
-		if err != nil {
-			continue
-		}
-
-		_ = s.writeCache(raw)
 		return apps, nil
 	}
 
@@ -102,13 +103,34 @@ func (s *RecommendedSource) FetchRecommendedApps(ctx context.Context) ([]Recomme
 	return []RecommendedApp{}, nil
 }
 
+// decodeRecommended decodes recommended.json, leaving out (and logging)
+// entries that fail schema validation.
 func (s *RecommendedSource) decodeRecommended(raw []byte) ([]RecommendedApp, error) {
-	var payload recommendedPayload
-	if err := json.Unmarshal(raw, &payload); err != nil {
-		return nil, fmt.Errorf("decode recommended.json: %w", err)
+	apps, invalid, err := decodeCatalog[RecommendedApp](raw, "recommended.json", recommendedSchema, "name")
+	if err != nil {
+		return nil, err
 	}
+	for _, ee := range invalid {
+		log.Printf("source: recommended: skipping invalid entry %s", ee)
+	}
+
+	s.mu.Lock()
+	s.memoSum, s.memoApps = sha256Hex(raw), slices.Clone(apps)
+	s.mu.Unlock()
 
-	return payload.Apps, nil
+	return apps, nil
+}
+
+// cachedRecommended returns the decoded cache file whose hash is sum.
+func (s *RecommendedSource) cachedRecommended(sum string) ([]RecommendedApp, error) {
+	s.mu.Lock()
+	if s.memoSum == sum {
+		apps := slices.Clone(s.memoApps)
+		s.mu.Unlock()
+		return apps, nil
+	}
+	s.mu.Unlock()
+	return s.readCache()
 }
 
 func (s *RecommendedSource) writeCache(raw []byte) error {
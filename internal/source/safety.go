@@ -0,0 +1,104 @@
+package source
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+
+	"fnos-store/internal/cache"
+	"fnos-store/internal/config"
+)
+
+const defaultSafetyJSONURL = "https://raw.githubusercontent.com/conversun/fnos-apps/main/upgrade-safety.json"
+
+// ErrNoSafetyKey means the build carries no trusted signing key; it matches
+// ErrNoSigningKey.
+var ErrNoSafetyKey = fmt.Errorf("upgrade-safety.json: %w", ErrNoSigningKey)
+
+// SafetyList is the catalog's upgrade safety deny-list. It extends the list
+// compiled into platform (see platform.UpgradeCapability) so a newly found
+// destructive fnOS build can be blocked without first updating the store.
+type SafetyList struct {
+	// Serial increases with every published list. A list older than the
+	// cached one is refused, so a mirror cannot replay a list from before an
+	// entry was added.
+	Serial int `json:"serial"`
+	// UnsafeFnOSVersions maps an fnOS build to why updating apps on it
+	// destroys them.
+	UnsafeFnOSVersions map[string]string `json:"unsafe_fnos_versions,omitempty"`
+	// AppBlocks refuse updates of single apps.
+	AppBlocks []AppUpgradeBlock `json:"app_blocks,omitempty"`
+}
+
+// AppUpgradeBlock refuses updating AppName. Versions and FnOSVersions narrow
+// it to updates towards those catalog versions and on those fnOS builds;
+// empty means any.
+type AppUpgradeBlock struct {
+	AppName      string   `json:"appname"`
+	Versions     []string `json:"versions,omitempty"`
+	FnOSVersions []string `json:"fnos_versions,omitempty"`
+	Reason       string   `json:"reason"`
+}
+
+// Applies reports whether b blocks updating its app to version on fnosVersion.
+func (b AppUpgradeBlock) Applies(version, fnosVersion string) bool {
+	if len(b.Versions) > 0 && !slices.Contains(b.Versions, version) {
+		return false
+	}
+	return len(b.FnOSVersions) == 0 || slices.Contains(b.FnOSVersions, fnosVersion)
+}
+
+// SafetySource fetches upgrade-safety.json from the catalog repository,
+// through the same mirrors as apps.json, and keeps the last verified copy for
+// offline use.
+type SafetySource struct {
+	signedFile
+}
+
+// NewSafetySource returns the upgrade safety list source. meta, when set,
+// keeps the HTTP validators of the cached copy.
+func NewSafetySource(cachePath string, cfgMgr *config.Manager, meta *cache.Store) *SafetySource {
+	return &SafetySource{newSignedFile("upgrade-safety.json", defaultSafetyJSONURL, cachePath, cfgMgr, meta)}
+}
+
+// FetchSafetyList returns the newest verified list: the remote one, or the
+// cached one when no mirror serves a valid list. It fails only when neither
+// is available.
+func (s *SafetySource) FetchSafetyList(ctx context.Context) (SafetyList, error) {
+	if len(s.keys) == 0 {
+		return SafetyList{}, ErrNoSafetyKey
+	}
+	payload, err := s.latest(ctx)
+	if err != nil {
+		return SafetyList{}, err
+	}
+	return decodeSafetyList(payload)
+}
+
+func decodeSafetyList(payload []byte) (SafetyList, error) {
+	var list SafetyList
+	if err := json.Unmarshal(payload, &list); err != nil {
+		return SafetyList{}, fmt.Errorf("decode upgrade-safety.json payload: %w", err)
+	}
+	list.AppBlocks = slices.DeleteFunc(list.AppBlocks, func(b AppUpgradeBlock) bool {
+		if b.AppName == "" || b.Reason == "" {
+			log.Printf("source: upgrade-safety.json: skipping app block without appname or reason")
+			return true
+		}
+		return false
+	})
+	return list, nil
+}
+
+// SignSafetyList wraps the JSON list payload in a signed envelope, the file
+// format FetchSafetyList reads.
+func SignSafetyList(priv ed25519.PrivateKey, payload []byte) ([]byte, error) {
+	var list SafetyList
+	if err := json.Unmarshal(payload, &list); err != nil {
+		return nil, fmt.Errorf("decode list: %w", err)
+	}
+	return signPayload(priv, payload)
+}
@@ -0,0 +1,158 @@
+package source
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fnos-store/internal/cache"
+	"fnos-store/internal/config"
+)
+
+// buildWithSigningKey stands in for a build with a signing key in its
+// -ldflags, returning the private half, so the sources pick the key up the
+// way a release does.
+func buildWithSigningKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := signingKeys
+	signingKeys = "not-a-key, " + base64.StdEncoding.EncodeToString(pub)
+	t.Cleanup(func() { signingKeys = old })
+	return priv
+}
+
+func newTestSafetySource(t *testing.T, url string) (*SafetySource, ed25519.PrivateKey) {
+	t.Helper()
+	priv := buildWithSigningKey(t)
+	dir := t.TempDir()
+	cfgMgr := config.NewManager(dir)
+	if err := cfgMgr.SaveConfig(config.Config{Mirror: "direct"}); err != nil {
+		t.Fatal(err)
+	}
+	meta := cache.NewStore(dir)
+	if err := meta.Init(); err != nil {
+		t.Fatal(err)
+	}
+	src := NewSafetySource(filepath.Join(dir, "cache", "upgrade-safety.json"), cfgMgr, meta)
+	src.url = url
+	return src, priv
+}
+
+func mustSign(t *testing.T, priv ed25519.PrivateKey, payload string) []byte {
+	t.Helper()
+	signed, err := SignSafetyList(priv, []byte(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestSafetyListVerify(t *testing.T) {
+	src, priv := newTestSafetySource(t, "")
+	signed := mustSign(t, priv, `{
+		"serial": 3,
+		"unsafe_fnos_versions": {"1.2.0300": "boom"},
+		"app_blocks": [
+			{"appname": "gopeed", "versions": ["1.7.0"], "reason": "loses downloads"},
+			{"appname": "noreason"}
+		]
+	}`)
+
+	payload, err := src.verifyPayload(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := decodeSafetyList(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.Serial != 3 || list.UnsafeFnOSVersions["1.2.0300"] != "boom" || len(list.AppBlocks) != 1 {
+		t.Errorf("list = %+v", list)
+	}
+
+	tampered := strings.Replace(string(signed), "boom", "fine", 1)
+	if _, err := src.verifyPayload([]byte(tampered)); err == nil {
+		t.Error("tampered list verified")
+	}
+	_, other, _ := ed25519.GenerateKey(rand.Reader)
+	if _, err := src.verifyPayload(mustSign(t, other, `{"serial": 4}`)); err == nil {
+		t.Error("list signed by an untrusted key verified")
+	}
+	if _, err := src.verifyPayload([]byte(`{"serial": 4}`)); err == nil {
+		t.Error("unsigned list verified")
+	}
+}
+
+func TestFetchSafetyListCachesAndRefusesRollback(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+
+	src, priv := newTestSafetySource(t, srv.URL+"/upgrade-safety.json")
+	body = mustSign(t, priv, `{"serial": 2, "unsafe_fnos_versions": {"1.2.0300": "boom"}}`)
+	list, err := src.FetchSafetyList(context.Background())
+	if err != nil || list.Serial != 2 {
+		t.Fatalf("list = %+v, err = %v", list, err)
+	}
+
+	// An older list, validly signed, is a replay: keep the cached one.
+	body = mustSign(t, priv, `{"serial": 1}`)
+	list, err = src.FetchSafetyList(context.Background())
+	if err != nil || list.Serial != 2 || list.UnsafeFnOSVersions["1.2.0300"] == "" {
+		t.Errorf("after rollback: list = %+v, err = %v", list, err)
+	}
+
+	// Offline, the cached list is used.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	list, err = src.FetchSafetyList(ctx)
+	if err != nil || list.Serial != 2 {
+		t.Errorf("offline: list = %+v, err = %v", list, err)
+	}
+}
+
+func TestFetchSafetyListWithoutKey(t *testing.T) {
+	src := NewSafetySource(filepath.Join(t.TempDir(), "upgrade-safety.json"), nil, nil)
+	if _, err := src.FetchSafetyList(context.Background()); !errors.Is(err, ErrNoSigningKey) {
+		t.Errorf("err = %v, want ErrNoSigningKey", err)
+	}
+	if SigningKeyStatus() == "" {
+		t.Error("a build without a key reports the signed files in use")
+	}
+	buildWithSigningKey(t)
+	if status := SigningKeyStatus(); status != "" {
+		t.Errorf("SigningKeyStatus() = %q with a key built in", status)
+	}
+}
+
+func TestAppUpgradeBlockApplies(t *testing.T) {
+	b := AppUpgradeBlock{AppName: "gopeed", Versions: []string{"1.7.0"}, FnOSVersions: []string{"1.2.0203"}, Reason: "x"}
+	tests := []struct {
+		version, fnos string
+		want          bool
+	}{
+		{"1.7.0", "1.2.0203", true},
+		{"1.7.1", "1.2.0203", false},
+		{"1.7.0", "1.2.0204", false},
+	}
+	for _, tt := range tests {
+		if got := b.Applies(tt.version, tt.fnos); got != tt.want {
+			t.Errorf("Applies(%q, %q) = %v, want %v", tt.version, tt.fnos, got, tt.want)
+		}
+	}
+	if !(AppUpgradeBlock{AppName: "gopeed", Reason: "x"}).Applies("anything", "") {
+		t.Error("unrestricted block does not apply")
+	}
+}
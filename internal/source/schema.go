@@ -0,0 +1,297 @@
+package source
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The catalog schemas in schema/ are the reference for whoever publishes
+// apps.json and recommended.json. Entries are validated against them one by
+// one, so a single bad entry is dropped instead of failing or poisoning the
+// whole catalog.
+//
+//go:embed schema/*.schema.json
+var schemaFS embed.FS
+
+// supportedSchemaMajor is the catalog schema major version this store reads.
+// A minor bump only adds optional fields; a major bump may change meaning, so
+// such a catalog is refused and the cached copy kept.
+const supportedSchemaMajor = 1
+
+var (
+	appsSchema        = mustLoadSchema("schema/apps.v1.schema.json")
+	recommendedSchema = mustLoadSchema("schema/recommended.v1.schema.json")
+)
+
+// jsonSchema is the subset of JSON Schema the catalog schemas use: type,
+// required, properties, items, $ref into $defs, pattern, minLength, minimum,
+// maximum, enum and format "uri".
+type jsonSchema struct {
+	Ref        string                 `json:"$ref"`
+	Defs       map[string]*jsonSchema `json:"$defs"`
+	Type       string                 `json:"type"`
+	Required   []string               `json:"required"`
+	Properties map[string]*jsonSchema `json:"properties"`
+	Items      *jsonSchema            `json:"items"`
+	Pattern    string                 `json:"pattern"`
+	MinLength  *int                   `json:"minLength"`
+	Minimum    *float64               `json:"minimum"`
+	Maximum    *float64               `json:"maximum"`
+	Enum       []any                  `json:"enum"`
+	Format     string                 `json:"format"`
+
+	pattern *regexp.Regexp
+	root    *jsonSchema
+}
+
+func mustLoadSchema(name string) *jsonSchema {
+	raw, err := schemaFS.ReadFile(name)
+	if err != nil {
+		panic(err)
+	}
+	var s jsonSchema
+	if err := json.Unmarshal(raw, &s); err != nil {
+		panic(fmt.Sprintf("%s: %v", name, err))
+	}
+	if err := s.compile(&s); err != nil {
+		panic(fmt.Sprintf("%s: %v", name, err))
+	}
+	return &s
+}
+
+func (s *jsonSchema) compile(root *jsonSchema) error {
+	s.root = root
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return err
+		}
+		s.pattern = re
+	}
+	for _, sub := range s.Defs {
+		if err := sub.compile(root); err != nil {
+			return err
+		}
+	}
+	for _, sub := range s.Properties {
+		if err := sub.compile(root); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.compile(root)
+	}
+	return nil
+}
+
+// def returns the named schema under $defs.
+func (s *jsonSchema) def(name string) *jsonSchema {
+	return s.Defs[name]
+}
+
+// validate checks v, a value decoded by encoding/json into any, and returns
+// one message per violation, prefixed with the path of the offending field.
+func (s *jsonSchema) validate(v any, path string) []string {
+	if s.Ref != "" {
+		name, ok := strings.CutPrefix(s.Ref, "#/$defs/")
+		if !ok || s.root.def(name) == nil {
+			return []string{fmt.Sprintf("%s: unresolvable $ref %q", path, s.Ref)}
+		}
+		return s.root.def(name).validate(v, path)
+	}
+
+	at := func(format string, args ...any) string {
+		msg := fmt.Sprintf(format, args...)
+		if path == "" {
+			return msg
+		}
+		return path + ": " + msg
+	}
+
+	if s.Type != "" && !hasJSONType(v, s.Type) {
+		return []string{at("must be %s", s.Type)}
+	}
+	if len(s.Enum) > 0 && !slices.Contains(s.Enum, v) {
+		return []string{at("must be one of %v", s.Enum)}
+	}
+
+	var errs []string
+	switch val := v.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := val[name]; !ok {
+				errs = append(errs, at("missing required field %q", name))
+			}
+		}
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if fv, ok := val[name]; ok {
+				errs = append(errs, s.Properties[name].validate(fv, joinPath(path, name))...)
+			}
+		}
+	case []any:
+		if s.Items != nil {
+			for i, item := range val {
+				errs = append(errs, s.Items.validate(item, path+"["+strconv.Itoa(i)+"]")...)
+			}
+		}
+	case string:
+		if s.MinLength != nil && utf8.RuneCountInString(val) < *s.MinLength {
+			if *s.MinLength == 1 {
+				errs = append(errs, at("must not be empty"))
+			} else {
+				errs = append(errs, at("must be at least %d characters", *s.MinLength))
+			}
+		}
+		if s.pattern != nil && !s.pattern.MatchString(val) {
+			errs = append(errs, at("%q does not match %s", val, s.Pattern))
+		}
+		if s.Format == "uri" && val != "" && !isHTTPURL(val) {
+			errs = append(errs, at("%q is not an http(s) URL", val))
+		}
+	case float64:
+		if s.Minimum != nil && val < *s.Minimum {
+			errs = append(errs, at("must be >= %v", *s.Minimum))
+		}
+		if s.Maximum != nil && val > *s.Maximum {
+			errs = append(errs, at("must be <= %v", *s.Maximum))
+		}
+	}
+	return errs
+}
+
+func hasJSONType(v any, typ string) bool {
+	switch typ {
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "null":
+		return v == nil
+	}
+	return false
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// catalogEnvelope is the top level every catalog file shares.
+type catalogEnvelope struct {
+	SchemaVersion any               `json:"schema_version"`
+	Apps          []json.RawMessage `json:"apps"`
+}
+
+// checkSchemaVersion accepts a catalog without a version (the format before
+// versioning) or one whose major version is supportedSchemaMajor.
+func checkSchemaVersion(what string, version any) error {
+	var v string
+	switch val := version.(type) {
+	case nil:
+		return nil
+	case string:
+		v = val
+	case float64:
+		v = strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		return fmt.Errorf("%s: invalid schema_version %v", what, version)
+	}
+	majorStr, _, _ := strings.Cut(v, ".")
+	major, err := strconv.Atoi(majorStr)
+	if err != nil {
+		return fmt.Errorf("%s: invalid schema_version %q", what, v)
+	}
+	if major != supportedSchemaMajor {
+		return fmt.Errorf("%s: unsupported schema_version %s (this store reads version %d.x); update the store", what, v, supportedSchemaMajor)
+	}
+	return nil
+}
+
+// EntryError is a catalog entry dropped because it failed validation.
+type EntryError struct {
+	Index  int      // position in the file's apps array
+	Name   string   // the entry's app name, when it has one
+	Errors []string // one message per violation
+}
+
+func (e EntryError) String() string {
+	name := e.Name
+	if name == "" {
+		name = "?"
+	}
+	return fmt.Sprintf("apps[%d] %s: %s", e.Index, name, strings.Join(e.Errors, "; "))
+}
+
+// decodeCatalog checks raw's schema version and decodes every entry that
+// validates against the schema's "app" definition, reporting the rest.
+// nameField is the property holding an entry's name, for the report.
+func decodeCatalog[T any](raw []byte, what string, schema *jsonSchema, nameField string) ([]T, []EntryError, error) {
+	var env catalogEnvelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return nil, nil, fmt.Errorf("decode %s: %w", what, err)
+	}
+	if err := checkSchemaVersion(what, env.SchemaVersion); err != nil {
+		return nil, nil, err
+	}
+
+	entrySchema := schema.def("app")
+	out := make([]T, 0, len(env.Apps))
+	var bad []EntryError
+	for i, entryRaw := range env.Apps {
+		var generic any
+		if err := json.Unmarshal(entryRaw, &generic); err != nil {
+			bad = append(bad, EntryError{Index: i, Errors: []string{err.Error()}})
+			continue
+		}
+		if errs := entrySchema.validate(generic, ""); len(errs) > 0 {
+			ee := EntryError{Index: i, Errors: errs}
+			if obj, ok := generic.(map[string]any); ok {
+				ee.Name, _ = obj[nameField].(string)
+			}
+			bad = append(bad, ee)
+			continue
+		}
+		var entry T
+		if err := json.Unmarshal(entryRaw, &entry); err != nil {
+			bad = append(bad, EntryError{Index: i, Errors: []string{err.Error()}})
+			continue
+		}
+		out = append(out, entry)
+	}
+	return out, bad, nil
+}
@@ -0,0 +1,83 @@
+package source
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeAppsSkipsInvalidEntries(t *testing.T) {
+	raw := []byte(`{"apps":[
+		{"appname":"gopeed","version":"1.6.0","fpk_version":"1.6.0","release_tag":"gopeed/v1.6.0","file_prefix":"gopeed","service_port":9999,"min_fnos_version":"1.2.0203"},
+		{"version":"1.0","fpk_version":"1.0","release_tag":"x/v1.0","file_prefix":"x"},
+		{"appname":"bad-port","version":"1.0","fpk_version":"1.0","release_tag":"b/v1.0","file_prefix":"b","service_port":-1},
+		{"appname":"bad-url","version":"1.0","fpk_version":"1.0","release_tag":"../../evil","file_prefix":"a b","icon_url":"javascript:alert(1)"},
+		"not an object",
+		{"appname":"bad-fnos","version":"1.0","fpk_version":"1.0","release_tag":"f/v1.0","file_prefix":"f","max_fnos_version":"latest"}
+	]}`)
+
+	src := &FNOSAppsSource{platform: "x86", name: "fnos-apps"}
+	apps, err := src.decodeApps(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(apps) != 1 || apps[0].AppName != "gopeed" || apps[0].ServicePort != 9999 || apps[0].MinFnOSVersion != "1.2.0203" {
+		t.Fatalf("apps = %+v, want only gopeed", apps)
+	}
+
+	invalid := src.invalidEntries()
+	if len(invalid) != 5 {
+		t.Fatalf("invalid = %v, want 5 entries", invalid)
+	}
+	wants := []struct {
+		index int
+		name  string
+		msg   string
+	}{
+		{1, "", `missing required field "appname"`},
+		{2, "bad-port", "service_port: must be >= 0"},
+		{3, "bad-url", "release_tag"},
+		{4, "", "must be object"},
+		{5, "bad-fnos", "max_fnos_version"},
+	}
+	for i, want := range wants {
+		got := invalid[i]
+		if got.Index != want.index || got.Name != want.name || !strings.Contains(strings.Join(got.Errors, "; "), want.msg) {
+			t.Errorf("invalid[%d] = %s, want index %d name %q mentioning %q", i, got, want.index, want.name, want.msg)
+		}
+	}
+	if errs := strings.Join(invalid[2].Errors, "; "); !strings.Contains(errs, "file_prefix") || !strings.Contains(errs, "icon_url") {
+		t.Errorf("bad-url errors = %s, want file_prefix and icon_url too", errs)
+	}
+}
+
+func TestCheckSchemaVersion(t *testing.T) {
+	for _, v := range []any{nil, "1", "1.0", "1.7", float64(1)} {
+		if err := checkSchemaVersion("apps.json", v); err != nil {
+			t.Errorf("version %v: %v", v, err)
+		}
+	}
+	for _, v := range []any{"2.0", "0.9", float64(2), "one", true} {
+		if err := checkSchemaVersion("apps.json", v); err == nil {
+			t.Errorf("version %v accepted", v)
+		}
+	}
+}
+
+func TestDecodeRefusesUnsupportedMajorVersion(t *testing.T) {
+	src := &FNOSAppsSource{platform: "x86"}
+	_, err := src.decodeApps([]byte(`{"schema_version":"2.0","apps":[]}`))
+	if err == nil || !strings.Contains(err.Error(), "unsupported schema_version") {
+		t.Errorf("err = %v, want unsupported schema_version", err)
+	}
+}
+
+func TestDecodeRecommendedSkipsInvalidEntries(t *testing.T) {
+	rec := &RecommendedSource{}
+	apps, err := rec.decodeRecommended([]byte(`{"schema_version":"1.1","apps":[{"name":"jellyfin","source_url":"https://jellyfin.org"},{"name":""},{"name":"x","github_repo":"not a repo"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(apps) != 1 || apps[0].Name != "jellyfin" {
+		t.Errorf("recommended = %+v, want only jellyfin", apps)
+	}
+}
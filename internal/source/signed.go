@@ -0,0 +1,247 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"fnos-store/internal/cache"
+	"fnos-store/internal/config"
+)
+
+// signingKeys are the Ed25519 public keys (base64, comma-separated) allowed to
+// sign the catalog's signed files, upgrade-safety.json and mirrors.json. They
+// travel through mirrors we do not control: a forged safety list could block
+// every update or hide a real block, a forged mirror list could send
+// downloads to a host of the forger's choosing. An unsigned or badly signed
+// file is ignored.
+//
+// The keys are set at build time, so a release is built with the publisher's
+// key and a fork can ship its own:
+//
+//	go build -ldflags "-X fnos-store/internal/source.signingKeys=<public key>" ./cmd/server/
+//
+// The Makefile, build.sh and the release workflow pass $CATALOG_SIGNING_KEYS.
+// Generate a key pair with `go run ./cmd/catalog-sign -genkey`; the private
+// half stays with whoever publishes the files. A build without a key ignores
+// both files and says so in the log and in GET /api/status.
+var signingKeys string
+
+// trustedSigningKeys decodes signingKeys, logging and skipping malformed
+// entries.
+func trustedSigningKeys() []ed25519.PublicKey {
+	keys, malformed := decodeSigningKeys()
+	for _, k := range malformed {
+		log.Printf("source: ignoring malformed signing key %q", k)
+	}
+	return keys
+}
+
+func decodeSigningKeys() (keys []ed25519.PublicKey, malformed []string) {
+	for _, k := range strings.Split(signingKeys, ",") {
+		if k = strings.TrimSpace(k); k == "" {
+			continue
+		}
+		raw, err := base64.StdEncoding.DecodeString(k)
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			malformed = append(malformed, k)
+			continue
+		}
+		keys = append(keys, ed25519.PublicKey(raw))
+	}
+	return keys, malformed
+}
+
+// ErrNoSigningKey means the build carries no trusted signing key, so no
+// signed file can be verified.
+var ErrNoSigningKey = errors.New("no catalog signing key built in")
+
+// SigningKeyStatus returns why the signed catalog files are ignored, or ""
+// when the build can verify them.
+func SigningKeyStatus() string {
+	if keys, _ := decodeSigningKeys(); len(keys) > 0 {
+		return ""
+	}
+	return "未内置目录签名公钥，upgrade-safety.json 与 mirrors.json 不会被使用（仅使用内置的安全列表和加速地址）"
+}
+
+// signedEnvelope is the file format: the payload, and a signature over the
+// exact bytes of payload as they appear in the file.
+type signedEnvelope struct {
+	Payload   json.RawMessage `json:"payload"`
+	Signature string          `json:"signature"`
+}
+
+// signedFile fetches a signed JSON file from the catalog repository, through
+// the same mirrors as apps.json, and keeps the last verified copy for offline
+// use. Payloads carry a serial that only ever increases.
+type signedFile struct {
+	name       string
+	httpClient *http.Client
+	url        string
+	cachePath  string
+	configMgr  *config.Manager
+	meta       *cache.Store
+	keys       []ed25519.PublicKey
+}
+
+func newSignedFile(name, url, cachePath string, cfgMgr *config.Manager, meta *cache.Store) signedFile {
+	f := signedFile{
+		name:       name,
+		httpClient: &http.Client{Timeout: 20 * time.Second, Transport: cfgMgr.HTTPTransport()},
+		url:        url,
+		cachePath:  cachePath,
+		configMgr:  cfgMgr,
+		meta:       meta,
+		keys:       trustedSigningKeys(),
+	}
+	if len(f.keys) == 0 {
+		log.Printf("source: %s ignored: %v", name, ErrNoSigningKey)
+	}
+	return f
+}
+
+// latest returns the payload of the newest verified copy: the remote one, or
+// the cached one when no mirror serves a valid copy. A remote copy with a
+// lower serial than the cached one is a replay and is refused. It fails only
+// when neither copy is available.
+func (f *signedFile) latest(ctx context.Context) ([]byte, error) {
+	cached, cacheErr := f.readCache()
+
+	remote, res, err := f.fetchRemote(ctx)
+	if err == nil && cacheErr == nil && !res.notModified {
+		if rs, cs := payloadSerial(remote), payloadSerial(cached); rs < cs {
+			err = fmt.Errorf("%s: serial %d is older than the cached %d", f.name, rs, cs)
+		}
+	}
+	if err != nil {
+		if cacheErr != nil {
+			return nil, err
+		}
+		log.Printf("source: %v; using the cached %s", err, f.name)
+		return cached, nil
+	}
+
+	if !res.notModified {
+		if werr := f.writeCache(res.raw); werr != nil {
+			log.Printf("source: %v", werr)
+		} else {
+			recordFetch(f.meta, f.cachePath, res)
+		}
+	}
+	return remote, nil
+}
+
+func payloadSerial(payload []byte) int {
+	var v struct {
+		Serial int `json:"serial"`
+	}
+	_ = json.Unmarshal(payload, &v)
+	return v.Serial
+}
+
+func (f *signedFile) fetchRemote(ctx context.Context) ([]byte, fetchResult, error) {
+	var cfg config.Config
+	if f.configMgr != nil {
+		cfg = f.configMgr.Get()
+	} else {
+		cfg = config.Config{Mirror: config.DefaultMirror}
+	}
+	cachedSum := fileSHA256(f.cachePath)
+
+	var lastErr error
+	for _, prefix := range config.GitHubFallbackPrefixes(cfg.Mirror, cfg) {
+		u := config.MirrorURL(prefix, f.url)
+		res, err := conditionalGet(ctx, f.httpClient, f.meta, u, cachedSum, f.name)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		res.mirror = mirrorLabelForPrefix(prefix, cfg)
+		if res.notModified {
+			payload, err := f.readCache()
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			return payload, res, nil
+		}
+		payload, err := f.verifyPayload(res.raw)
+		if err != nil {
+			// A mirror serving a bad copy says nothing about the others.
+			lastErr = err
+			continue
+		}
+		return payload, res, nil
+	}
+	return nil, fetchResult{}, lastErr
+}
+
+// verifyPayload checks raw's signature against the trusted keys and returns
+// the signed payload.
+func (f *signedFile) verifyPayload(raw []byte) ([]byte, error) {
+	var env signedEnvelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", f.name, err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(env.Signature)
+	if err != nil || len(env.Payload) == 0 {
+		return nil, fmt.Errorf("%s: missing or malformed signature", f.name)
+	}
+	if !slices.ContainsFunc(f.keys, func(k ed25519.PublicKey) bool { return ed25519.Verify(k, env.Payload, sig) }) {
+		return nil, fmt.Errorf("%s: signature does not verify", f.name)
+	}
+	return env.Payload, nil
+}
+
+func (f *signedFile) writeCache(raw []byte) error {
+	if f.cachePath == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(f.cachePath), 0o755); err != nil {
+		return fmt.Errorf("create cache dir: %w", err)
+	}
+	if err := os.WriteFile(f.cachePath, raw, 0o644); err != nil {
+		return fmt.Errorf("write %s cache %q: %w", f.name, f.cachePath, err)
+	}
+	return nil
+}
+
+// readCache returns the cached payload, verified again: the cache directory
+// is no more trusted than the network.
+func (f *signedFile) readCache() ([]byte, error) {
+	if f.cachePath == "" {
+		return nil, errors.New("cache path is empty")
+	}
+	raw, err := os.ReadFile(f.cachePath)
+	if err != nil {
+		return nil, fmt.Errorf("read %s cache %q: %w", f.name, f.cachePath, err)
+	}
+	return f.verifyPayload(raw)
+}
+
+// signPayload wraps a JSON payload in a signed envelope. The payload is
+// compacted first, so the signature covers exactly the bytes written.
+func signPayload(priv ed25519.PrivateKey, payload []byte) ([]byte, error) {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, payload); err != nil {
+		return nil, err
+	}
+	env := signedEnvelope{
+		Payload:   compact.Bytes(),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(priv, compact.Bytes())),
+	}
+	// Not indented: that would re-indent the payload and break the signature.
+	return json.Marshal(env)
+}
@@ -1,6 +1,9 @@
 package source
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // RemoteApp represents an app available from a remote source.
 type RemoteApp struct {
@@ -22,6 +25,15 @@ type RemoteApp struct {
 	Category        string
 	Source          string
 	PostInstallNote string
+	// MinFnOSVersion and MaxFnOSVersion bound the fnOS builds the app runs
+	// on, inclusive ("1.2.0203"); empty means unbounded.
+	MinFnOSVersion string
+	MaxFnOSVersion string
+	// Changelog is the catalog's per-version changelog, when it has one.
+	Changelog []ChangelogEntry
+	// RedactPatterns are the catalog's extra log-redaction rules for the app
+	// (see diagnostics.Redactor).
+	RedactPatterns []string
 }
 
 // Source provides access to a remote app catalog.
@@ -29,6 +41,38 @@ type Source interface {
 	// Name returns the identifier of this source (e.g., "fnos-apps").
 	Name() string
 
-	// FetchApps retrieves all available apps from this source.
-	FetchApps(ctx context.Context) ([]RemoteApp, error)
+	// FetchApps retrieves all available apps from this source, and where
+	// they came from.
+	FetchApps(ctx context.Context) ([]RemoteApp, Provenance, error)
+}
+
+// Catalog origins.
+const (
+	OriginRemote = "remote" // a mirror served or confirmed it just now
+	OriginCache  = "cache"  // every mirror failed; the copy from an earlier fetch
+	OriginLocal  = "local"  // every mirror failed and there was no cache; the bundled copy
+)
+
+// Provenance tells where a fetched catalog came from and how old it is.
+type Provenance struct {
+	Origin string
+	// Mirror is the mirror that served the data, for a cached copy the one
+	// that served it originally. Empty for the bundled copy.
+	Mirror string
+	// AsOf is when a mirror last confirmed the data current; for the bundled
+	// copy, the file's modification time.
+	AsOf time.Time
+	// FetchError is why the mirrors failed when Origin is not remote.
+	FetchError string
+	// InvalidEntries are the catalog entries left out because they failed
+	// schema validation.
+	InvalidEntries []EntryError
+}
+
+// Age is how old the data was at now.
+func (p Provenance) Age(now time.Time) time.Duration {
+	if p.AsOf.IsZero() {
+		return 0
+	}
+	return now.Sub(p.AsOf)
 }